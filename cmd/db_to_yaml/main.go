@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	dataschema "github.com/k-kkong/dataschema"
+)
+
+// 根据现有数据库生成yml配置，每张表一个 Entity.*.yml 文件，已存在的文件默认跳过
+//
+//	go run ./cmd/db_to_yaml -dsn "root:tiger@(127.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local" -path ./etc/
+func main() {
+	dsn := flag.String("dsn", "", "数据库连接dsn")
+	savePath := flag.String("path", "./etc/", "yml配置文件保存目录")
+	tables := flag.String("tables", "", "要生成的表，多个用逗号隔开，默认全部表")
+	overwrite := flag.Bool("overwrite", false, "是否覆盖已存在的文件")
+	flag.Parse()
+
+	dh := dataschema.NewDbToYamlHandler().SetDsn(*dsn).SetSavePath(*savePath).SetOverwrite(*overwrite)
+	if *tables != "" {
+		dh.SetTableNames(strings.Split(*tables, ",")...)
+	}
+	if err := dh.GenerateYamlE(); err != nil {
+		fmt.Printf("\x1b[%dm%s\x1b[0m\n", 31, err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	dataschema "github.com/k-kkong/dataschema"
+)
+
+// 输出yml配置的 JSON Schema，可在yml文件首行配置
+// # yaml-language-server: $schema=./entity.schema.json 让IDE提示和校验
+//
+//	go run ./cmd/yaml_json_schema -out ./entity.schema.json
+func main() {
+	out := flag.String("out", "", "输出文件，为空时输出到标准输出")
+	flag.Parse()
+
+	schema, err := dataschema.JsonSchema()
+	if err != nil {
+		fmt.Printf("\x1b[%dm生成 JSON Schema 失败: %s\x1b[0m\n", 31, err.Error())
+		os.Exit(1)
+	}
+	if *out == "" {
+		fmt.Println(string(schema))
+		return
+	}
+	if err := os.WriteFile(*out, append(schema, '\n'), 0644); err != nil {
+		fmt.Printf("\x1b[%dm写入 %s 失败: %s\x1b[0m\n", 31, *out, err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("\x1b[%dm已生成 %s\x1b[0m\n", 32, *out)
+}
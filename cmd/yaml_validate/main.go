@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	dataschema "github.com/k-kkong/dataschema"
+)
+
+// 校验yml配置目录，存在error级别诊断时退出码为1，可用于pre-commit检查
+//
+//	go run ./cmd/yaml_validate -path ./cmd/test_yaml_to_sql/etc2/
+func main() {
+	yamlPath := flag.String("path", "./etc/", "yml配置文件目录")
+	flag.Parse()
+
+	ds := dataschema.NewYamlToSqlHandler().SetYamlPath(*yamlPath).Validate()
+	for _, d := range ds {
+		color := 33
+		if d.Severity == dataschema.SEVERITY_ERROR {
+			color = 31
+		}
+		fmt.Printf("\x1b[%dm%s\x1b[0m\n", color, d.String())
+	}
+	if ds.HasError() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,409 @@
+package dataschema
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"github.com/k-kkong/dataschema/information_schema"
+)
+
+// DbToYamlHandler 根据数据库现有的表生成 YamlToSqlHandler 使用的yml配置文件，每张表一个文件
+type DbToYamlHandler struct {
+	dsn    string   //数据库连接dsn
+	db     *gorm.DB //数据库连接
+	schema string   //数据库名，为空时使用连接的当前库
+
+	savePath   string   //yml文件保存目录
+	tableNames []string //要生成的表，为空时生成全部表
+	overwrite  bool     //文件已存在时是否覆盖
+
+	files []string //最近一次生成的文件
+}
+
+// NewDbToYamlHandler 新建yml配置生成器
+func NewDbToYamlHandler() *DbToYamlHandler {
+	return &DbToYamlHandler{
+		savePath: "./etc/",
+	}
+}
+
+// SetDsn 设置数据库连接
+func (ts *DbToYamlHandler) SetDsn(dsn string) *DbToYamlHandler {
+	ts.dsn = dsn
+	return ts
+}
+
+// SetDB 设置数据库连接
+func (ts *DbToYamlHandler) SetDB(db *gorm.DB) *DbToYamlHandler {
+	ts.db = db
+	return ts
+}
+
+// SetSchema 设置数据库名，默认为连接的当前库
+func (ts *DbToYamlHandler) SetSchema(schema string) *DbToYamlHandler {
+	ts.schema = schema
+	return ts
+}
+
+// SetSavePath 设置yml文件的保存目录，默认为 ./etc/
+func (ts *DbToYamlHandler) SetSavePath(savePath string) *DbToYamlHandler {
+	ts.savePath = savePath
+	return ts
+}
+
+// SetTableNames 设置要生成yml的表，默认为全部表
+func (ts *DbToYamlHandler) SetTableNames(tableNames ...string) *DbToYamlHandler {
+	ts.tableNames = tableNames
+	return ts
+}
+
+// SetOverwrite 设置文件已存在时是否覆盖，默认跳过，避免覆盖手动修改过的配置
+func (ts *DbToYamlHandler) SetOverwrite(overwrite bool) *DbToYamlHandler {
+	ts.overwrite = overwrite
+	return ts
+}
+
+// GetFiles 获取最近一次生成的文件
+func (ts *DbToYamlHandler) GetFiles() []string {
+	return ts.files
+}
+
+// GenerateYaml 生成yml配置文件，出错时panic
+func (ts *DbToYamlHandler) GenerateYaml() *DbToYamlHandler {
+	if err := ts.GenerateYamlE(); err != nil {
+		fmt.Printf("\x1b[%dm %s \x1b[0m\n", 31, err.Error())
+		panic(err)
+	}
+	return ts
+}
+
+// GenerateYamlE 同 GenerateYaml，出错时返回 SchemaErrors 而不是panic
+func (ts *DbToYamlHandler) GenerateYamlE() error {
+	ts.files = nil
+	if err := ts.connectSql(); err != nil {
+		return SchemaErrors{err}
+	}
+
+	//文件名按库中全部的表计算，只生成部分表时文件名也保持一致
+	var sqlTables []information_schema.SqlTable
+	err := ts.db.Table("INFORMATION_SCHEMA.TABLES").
+		Where("TABLE_SCHEMA=?", ts.schema).
+		Where("TABLE_TYPE=?", "BASE TABLE").
+		Order("TABLE_NAME").Find(&sqlTables).Error
+	if err != nil {
+		return SchemaErrors{&SchemaError{Code: ERR_CODE_QUERY_DB, Message: "读取表失败", Err: err}}
+	}
+	var tnames []string
+	for _, sqlTbl := range sqlTables {
+		tnames = append(tnames, sqlTbl.TableName)
+	}
+	fileNames := ymlFileNames(tnames)
+	selected := map[string]bool{}
+	for _, tname := range ts.tableNames {
+		selected[tname] = true
+	}
+
+	var errs SchemaErrors
+	for _, sqlTbl := range sqlTables {
+		if len(selected) > 0 && !selected[sqlTbl.TableName] {
+			continue
+		}
+		if fileNames[sqlTbl.TableName] == "" {
+			errs = append(errs, &SchemaError{Code: ERR_CODE_SERIALIZE, Table: sqlTbl.TableName, Message: "与其他表的文件名重复(表名只有大小写不同)"})
+			continue
+		}
+		file := filepath.Join(ts.savePath, fileNames[sqlTbl.TableName])
+		if _, err := os.Stat(file); err == nil && !ts.overwrite {
+			fmt.Printf("\x1b[%dm文件已存在，已跳过: %s \x1b[0m\n", 33, file)
+			continue
+		}
+
+		var charset string
+		err := ts.db.Table("INFORMATION_SCHEMA.COLLATIONS").
+			Select("CHARACTER_SET_NAME").
+			Where("COLLATION_NAME=?", sqlTbl.TableCollation).
+			Find(&charset).Error
+		if err != nil {
+			errs = append(errs, &SchemaError{Code: ERR_CODE_QUERY_DB, File: file, Table: sqlTbl.TableName, Message: "读取字符集失败", Err: err})
+			continue
+		}
+		var sqlColumns []information_schema.SqlTableColumns
+		err = ts.db.Table("INFORMATION_SCHEMA.COLUMNS").
+			Where("TABLE_SCHEMA=?", ts.schema).
+			Where("TABLE_NAME=?", sqlTbl.TableName).
+			Order("ORDINAL_POSITION").
+			Find(&sqlColumns).Error
+		if err != nil {
+			errs = append(errs, &SchemaError{Code: ERR_CODE_QUERY_DB, File: file, Table: sqlTbl.TableName, Message: "读取字段失败", Err: err})
+			continue
+		}
+		var sqlIndexes []information_schema.SqlIndexes
+		if err := ts.db.Raw(showIndexesSql(ts.schema, sqlTbl.TableName)).Scan(&sqlIndexes).Error; err != nil {
+			errs = append(errs, &SchemaError{Code: ERR_CODE_QUERY_DB, File: file, Table: sqlTbl.TableName, Message: "读取索引失败", Err: err})
+			continue
+		}
+
+		content, err := tableYaml(sqlTbl, charset, sqlColumns, sqlIndexes)
+		if err == nil {
+			os.MkdirAll(ts.savePath, os.ModePerm)
+			err = os.WriteFile(file, content, 0644)
+		}
+		if err != nil {
+			errs = append(errs, &SchemaError{Code: ERR_CODE_SERIALIZE, File: file, Table: sqlTbl.TableName, Message: "生成yml失败", Err: err})
+			continue
+		}
+		ts.files = append(ts.files, file)
+		fmt.Printf("\x1b[%dm已生成: %s \x1b[0m\n", 36, file)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (ts *DbToYamlHandler) connectSql() *SchemaError {
+	if ts.db == nil {
+		if ts.dsn == "" {
+			return &SchemaError{Code: ERR_CODE_DB_CONNECT, Message: "数据库连接不能为空"}
+		}
+		db, err := gorm.Open(mysql.Open(ts.dsn), &gorm.Config{})
+		if err != nil {
+			return &SchemaError{Code: ERR_CODE_DB_CONNECT, Message: "数据库连接失败", Err: err}
+		}
+		ts.db = db
+	}
+	if ts.schema == "" {
+		ts.db.Raw("SELECT DATABASE()").Scan(&ts.schema)
+		if ts.schema == "" {
+			return &SchemaError{Code: ERR_CODE_DB_CONNECT, Message: "未指定数据库，请在dsn中指定或调用 SetSchema"}
+		}
+	}
+	return nil
+}
+
+// ymlFileName 表对应的配置文件名，如 plf_tbl_user => Entity.PlfTblUser.yml
+func ymlFileName(tname string) string {
+	var name string
+	for _, p := range strings.Split(tname, "_") {
+		if p != "" {
+			name += strings.ToUpper(p[0:1]) + p[1:]
+		}
+	}
+	return fmt.Sprintf("Entity.%s.yml", name)
+}
+
+// ymlFileNames 每张表的配置文件名，转换后文件名重复(不区分大小写，如 user_name 和 username)的表
+// 直接使用表名，如 Entity.user_name.yml，仍然重复的表(表名只有大小写不同)文件名为空
+func ymlFileNames(tnames []string) map[string]string {
+	count := map[string]int{}
+	for _, tname := range tnames {
+		count[strings.ToLower(ymlFileName(tname))]++
+	}
+	names := map[string]string{}
+	used := map[string]int{}
+	for _, tname := range tnames {
+		name := ymlFileName(tname)
+		if count[strings.ToLower(name)] > 1 {
+			name = fmt.Sprintf("Entity.%s.yml", tname)
+		}
+		names[tname] = name
+		used[strings.ToLower(name)]++
+	}
+	for tname, name := range names {
+		if used[strings.ToLower(name)] > 1 {
+			names[tname] = ""
+		}
+	}
+	return names
+}
+
+// tableYaml 按 getYamlDatas 读取的格式生成单张表的yml
+func tableYaml(sqlTbl information_schema.SqlTable, charset string, sqlColumns []information_schema.SqlTableColumns,
+	sqlIndexes []information_schema.SqlIndexes) ([]byte, error) {
+	tbl := yamlMapping()
+	yamlSet(tbl, "type", "entity")
+	yamlSet(tbl, "table", sqlTbl.TableName)
+
+	options := yamlMapping()
+	if charset != "" {
+		yamlSet(options, "charset", charset)
+	}
+	if sqlTbl.TableCollation != "" {
+		yamlSet(options, "collate", sqlTbl.TableCollation)
+	}
+	if sqlTbl.Engine != "" {
+		yamlSet(options, "engine", sqlTbl.Engine)
+	}
+	//只输出建表时显式指定的行格式
+	if strings.Contains(strings.ToLower(sqlTbl.CreateOptions), "row_format=") {
+		yamlSet(options, "row_format", strings.ToUpper(sqlTbl.RowFormat))
+	}
+	yamlSet(options, "comment", sqlTbl.TableComment)
+	yamlSetNode(tbl, "options", options)
+
+	//保持索引第一次出现的顺序，索引内按 Seq_in_index 排序
+	sort.SliceStable(sqlIndexes, func(i, j int) bool {
+		return sqlIndexes[i].Seq_in_index < sqlIndexes[j].Seq_in_index
+	})
+	var primary []string
+	var indexNames []string
+	indexSpecs := map[string]indexSpec{}
+	indexSections := map[string]string{}
+	for _, sqlIndex := range sqlIndexes {
+		if strings.ToLower(sqlIndex.Key_name) == "primary" {
+			primary = append(primary, sqlIndex.Column_name)
+			continue
+		}
+		section := sqlIndexSection(sqlIndex)
+		if section == "" {
+			continue
+		}
+		spec, ok := indexSpecs[sqlIndex.Key_name]
+		if !ok {
+			indexNames = append(indexNames, sqlIndex.Key_name)
+			indexSections[sqlIndex.Key_name] = section
+			spec = indexSpec{Name: sqlIndex.Key_name, Invisible: strings.EqualFold(sqlIndex.Visible, "NO")}
+			//BTREE 为默认值，不输出
+			if strings.EqualFold(sqlIndex.IndexType, "hash") {
+				spec.Using = "HASH"
+			}
+		}
+		spec.Columns = append(spec.Columns, sqlIndexColumn(sqlIndex))
+		indexSpecs[sqlIndex.Key_name] = spec
+	}
+
+	columns := map[string]information_schema.SqlTableColumns{}
+	for _, sc := range sqlColumns {
+		columns[sc.ColumnName] = sc
+	}
+	if len(primary) > 0 {
+		id := yamlMapping()
+		for _, name := range primary {
+			yamlSetNode(id, name, columnYaml(columns[name], sqlTbl.TableCollation))
+		}
+		yamlSetNode(tbl, "id", id)
+	}
+	for _, section := range ymlIndexSections {
+		indexes := yamlMapping()
+		for _, name := range indexNames {
+			if indexSections[name] != section.Name {
+				continue
+			}
+			spec := indexSpecs[name]
+			var columns []string
+			for _, c := range spec.Columns {
+				columns = append(columns, c.String())
+			}
+			index := yamlMapping()
+			yamlSet(index, "columns", columns)
+			if spec.Using != "" {
+				yamlSet(index, "using", strings.ToLower(spec.Using))
+			}
+			if spec.Invisible {
+				yamlSet(index, "invisible", true)
+			}
+			yamlSetNode(indexes, name, index)
+		}
+		if len(indexes.Content) > 0 {
+			yamlSetNode(tbl, section.Name, indexes)
+		}
+	}
+
+	isPrimary := map[string]bool{}
+	for _, name := range primary {
+		isPrimary[name] = true
+	}
+	fields := yamlMapping()
+	for _, sc := range sqlColumns {
+		if !isPrimary[sc.ColumnName] {
+			yamlSetNode(fields, sc.ColumnName, columnYaml(sc, sqlTbl.TableCollation))
+		}
+	}
+	yamlSetNode(tbl, "fields", fields)
+
+	doc := yamlMapping()
+	yamlSet(doc, "version", SCHEMA_VERSION)
+	yamlSetNode(doc, "Table", tbl)
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	enc.Close()
+	return b.Bytes(), nil
+}
+
+// columnYaml 数据库列对应的yml字段配置，字符集与表不同时输出 charset/collate
+func columnYaml(sc information_schema.SqlTableColumns, tableCollation string) *yaml.Node {
+	field := yamlMapping()
+	yamlSet(field, "type", sc.ColumnType)
+	if sc.CollationName != nil && *sc.CollationName != "" &&
+		normalizeCollation(*sc.CollationName) != normalizeCollation(tableCollation) {
+		if sc.CharacterSetName != nil {
+			yamlSet(field, "charset", *sc.CharacterSetName)
+		}
+		yamlSet(field, "collate", *sc.CollationName)
+	}
+	yamlSet(field, "nullable", strings.ToLower(sc.IsNullable) == "yes")
+	extra := strings.ToLower(sc.Extra)
+	switch {
+	case sc.GenerationExpression != "":
+		//INFORMATION_SCHEMA 中的表达式对引号做了转义
+		yamlSet(field, "expression", strings.ReplaceAll(sc.GenerationExpression, `\'`, `'`))
+		if strings.Contains(extra, "stored") {
+			yamlSet(field, "stored", true)
+		}
+	case strings.Contains(extra, "default_generated") && sc.ColumnDefault != nil &&
+		strings.ToLower(*sc.ColumnDefault) == "current_timestamp":
+		generator := "default current_timestamp"
+		if strings.Contains(extra, "on update current_timestamp") {
+			generator += " on update current_timestamp"
+		}
+		yamlSet(field, "generator", generator)
+	case strings.Contains(extra, "default_generated") && sc.ColumnDefault != nil:
+		//表达式默认值，yml中用括号包裹，如 (uuid())
+		value := *sc.ColumnDefault
+		if !isDefaultExpression(value) {
+			value = "(" + value + ")"
+		}
+		yamlSet(field, "default", value)
+		if generator := strings.TrimSpace(defaultGeneratedRegexp.ReplaceAllString(sc.Extra, "")); generator != "" {
+			yamlSet(field, "generator", generator)
+		}
+	default:
+		if sc.ColumnDefault != nil {
+			yamlSet(field, "default", *sc.ColumnDefault)
+		}
+		if strings.Contains(extra, "auto_increment") {
+			yamlSet(field, "generator", "AUTO_INCREMENT")
+		} else if extra != "" {
+			yamlSet(field, "generator", sc.Extra)
+		}
+	}
+	yamlSet(field, "comment", sc.ColumnComment)
+	return field
+}
+
+func yamlMapping() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode}
+}
+
+// yamlSet 在映射节点末尾添加键值，保持添加顺序
+func yamlSet(m *yaml.Node, key string, value interface{}) {
+	node := &yaml.Node{}
+	node.Encode(value)
+	yamlSetNode(m, key, node)
+}
+
+func yamlSetNode(m *yaml.Node, key string, value *yaml.Node) {
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
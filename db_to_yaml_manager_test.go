@@ -0,0 +1,139 @@
+package dataschema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYmlFileName(t *testing.T) {
+	if name := ymlFileName("plf_tbl_user"); name != "Entity.PlfTblUser.yml" {
+		t.Errorf("Unexpected file name: %s", name)
+	}
+	if name := ymlFileName("user__log_"); name != "Entity.UserLog.yml" {
+		t.Errorf("Unexpected file name: %s", name)
+	}
+}
+
+func TestYmlFileNames(t *testing.T) {
+	names := ymlFileNames([]string{"user_name", "username", "user__log_", "user_log", "plf_tbl_user", "Order", "order"})
+	want := map[string]string{
+		"user_name":    "Entity.user_name.yml",
+		"username":     "Entity.username.yml",
+		"user__log_":   "Entity.user__log_.yml",
+		"user_log":     "Entity.user_log.yml",
+		"plf_tbl_user": "Entity.PlfTblUser.yml",
+		"Order":        "",
+		"order":        "",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Unexpected file names: %v", names)
+	}
+}
+
+func TestTableYaml(t *testing.T) {
+	str := func(s string) *string { return &s }
+	sqlTbl := information_schema.SqlTable{TableName: "plf_user", TableComment: "用户表", TableCollation: "utf8mb4_general_ci"}
+	sqlColumns := []information_schema.SqlTableColumns{
+		{ColumnName: "id", ColumnType: "int(10) unsigned", DataType: "int", IsNullable: "NO", Extra: "auto_increment"},
+		{ColumnName: "tenant_id", ColumnType: "int(10) unsigned", DataType: "int", IsNullable: "NO"},
+		{ColumnName: "nickname", ColumnType: "varchar(64)", DataType: "varchar", IsNullable: "YES", ColumnDefault: str("无名"), ColumnComment: "昵称: 可为空"},
+		{ColumnName: "age", ColumnType: "int(11)", DataType: "int", IsNullable: "NO", ColumnDefault: str("3")},
+		{ColumnName: "intro", ColumnType: "text", DataType: "text", IsNullable: "YES"},
+		{ColumnName: "full_name", ColumnType: "varchar(255)", DataType: "varchar", IsNullable: "YES",
+			Extra: "STORED GENERATED", GenerationExpression: `concat(nickname,_utf8mb4\'-\')`},
+		{ColumnName: "updated_at", ColumnType: "datetime", DataType: "datetime", IsNullable: "NO",
+			ColumnDefault: str("CURRENT_TIMESTAMP"), Extra: "DEFAULT_GENERATED on update CURRENT_TIMESTAMP"},
+	}
+	sqlIndexes := []information_schema.SqlIndexes{
+		{Key_name: "PRIMARY", Seq_in_index: 2, Column_name: "tenant_id", IndexType: "BTREE"},
+		{Key_name: "PRIMARY", Seq_in_index: 1, Column_name: "id", IndexType: "BTREE"},
+		{Key_name: "uniq_nickname", Seq_in_index: 1, Column_name: "nickname", IndexType: "BTREE"},
+		{Key_name: "idx_age_nickname", Non_unique: 1, Seq_in_index: 2, Column_name: "nickname", IndexType: "BTREE"},
+		{Key_name: "idx_age_nickname", Non_unique: 1, Seq_in_index: 1, Column_name: "age", IndexType: "BTREE"},
+		{Key_name: "ft_intro", Non_unique: 1, Seq_in_index: 1, Column_name: "intro", IndexType: "FULLTEXT"},
+		{Key_name: "idx_nickname_prefix", Non_unique: 1, Seq_in_index: 1, Column_name: "nickname", IndexType: "BTREE",
+			Sub_part: func() *int { n := 8; return &n }(), Collation: str("D"), Visible: "NO"},
+	}
+
+	content, err := tableYaml(sqlTbl, "utf8mb4", sqlColumns, sqlIndexes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		t.Fatalf("Generated yaml is invalid: %v\n%s", err, content)
+	}
+	if _, err := ParseSchema(content); err != nil {
+		t.Errorf("Generated yaml does not match the schema model: %v", err)
+	}
+	jb, _ := yamlNodeToJSON(&doc)
+	tbl := gjson.GetBytes(jb, "Table")
+
+	if tbl.Get("options.charset").String() != "utf8mb4" || tbl.Get("options.collate").String() != "utf8mb4_general_ci" {
+		t.Errorf("Unexpected options: %s", tbl.Get("options"))
+	}
+	var ids []string
+	tbl.Get("id").ForEach(func(key, value gjson.Result) bool {
+		ids = append(ids, key.String())
+		return true
+	})
+	if !reflect.DeepEqual(ids, []string{"id", "tenant_id"}) {
+		t.Errorf("Expected primary key in index order, got %v", ids)
+	}
+	if tbl.Get("indexes.idx_age_nickname.columns").String() != `["age","nickname"]` ||
+		!tbl.Get("unique_indexes.uniq_nickname").Exists() || !tbl.Get("fulltext_indexes.ft_intro").Exists() {
+		t.Errorf("Unexpected indexes:\n%s", content)
+	}
+
+	if tbl.Get("indexes.idx_nickname_prefix.columns").String() != `["nickname(8) DESC"]` ||
+		!tbl.Get("indexes.idx_nickname_prefix.invisible").Bool() {
+		t.Errorf("Unexpected prefix index:\n%s", content)
+	}
+	sections, _ := sqlIndexSpecs(sqlIndexes)
+	for _, section := range ymlIndexSections {
+		for name, sqlSpec := range sections[section.Name] {
+			if yml := ymlIndexSpec(section.Kind, name, tbl.Get(section.Name+"."+name)); !sameIndex(yml, sqlSpec, "InnoDB") {
+				t.Errorf("Index %s differs:\nyml: %s\nsql: %s", name, yml.Definition(), sqlSpec.Definition())
+			}
+		}
+	}
+
+	var ds Diagnostics
+	checkYmlTable(tbl, func(d Diagnostic, path string) { ds = append(ds, d) })
+	if ds.HasError() {
+		t.Errorf("Generated yaml has errors: %v", ds)
+	}
+
+	// 生成的配置与数据库现有结构一致，不会产生变更
+	merged := gjson.Parse(ymlMergedFields(tbl))
+	for _, sc := range sqlColumns {
+		yml := ymlColumnDefinition(sc.ColumnName, merged.Get(sc.ColumnName))
+		if sc.GenerationExpression != "" {
+			if got := merged.Get(sc.ColumnName + ".expression").String(); got != "concat(nickname,_utf8mb4'-')" {
+				t.Errorf("Expected unescaped expression, got %s", got)
+			}
+			continue
+		}
+		if sql := sqlColumnDefinition(sc); !strings.EqualFold(yml, sql) {
+			t.Errorf("Column %s differs:\nyml: %s\nsql: %s", sc.ColumnName, yml, sql)
+		}
+	}
+}
+
+func TestColumnYamlExpressionDefault(t *testing.T) {
+	value := "uuid()"
+	sc := information_schema.SqlTableColumns{ColumnName: "uuid", ColumnType: "varchar(36)", IsNullable: "NO",
+		ColumnDefault: &value, Extra: "DEFAULT_GENERATED"}
+	out, err := yaml.Marshal(columnYaml(sc, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "default: (uuid())") || strings.Contains(string(out), "generator") {
+		t.Errorf("Unexpected expression default yaml:\n%s", out)
+	}
+}
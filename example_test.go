@@ -59,6 +59,40 @@ func ExampleTblToStructHandler_GenerateAllTblStruct() {
 
 }
 
+func ExampleYamlToStructHandler_GenerateAllTblStruct() {
+
+	// 不连接数据库，直接根据yml生成model，分表按分表名各生成一个
+	{
+		NewYamlToStructHandler().SetYamlPath("./cmd/test_yaml_to_sql/etc2/").
+			SetOtherTags("json").
+			GenerateAllTblStruct()
+	}
+
+	// 生成单张表
+	{
+		NewYamlToStructHandler().SetYamlPath("./cmd/test_yaml_to_sql/etc2/").
+			SetTableName("company_test").
+			SetSavePath("./pkg/models/tbl_company_test/tbl_company_test.go").
+			SetPackageInfo("company_test", "tbl_", "").
+			SeTblStructColumnNameInfo(CAMEL_CASE, FIELD_ORDER_ORDINAL_POSITION, "", "").
+			GenerateTblStruct()
+	}
+
+}
+
+func ExampleDbToYamlHandler_GenerateYaml() {
+
+	// 接入已有数据库：根据现有的表生成yml配置，之后即可使用 YamlToSqlHandler 维护表结构
+	{
+		NewDbToYamlHandler().
+			SetDsn("root:tiger@(127.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local").
+			SetSavePath("./cmd/test_yaml_to_sql/etc3/").
+			SetTableNames("plf_tbl_user", "plf_tbl_user_2").
+			GenerateYaml()
+	}
+
+}
+
 func ExampleYamlToSqlHandler_ExecuteSchemaSafeCheck() {
 
 	// 配置文件请参考 目录./cmd/test_yaml_to_sql/etc2/ 下的案例
@@ -75,4 +109,159 @@ func ExampleYamlToSqlHandler_ExecuteSchemaSafeCheck() {
 		yts.ExecuteSchemaSafeCheck()
 	}
 
+	// CI等无人值守场景：不等待终端输入，禁止删除操作并要求环境变量中的审批token
+	{
+		yts := NewYamlToSqlHandler().SetYamlPath("./cmd/test_yaml_to_sql/etc/").
+			SetDsn("root:tiger@(127.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local").
+			SetApprover(NewAllApprover(
+				NewRejectDropApprover(),
+				NewEnvTokenApprover("DATASCHEMA_APPROVE_TOKEN", "release-2024"),
+			))
+		if err := yts.ExecuteSchemaSafeCheckE(); err != nil {
+			fmt.Println(err)
+		}
+		if !yts.Approved() {
+			fmt.Println("迁移计划未通过审批")
+		}
+	}
+
+	// 大表在线变更：变更合并为一条 ALTER TABLE 并追加 ALGORITHM/LOCK，同时按yml顺序调整字段顺序
+	{
+		yts := NewYamlToSqlHandler().SetYamlPath("./cmd/test_yaml_to_sql/etc2/").
+			SetDsn("root:tiger@(127.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local").
+			SetOnlineDDL("inplace", "none").
+			SetFixColumnOrder(true)
+		yts.ExecuteSchemaSafeCheck()
+	}
+
+	// 大量分表：8个并发对比和执行，每张表完成后回调进度，失败的表不影响其他分表
+	{
+		yts := NewYamlToSqlHandler().SetYamlPath("./cmd/test_yaml_to_sql/etc2/").
+			SetDsn("root:tiger@(127.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local").
+			SetConcurrency(8).
+			SetProgress(func(p TableProgress) {
+				fmt.Println(p.Stage, p.Done, "/", p.Total, p.Table, p.Errors)
+			})
+		if err := yts.ExecuteSchemaSafeCheckE(); err != nil {
+			for table, errs := range yts.ErrorsByTable() {
+				fmt.Println(table, errs)
+			}
+		}
+	}
+
+}
+
+func ExampleYamlToSqlHandler_WritePlan() {
+
+	// 只计算迁移计划并写入sql文件，供DBA审核，不会执行任何变更
+	// 同时生成回滚文件 ./migration/plan.down.sql
+	{
+		yts := NewYamlToSqlHandler().SetYamlPath("./cmd/test_yaml_to_sql/etc2/").
+			SetDsn("root:tiger@(127.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local")
+		if err := yts.WritePlan("./migration/plan.sql"); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+}
+
+func ExampleYamlToSqlHandler_DetectDrift() {
+
+	// 每次执行迁移都会记录到 dataschema_migrations 表，可据此查看历史以及检测手动修改
+	{
+		yts := NewYamlToSqlHandler().
+			SetDsn("root:tiger@(127.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local").
+			SetExecutor("release-pipeline")
+		records, err := yts.History(10)
+		if err != nil {
+			fmt.Println(err)
+		}
+		for _, r := range records {
+			fmt.Println(r.StartedAt, r.Executor, r.Success, r.Checksum)
+		}
+
+		report, err := yts.DetectDrift()
+		if err != nil {
+			fmt.Println(err)
+		}
+		if report.HasDrift() {
+			fmt.Println("手动修改过的表:", report.ChangedTables, report.AddedTables, report.RemovedTables)
+		}
+	}
+
+}
+
+func ExampleYamlToSqlHandler_Resume() {
+
+	// DDL 会隐式提交，执行失败时已执行的步骤不会回滚，设置状态文件后执行状态保存在文件中
+	// 修复问题后调用 Resume 从失败的步骤继续执行
+	{
+		yts := NewYamlToSqlHandler().SetYamlPath("./cmd/test_yaml_to_sql/etc2/").SetStateFile(DEFAULT_STATE_FILE).
+			SetDsn("root:tiger@(127.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local")
+		if err := yts.ExecuteSchemaE(); err != nil {
+			fmt.Println(err)
+			if run := yts.GetRun(); run != nil {
+				fmt.Println("处于部分迁移状态的表:", run.MixedTables())
+			}
+			if err := yts.ResumeE(); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+
+}
+
+func ExampleYamlToSqlHandler_ExecuteTargetsE() {
+
+	// 每个租户一个库：同一套yml依次应用到每个库，每个库单独计算计划并汇总结果
+	{
+		yts := NewYamlToSqlHandler().SetYamlPath("./cmd/test_yaml_to_sql/etc2/").
+			SetDsn("root:tiger@(127.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local").
+			SetTargetSchemas("tenant_001", "tenant_002", "tenant_003")
+		results, err := yts.ExecuteTargetsE()
+		if err != nil {
+			fmt.Println("失败的租户:", results.Failed())
+		}
+		fmt.Print(results.Summary())
+	}
+
+	// 不同实例上的库
+	{
+		yts := NewYamlToSqlHandler().SetYamlPath("./cmd/test_yaml_to_sql/etc2/").
+			SetTargets(
+				Target{Name: "master-a", Dsn: "root:tiger@(10.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local"},
+				Target{Name: "master-b", Dsn: "root:tiger@(10.0.0.2:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local"},
+			)
+		if _, err := yts.PlanTargets(); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+}
+
+func ExampleLoadSchemaDir() {
+
+	// 不连接数据库，按类型读取yml配置，存在未定义的键或不支持的版本时返回错误
+	{
+		schema, err := LoadSchemaDir("./cmd/test_yaml_to_sql/etc2/")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, tbl := range schema.Tables {
+			fmt.Println(tbl.Name, len(tbl.Id)+len(tbl.Fields))
+		}
+
+		// 修改后重新输出为yml
+		if tbl := schema.Table("company_test"); tbl != nil {
+			tbl.Options.Comment = "公司表"
+			content, err := MarshalYAML(tbl)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Println(string(content))
+		}
+	}
+
 }
@@ -1,5 +1,6 @@
 package information_schema
 
+// Deprecated: 未使用，yml配置的结构见 dataschema.Table
 type YmlTable struct {
 	TableName string
 	Charset   string
@@ -11,6 +12,7 @@ type YmlTable struct {
 	Columns    []YmlColum
 }
 
+// Deprecated: 未使用，yml配置的结构见 dataschema.Index
 type YmlIndexes struct {
 	IndexName    string   `json:"index_name"`
 	IndexColumns []string `json:"columns"`
@@ -27,6 +29,7 @@ type SqlIndexesSerialize struct {
 
 type SqlColumnsSerialize map[string]map[string]string
 
+// Deprecated: 未使用，yml配置的结构见 dataschema.Column
 type YmlColum struct {
 	ColumnName    string
 	ColumnType    string
@@ -37,27 +40,74 @@ type YmlColum struct {
 }
 
 type SqlTable struct {
-	TableName    string `gorm:"column:TABLE_NAME"`
-	TableComment string `gorm:"column:TABLE_COMMENT"`
+	TableName      string `gorm:"column:TABLE_NAME"`
+	TableComment   string `gorm:"column:TABLE_COMMENT"`
+	TableCollation string `gorm:"column:TABLE_COLLATION"`
+	Engine         string `gorm:"column:ENGINE"`
+	RowFormat      string `gorm:"column:ROW_FORMAT"`
+	CreateOptions  string `gorm:"column:CREATE_OPTIONS"`
 }
 
 type SqlIndexes struct {
-	Non_unique   int    `gorm:"column:Non_unique"`
-	Key_name     string `gorm:"column:Key_name"`
-	Seq_in_index int    `gorm:"column:Seq_in_index"`
-	Column_name  string `gorm:"column:Column_name"`
-	IndexType    string `gorm:"column:Index_type"`
+	Non_unique   int     `gorm:"column:Non_unique"`
+	Key_name     string  `gorm:"column:Key_name"`
+	Seq_in_index int     `gorm:"column:Seq_in_index"`
+	Column_name  string  `gorm:"column:Column_name"`
+	IndexType    string  `gorm:"column:Index_type"`
+	Sub_part     *int    `gorm:"column:Sub_part"`  // 前缀长度，整个字段时为NULL
+	Collation    *string `gorm:"column:Collation"` // A升序 D降序，不排序的索引为NULL
+	Visible      string  `gorm:"column:Visible"`   // YES/NO，MySQL 8.0 起才有
 	// Column_name string `gorm:"column:Column_name"`
 }
 
 type SqlTableColumns struct {
-	TableName     string  `gorm:"column:TABLE_NAME"`
-	ColumnName    string  `gorm:"column:COLUMN_NAME"`
-	ColumnDefault *string `gorm:"column:COLUMN_DEFAULT"`
-	IsNullable    string  `gorm:"column:IS_NULLABLE"`
-	DataType      string  `gorm:"column:DATA_TYPE"`
-	ColumnType    string  `gorm:"column:COLUMN_TYPE"`
-	Length        *string `gorm:"column:CHARACTER_MAXIMUM_LENGTH"`
-	ColumnComment string  `gorm:"column:COLUMN_COMMENT"`
-	Extra         string  `gorm:"column:EXTRA"`
+	TableName            string  `gorm:"column:TABLE_NAME"`
+	ColumnName           string  `gorm:"column:COLUMN_NAME"`
+	OrdinalPosition      int     `gorm:"column:ORDINAL_POSITION"`
+	ColumnDefault        *string `gorm:"column:COLUMN_DEFAULT"`
+	IsNullable           string  `gorm:"column:IS_NULLABLE"`
+	DataType             string  `gorm:"column:DATA_TYPE"`
+	ColumnType           string  `gorm:"column:COLUMN_TYPE"`
+	Length               *string `gorm:"column:CHARACTER_MAXIMUM_LENGTH"`
+	ColumnComment        string  `gorm:"column:COLUMN_COMMENT"`
+	Extra                string  `gorm:"column:EXTRA"`
+	GenerationExpression string  `gorm:"column:GENERATION_EXPRESSION"`
+	CharacterSetName     *string `gorm:"column:CHARACTER_SET_NAME"`
+	CollationName        *string `gorm:"column:COLLATION_NAME"`
+}
+
+type SqlStatistics struct {
+	TableName  string `gorm:"column:TABLE_NAME"`
+	IndexName  string `gorm:"column:INDEX_NAME"`
+	NonUnique  int    `gorm:"column:NON_UNIQUE"`
+	SeqInIndex int    `gorm:"column:SEQ_IN_INDEX"`
+	ColumnName string `gorm:"column:COLUMN_NAME"`
+	IndexType  string `gorm:"column:INDEX_TYPE"`
+}
+
+// SqlForeignKey KEY_COLUMN_USAGE 关联 REFERENTIAL_CONSTRAINTS 得到的外键字段
+type SqlForeignKey struct {
+	ConstraintName       string `gorm:"column:CONSTRAINT_NAME"`
+	ColumnName           string `gorm:"column:COLUMN_NAME"`
+	OrdinalPosition      int    `gorm:"column:ORDINAL_POSITION"`
+	ReferencedTableName  string `gorm:"column:REFERENCED_TABLE_NAME"`
+	ReferencedColumnName string `gorm:"column:REFERENCED_COLUMN_NAME"`
+	UpdateRule           string `gorm:"column:UPDATE_RULE"`
+	DeleteRule           string `gorm:"column:DELETE_RULE"`
+}
+
+// SqlCheckConstraint TABLE_CONSTRAINTS 关联 CHECK_CONSTRAINTS 得到的检查约束
+type SqlCheckConstraint struct {
+	ConstraintName string `gorm:"column:CONSTRAINT_NAME"`
+	CheckClause    string `gorm:"column:CHECK_CLAUSE"`
+	Enforced       string `gorm:"column:ENFORCED"`
+}
+
+// SqlPartition INFORMATION_SCHEMA.PARTITIONS 中的分区
+type SqlPartition struct {
+	PartitionName            string `gorm:"column:PARTITION_NAME"`
+	PartitionOrdinalPosition int    `gorm:"column:PARTITION_ORDINAL_POSITION"`
+	PartitionMethod          string `gorm:"column:PARTITION_METHOD"`
+	PartitionExpression      string `gorm:"column:PARTITION_EXPRESSION"`
+	PartitionDescription     string `gorm:"column:PARTITION_DESCRIPTION"`
 }
@@ -262,32 +262,38 @@ func (ts *TblToStructHandler) SetTimeType(timeType string) *TblToStructHandler {
 func (ts *TblToStructHandler) GenerateTblStruct() *TblToStructHandler {
 	ts.connectSql()
 	ts.getColumns()
+
+	var tableComment string
+	// select * from INFORMATION_SCHEMA.TABLES where TABLE_SCHEMA=database()
+	ts.db.Table("INFORMATION_SCHEMA.TABLES").
+		Select("TABLE_COMMENT").
+		Where("TABLE_SCHEMA=database()").
+		Where("TABLE_NAME", ts.tableName).Find(&tableComment)
+	ts.saveTblStruct(ts.tblStructContent(tableComment))
+	return ts
+}
+
+// tblStructContent 根据已读取的字段生成model文件内容
+func (ts *TblToStructHandler) tblStructContent(tableComment string) string {
 	packageName := fmt.Sprintf("package %s%s%s\n",
 		ts.packageInfo.PackagePrefix,
 		ts.packageInfo.PackageName,
 		ts.packageInfo.PackageSuffix,
 	)
 	var timetypeCount int64
-	ts.db.Table("INFORMATION_SCHEMA.COLUMNS").
-		Where("TABLE_SCHEMA=database()").
-		Where("TABLE_NAME", ts.tableName).
-		Where("DATA_TYPE in ?", []string{
-			"date", "datetime", "timestamp", "time",
-			"DATE", "DATETIME", "TIMESTAMP", "TIME",
-		}).
-		Count(&timetypeCount)
+	for _, v := range ts.tblStructColumnInfo.Columns {
+		switch strings.ToLower(v.Type) {
+		case "date", "datetime", "timestamp", "time",
+			"point_date", "point_datetime", "point_timestamp", "point_time":
+			timetypeCount++
+		}
+	}
 
 	packageimport := ""
 	if ts.timeType != TIMETYPE_STRING && timetypeCount > 0 {
 		packageimport = "import \"time\"\n"
 	}
 
-	var tableComment string
-	// select * from INFORMATION_SCHEMA.TABLES where TABLE_SCHEMA=database()
-	ts.db.Table("INFORMATION_SCHEMA.TABLES").
-		Select("TABLE_COMMENT").
-		Where("TABLE_SCHEMA=database()").
-		Where("TABLE_NAME", ts.tableName).Find(&tableComment)
 	tableComment = fmt.Sprintf("//%s\n", tableComment)
 	ts.tblStructNameInfo.TblStructName = fmt.Sprintf("%s%s%s",
 		ts.tblStructNameInfo.TblStructPrefix,
@@ -310,8 +316,11 @@ func (ts *TblToStructHandler) GenerateTblStruct() *TblToStructHandler {
 		fmt.Sprintf("\t return \"%s\"\n", ts.tableName) +
 		"}\n"
 
-	fileContent := fmt.Sprintf("%s\n%s\n%s%s\n%s", packageName, packageimport, tableComment, structContent, functableName)
+	return fmt.Sprintf("%s\n%s\n%s%s\n%s", packageName, packageimport, tableComment, structContent, functableName)
+}
 
+// saveTblStruct 将model文件内容写入 savePath
+func (ts *TblToStructHandler) saveTblStruct(fileContent string) {
 	// fmt.Println(fileContent)
 	filePath := fmt.Sprint(ts.savePath)
 
@@ -321,14 +330,13 @@ func (ts *TblToStructHandler) GenerateTblStruct() *TblToStructHandler {
 	f, err := os.Create(filePath)
 	if err != nil {
 		fmt.Printf("\x1b[%dm->table: %s 生成失败\x1b[0m\n", 31, ts.tableName)
-		return ts
+		return
 	}
 	defer f.Close()
 
 	f.WriteString(fileContent)
 	fmt.Printf("\x1b[%dm->table: %s 生成成功\x1b[0m\n", 32, ts.tableName)
 	// fmt.Printf("", )
-	return ts
 }
 
 type column struct {
@@ -375,6 +383,11 @@ func (ts *TblToStructHandler) getColumns() {
 	if len(cols) < 1 {
 		panic("此表不存在或者数据库连接 不正确请检查哦")
 	}
+	ts.setColumns(cols)
+}
+
+// setColumns 根据字段信息计算结构体的字段名、类型和标签
+func (ts *TblToStructHandler) setColumns(cols []column) {
 	ts.tblStructColumnInfo.MaxLenFieldName = 0
 	ts.tblStructColumnInfo.MaxLenFieldTag = 0
 	ts.tblStructColumnInfo.MaxLenFieldType = 0
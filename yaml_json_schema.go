@@ -0,0 +1,259 @@
+package dataschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// jsonSchema JSON Schema 中的一个节点
+type jsonSchema map[string]interface{}
+
+// JsonSchema 生成描述 Entity yml 配置格式的 JSON Schema(draft-07)，
+// 可配置到 IDE(如 yaml-language-server 的 $schema) 或 CI 中校验yml文件。
+// Schema 只做结构和取值校验，跨字段、跨表以及与数据库相关的检查仍由 Validate 完成
+func JsonSchema() ([]byte, error) {
+	return json.MarshalIndent(entityJsonSchema(), "", "  ")
+}
+
+// entityJsonSchema 由 yml 配置模型和校验规则中允许的取值生成 Schema
+func entityJsonSchema() jsonSchema {
+	stringArray := jsonSchema{"type": "array", "items": jsonSchema{"type": "string"}}
+	scalar := jsonSchema{"type": []string{"string", "number", "boolean"}}
+	stringList := jsonSchema{"oneOf": []jsonSchema{{"type": "string"}, stringArray}}
+	ref := func(name string) jsonSchema { return jsonSchema{"$ref": "#/definitions/" + name} }
+	mapOf := func(name, description string) jsonSchema {
+		return jsonSchema{"type": "object", "description": description, "additionalProperties": ref(name)}
+	}
+
+	tableProperties := jsonSchema{
+		"type":            jsonSchema{"type": "string", "description": "配置类型，如 entity"},
+		"table":           jsonSchema{"type": "string", "description": "表名"},
+		"renamed_from":    describe(stringList, "表的旧名称，存在旧表时改名而不是新建"),
+		"sharding_tables": jsonSchema{"type": "string", "description": "逗号分隔的分表名"},
+		"sharding":        ref("sharding"),
+		"options":         ref("options"),
+		"primary_indexes": jsonSchema{"type": "object", "description": "主键，配置后优先于 id 下的字段", "additionalProperties": false,
+			"required": []string{"columns"}, "properties": jsonSchema{"columns": stringArray}},
+		"dropped_fields":     describe(stringArray, "声明删除的字段"),
+		"dropped_indexes":    describe(stringArray, "声明删除的索引"),
+		"dropped_partitions": describe(stringArray, "声明删除的分区"),
+		"foreign_keys":       mapOf("foreign_key", "外键，key 为约束名"),
+		"checks":             mapOf("check", "检查约束，key 为约束名"),
+		"id":                 mapOf("column", "主键字段，key 为字段名"),
+		"fields":             mapOf("column", "普通字段，key 为字段名"),
+	}
+	for _, section := range ymlIndexSections {
+		tableProperties[section.Name] = mapOf("index", fmt.Sprintf("%s，key 为索引名", section.Kind))
+	}
+
+	return jsonSchema{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "dataschema Entity yml",
+		"type":                 "object",
+		"required":             []string{"Table"},
+		"additionalProperties": false,
+		"properties": jsonSchema{
+			"version": jsonSchema{"type": "integer", "minimum": 1, "maximum": SCHEMA_VERSION, "description": "配置格式版本，未配置时按1处理"},
+			"Table":   ref("table"),
+		},
+		"definitions": jsonSchema{
+			"table": jsonSchema{
+				"type":                 "object",
+				"required":             []string{"table"},
+				"additionalProperties": false,
+				"properties":           tableProperties,
+				"not":                  jsonSchema{"required": []string{"sharding", "sharding_tables"}},
+			},
+			"sharding": jsonSchema{
+				"type":                 "object",
+				"description":          "按模板生成分表，与 sharding_tables 不能同时配置",
+				"required":             []string{"pattern", "from", "to"},
+				"additionalProperties": false,
+				"properties": jsonSchema{
+					"pattern": jsonSchema{"type": "string", "description": "分表名模板，整数如 user_%03d，日期如 log_%Y%m"},
+					"from":    describe(scalar, "起始序号或日期"),
+					"to":      describe(scalar, "结束序号或日期(包含)"),
+					"step":    jsonSchema{"type": "integer", "minimum": 1, "description": "步长，默认1"},
+				},
+			},
+			"options": jsonSchema{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": jsonSchema{
+					"charset":    jsonSchema{"type": "string"},
+					"collate":    jsonSchema{"type": "string"},
+					"comment":    scalar,
+					"engine":     jsonSchema{"type": "string", "description": "存储引擎，默认 " + DEFAULT_ENGINE},
+					"row_format": enumSchema(mapKeys(rowFormats)),
+					"algorithm":  describe(enumSchema(mapKeys(onlineDDLOptions["algorithm"])), "在线DDL的 ALGORITHM"),
+					"lock":       describe(enumSchema(mapKeys(onlineDDLOptions["lock"])), "在线DDL的 LOCK"),
+					"partitions": ref("partitions"),
+				},
+			},
+			"partitions": jsonSchema{
+				"type":                 "object",
+				"required":             []string{"type"},
+				"additionalProperties": false,
+				"properties": jsonSchema{
+					"type":       enumSchema(mapKeys(partitionMethods)),
+					"expression": jsonSchema{"type": "string", "description": "range/list/hash 的分区表达式"},
+					"columns":    describe(stringArray, "columns/key 分区的字段"),
+					"count":      jsonSchema{"type": "integer", "minimum": 1, "description": "hash/key 的分区数"},
+					"items": jsonSchema{"type": "array", "description": "range/list 的分区",
+						"items": jsonSchema{
+							"type":                 "object",
+							"required":             []string{"name", "values"},
+							"additionalProperties": false,
+							"properties": jsonSchema{
+								"name":   jsonSchema{"type": "string"},
+								"values": jsonSchema{"oneOf": []jsonSchema{scalar, {"type": "array", "items": scalar}}},
+							},
+						},
+					},
+				},
+			},
+			"column": jsonSchema{
+				"type":                 "object",
+				"required":             []string{"type"},
+				"additionalProperties": false,
+				"properties": jsonSchema{
+					"type":         jsonSchema{"type": "string", "pattern": dataTypePattern(), "description": "字段类型，如 varchar(64)、bigint unsigned"},
+					"nullable":     jsonSchema{"type": "boolean"},
+					"default":      describe(scalar, "默认值，函数表达式需用括号包裹，如 (uuid())"),
+					"generator":    jsonSchema{"type": "string", "description": "如 AUTO_INCREMENT、on update CURRENT_TIMESTAMP"},
+					"expression":   jsonSchema{"type": "string", "description": "生成列的表达式，不能与 default/generator 同时配置"},
+					"stored":       jsonSchema{"type": "boolean", "description": "生成列是否为 STORED"},
+					"charset":      jsonSchema{"type": "string"},
+					"collate":      jsonSchema{"type": "string"},
+					"renamed_from": describe(stringList, "字段的旧名称"),
+					"comment":      scalar,
+				},
+			},
+			"index": jsonSchema{
+				"type":                 "object",
+				"required":             []string{"columns"},
+				"additionalProperties": false,
+				"properties": jsonSchema{
+					"columns":     jsonSchema{"type": "array", "minItems": 1, "items": ref("index_column")},
+					"using":       enumSchema([]string{"BTREE", "HASH"}),
+					"invisible":   jsonSchema{"type": "boolean"},
+					"with_parser": jsonSchema{"type": "string", "description": "全文索引的分词插件，如 ngram"},
+				},
+			},
+			"index_column": jsonSchema{"oneOf": []jsonSchema{
+				{"type": "string", "pattern": indexColumnPattern(), "description": "name、name(20)、name(20) desc"},
+				{
+					"type":                 "object",
+					"required":             []string{"name"},
+					"additionalProperties": false,
+					"properties": jsonSchema{
+						"name":   jsonSchema{"type": "string"},
+						"length": jsonSchema{"type": "integer", "minimum": 1, "description": "前缀长度"},
+						"order":  enumSchema([]string{"ASC", "DESC"}),
+					},
+				},
+			}},
+			"foreign_key": jsonSchema{
+				"type":                 "object",
+				"required":             []string{"columns", "references"},
+				"additionalProperties": false,
+				"properties": jsonSchema{
+					"columns": stringArray,
+					"references": jsonSchema{
+						"type":                 "object",
+						"required":             []string{"table", "columns"},
+						"additionalProperties": false,
+						"properties":           jsonSchema{"table": jsonSchema{"type": "string"}, "columns": stringArray},
+					},
+					"on_delete": enumSchema(mapKeys(foreignKeyRules)),
+					"on_update": enumSchema(mapKeys(foreignKeyRules)),
+				},
+			},
+			"check": jsonSchema{"oneOf": []jsonSchema{
+				{"type": "string", "description": "检查约束的表达式"},
+				{
+					"type":                 "object",
+					"required":             []string{"expression"},
+					"additionalProperties": false,
+					"properties": jsonSchema{
+						"expression": jsonSchema{"type": "string"},
+						"enforced":   jsonSchema{"type": "boolean", "description": "默认 true"},
+					},
+				},
+			}},
+		},
+	}
+}
+
+// describe 复制节点并加上说明
+func describe(s jsonSchema, description string) jsonSchema {
+	d := jsonSchema{"description": description}
+	for k, v := range s {
+		d[k] = v
+	}
+	return d
+}
+
+// enumSchema 不区分大小写的枚举，yml中的取值大小写和空格都不敏感，
+// JSON Schema 的 enum 区分大小写，所以用 pattern 表示
+func enumSchema(values []string) jsonSchema {
+	return jsonSchema{"type": "string", "pattern": "^\\s*(?:" + caseInsensitivePattern(values) + ")\\s*$",
+		"description": "可选 " + strings.ToLower(strings.Join(values, "|"))}
+}
+
+// dataTypePattern type 的正则，与 verifyDataType(baseDataType(type)) 接受的类型一致：
+// 第一个单词后可以带长度，如 int(10) unsigned、decimal(10,2)
+func dataTypePattern() string {
+	var alternatives []string
+	for _, t := range mapKeys(dataTypes) {
+		words := strings.Fields(t)
+		p := caseInsensitivePattern(words[:1]) + `\s*(?:\([^)]*\))?`
+		for _, w := range words[1:] {
+			p += `\s+` + caseInsensitivePattern([]string{w})
+		}
+		alternatives = append(alternatives, p)
+	}
+	return `^\s*(?:` + strings.Join(alternatives, "|") + `)\s*$`
+}
+
+// indexColumnPattern 与 indexColumnRegexp 一致，JSON Schema 的正则不支持 (?i)
+func indexColumnPattern() string {
+	return `^\s*[\w$]+\s*(?:\(\s*\d+\s*\))?\s*(?:` + caseInsensitivePattern([]string{"asc", "desc"}) + `)?\s*$`
+}
+
+// caseInsensitivePattern 生成不区分大小写匹配任一取值的正则，如 hash => [hH][aA][sS][hH]，
+// 取值中的空格匹配任意空白
+func caseInsensitivePattern(values []string) string {
+	var alternatives []string
+	for _, v := range values {
+		var b strings.Builder
+		for i, w := range strings.Fields(v) {
+			if i > 0 {
+				b.WriteString(`\s+`)
+			}
+			for _, r := range w {
+				if lower, upper := unicode.ToLower(r), unicode.ToUpper(r); lower != upper {
+					b.WriteString("[" + string(lower) + string(upper) + "]")
+				} else {
+					b.WriteString(regexp.QuoteMeta(string(r)))
+				}
+			}
+		}
+		alternatives = append(alternatives, b.String())
+	}
+	return strings.Join(alternatives, "|")
+}
+
+// mapKeys 排序后的 key，保证生成的 Schema 稳定
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,93 @@
+package dataschema
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestJsonSchemaCoversModel(t *testing.T) {
+	data, err := JsonSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := gjson.ParseBytes(data)
+	if !schema.Get("properties.version").Exists() || schema.Get("required.0").String() != "Table" {
+		t.Errorf("Unexpected root: %s", schema.Get("properties").Raw)
+	}
+
+	models := map[string]interface{}{
+		"definitions.table":                             Table{},
+		"definitions.options":                           TableOptions{},
+		"definitions.sharding":                          Sharding{},
+		"definitions.partitions":                        Partitions{},
+		"definitions.partitions.properties.items.items": PartitionItem{},
+		"definitions.column":                            Column{},
+		"definitions.index":                             Index{},
+		"definitions.index_column.oneOf.1":              IndexColumn{},
+		"definitions.table.properties.primary_indexes":  PrimaryIndex{},
+		"definitions.foreign_key":                       ForeignKey{},
+		"definitions.foreign_key.properties.references": ForeignKeyReference{},
+		"definitions.check.oneOf.1":                     Check{},
+	}
+	for path, model := range models {
+		rt := reflect.TypeOf(model)
+		for i := 0; i < rt.NumField(); i++ {
+			name := strings.Split(rt.Field(i).Tag.Get("yaml"), ",")[0]
+			if name == "-" || name == "" {
+				continue
+			}
+			if !schema.Get(path + ".properties." + name).Exists() {
+				t.Errorf("%s: missing property %s for %s", path, name, rt.Name())
+			}
+		}
+	}
+}
+
+func TestJsonSchemaPatterns(t *testing.T) {
+	types := regexp.MustCompile(dataTypePattern())
+	for _, v := range []string{"int", "int(10) unsigned", "BIGINT UNSIGNED", "varchar(255)", "decimal(10,2)", "enum('a','b')", "datetime"} {
+		if !types.MatchString(v) {
+			t.Errorf("Expected type %q to match", v)
+		}
+	}
+	for _, v := range []string{"varchar unsigned", "string", "int(10)unsigned", "intx"} {
+		if types.MatchString(v) {
+			t.Errorf("Expected type %q not to match", v)
+		}
+	}
+
+	columns := regexp.MustCompile(indexColumnPattern())
+	for v, want := range map[string]bool{"name": true, "name(20)": true, "name(20) DESC": true, "name asc": true, "name(x)": false, "a b": false} {
+		if columns.MatchString(v) != want {
+			t.Errorf("Index column %q: expected match %v", v, want)
+		}
+	}
+
+	methods := regexp.MustCompile(enumSchema(mapKeys(partitionMethods))["pattern"].(string))
+	for v, want := range map[string]bool{"linear  hash": true, "RANGE COLUMNS": true, "range column": false} {
+		if methods.MatchString(v) != want {
+			t.Errorf("Partition method %q: expected match %v", v, want)
+		}
+	}
+}
+
+func TestJsonSchemaDataTypesMatchValidate(t *testing.T) {
+	types := regexp.MustCompile(dataTypePattern())
+	for _, dir := range []string{"cmd/test_yaml_to_sql/etc/", "cmd/test_yaml_to_sql/etc2/"} {
+		schema, err := LoadSchemaDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tbl := range schema.Tables {
+			for _, c := range append(append(Columns{}, tbl.Id...), tbl.Fields...) {
+				if types.MatchString(c.Type) != (verifyDataType(baseDataType(c.Type)) != 0) {
+					t.Errorf("%s.%s: pattern and verifyDataType disagree on %q", tbl.Name, c.Name, c.Type)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package dataschema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SCHEMA_VERSION 当前的yml配置格式版本，未配置 version 的文件按版本1处理
+const SCHEMA_VERSION = 1
+
+// Schema 配置目录下的全部表
+type Schema struct {
+	Tables []*Table // 按文件名排序
+}
+
+// Table 获取表配置，不存在时返回nil
+func (s *Schema) Table(name string) *Table {
+	for _, t := range s.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Table 一个yml文件中的表配置
+type Table struct {
+	Version int    `yaml:"-" json:"-"` // 文件中声明的配置版本
+	File    string `yaml:"-" json:"-"` // 所在的配置文件
+
+	Type              string        `yaml:"type,omitempty"`
+	Name              string        `yaml:"table"`
+	RenamedFrom       StringList    `yaml:"renamed_from,omitempty"`    // 表的旧名称
+	ShardingTables    string        `yaml:"sharding_tables,omitempty"` // 逗号分隔的分表名
+	Sharding          *Sharding     `yaml:"sharding,omitempty"`        // 按模板生成分表
+	Options           TableOptions  `yaml:"options"`
+	Indexes           Indexes       `yaml:"indexes,omitempty"`
+	UniqueIndexes     Indexes       `yaml:"unique_indexes,omitempty"`
+	FulltextIndexes   Indexes       `yaml:"fulltext_indexes,omitempty"`
+	SpatialIndexes    Indexes       `yaml:"spatial_indexes,omitempty"`
+	PrimaryIndexes    *PrimaryIndex `yaml:"primary_indexes,omitempty"` // 配置后优先于 id 下的字段
+	DroppedFields     []string      `yaml:"dropped_fields,omitempty"`
+	DroppedIndexes    []string      `yaml:"dropped_indexes,omitempty"`
+	DroppedPartitions []string      `yaml:"dropped_partitions,omitempty"`
+	ForeignKeys       ForeignKeys   `yaml:"foreign_keys,omitempty"`
+	Checks            Checks        `yaml:"checks,omitempty"`
+	Id                Columns       `yaml:"id"`     // 主键字段
+	Fields            Columns       `yaml:"fields"` // 普通字段
+}
+
+func (t *Table) UnmarshalYAML(node *yaml.Node) error {
+	type plain Table
+	return strictDecode(node, (*plain)(t))
+}
+
+// Column 获取字段配置，先查找主键字段，不存在时返回nil
+func (t *Table) Column(name string) *Column {
+	for _, columns := range []Columns{t.Id, t.Fields} {
+		for i := range columns {
+			if columns[i].Name == name {
+				return &columns[i]
+			}
+		}
+	}
+	return nil
+}
+
+// TableOptions 表选项
+type TableOptions struct {
+	Charset    string      `yaml:"charset,omitempty"`
+	Collate    string      `yaml:"collate,omitempty"`
+	Comment    string      `yaml:"comment,omitempty"`
+	Engine     string      `yaml:"engine,omitempty"`
+	RowFormat  string      `yaml:"row_format,omitempty"`
+	Algorithm  string      `yaml:"algorithm,omitempty"`
+	Lock       string      `yaml:"lock,omitempty"`
+	Partitions *Partitions `yaml:"partitions,omitempty"`
+}
+
+func (o *TableOptions) UnmarshalYAML(node *yaml.Node) error {
+	type plain TableOptions
+	return strictDecode(node, (*plain)(o))
+}
+
+// Sharding 分表模板
+type Sharding struct {
+	Pattern string `yaml:"pattern"`
+	From    string `yaml:"from"`
+	To      string `yaml:"to"`
+	Step    int    `yaml:"step,omitempty"`
+}
+
+func (s *Sharding) UnmarshalYAML(node *yaml.Node) error {
+	type plain Sharding
+	return strictDecode(node, (*plain)(s))
+}
+
+// Partitions 分区配置
+type Partitions struct {
+	Type       string          `yaml:"type"`
+	Expression string          `yaml:"expression,omitempty"`
+	Columns    []string        `yaml:"columns,omitempty"`
+	Count      int             `yaml:"count,omitempty"`
+	Items      []PartitionItem `yaml:"items,omitempty"`
+}
+
+func (p *Partitions) UnmarshalYAML(node *yaml.Node) error {
+	type plain Partitions
+	return strictDecode(node, (*plain)(p))
+}
+
+// PartitionItem range/list 的单个分区
+type PartitionItem struct {
+	Name   string     `yaml:"name"`
+	Values StringList `yaml:"values"`
+}
+
+func (p *PartitionItem) UnmarshalYAML(node *yaml.Node) error {
+	type plain PartitionItem
+	return strictDecode(node, (*plain)(p))
+}
+
+// Column 字段配置
+type Column struct {
+	Name        string     `yaml:"-"`
+	Type        string     `yaml:"type"`
+	Nullable    bool       `yaml:"nullable"`
+	Default     *string    `yaml:"default,omitempty"`    // 为nil表示没有默认值
+	Generator   string     `yaml:"generator,omitempty"`  // 如 AUTO_INCREMENT
+	Expression  string     `yaml:"expression,omitempty"` // 生成列的表达式
+	Stored      bool       `yaml:"stored,omitempty"`
+	Charset     string     `yaml:"charset,omitempty"`
+	Collate     string     `yaml:"collate,omitempty"`
+	RenamedFrom StringList `yaml:"renamed_from,omitempty"`
+	Comment     string     `yaml:"comment"`
+}
+
+func (c *Column) UnmarshalYAML(node *yaml.Node) error {
+	type plain Column
+	return strictDecode(node, (*plain)(c))
+}
+
+// Columns 按文件中的顺序排列的字段
+type Columns []Column
+
+func (cs *Columns) UnmarshalYAML(node *yaml.Node) error {
+	return decodeMapping(node, func(key string, value *yaml.Node) error {
+		c := Column{}
+		if err := value.Decode(&c); err != nil {
+			return err
+		}
+		c.Name = key
+		*cs = append(*cs, c)
+		return nil
+	})
+}
+
+func (cs Columns) MarshalYAML() (interface{}, error) {
+	return encodeMapping(len(cs), func(i int) (string, interface{}) { return cs[i].Name, cs[i] })
+}
+
+// Index 普通/唯一/全文/空间索引
+type Index struct {
+	Name       string        `yaml:"-"`
+	Columns    []IndexColumn `yaml:"columns"`
+	Using      string        `yaml:"using,omitempty"`
+	Invisible  bool          `yaml:"invisible,omitempty"`
+	WithParser string        `yaml:"with_parser,omitempty"`
+}
+
+func (idx *Index) UnmarshalYAML(node *yaml.Node) error {
+	type plain Index
+	return strictDecode(node, (*plain)(idx))
+}
+
+// Indexes 按文件中的顺序排列的索引
+type Indexes []Index
+
+func (is *Indexes) UnmarshalYAML(node *yaml.Node) error {
+	return decodeMapping(node, func(key string, value *yaml.Node) error {
+		idx := Index{}
+		if err := value.Decode(&idx); err != nil {
+			return err
+		}
+		idx.Name = key
+		*is = append(*is, idx)
+		return nil
+	})
+}
+
+func (is Indexes) MarshalYAML() (interface{}, error) {
+	return encodeMapping(len(is), func(i int) (string, interface{}) { return is[i].Name, is[i] })
+}
+
+// IndexColumn 索引字段，yml中写作 name(20) desc 或 {name: name, length: 20, order: desc}
+type IndexColumn struct {
+	Name   string `yaml:"name"`
+	Length int    `yaml:"length,omitempty"` // 前缀长度，0表示整个字段
+	Order  string `yaml:"order,omitempty"`  // asc|desc
+}
+
+func (c *IndexColumn) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		type plain IndexColumn
+		if err := strictDecode(node, (*plain)(c)); err != nil {
+			return err
+		}
+	} else {
+		m := indexColumnRegexp.FindStringSubmatch(node.Value)
+		if m == nil {
+			return fmt.Errorf("line %d: 索引字段配置不正确:'%s'", node.Line, node.Value)
+		}
+		c.Name, c.Order = m[1], strings.ToLower(m[3])
+		c.Length, _ = strconv.Atoi(m[2])
+	}
+	c.Order = strings.ToLower(c.Order)
+	if c.Name == "" || c.Length < 0 || (c.Order != "" && c.Order != "asc" && c.Order != "desc") {
+		return fmt.Errorf("line %d: 索引字段配置不正确", node.Line)
+	}
+	return nil
+}
+
+func (c IndexColumn) MarshalYAML() (interface{}, error) {
+	return c.String(), nil
+}
+
+// String yml中的简写形式，如 name(20) desc
+func (c IndexColumn) String() string {
+	s := c.Name
+	if c.Length > 0 {
+		s = fmt.Sprintf("%s(%d)", s, c.Length)
+	}
+	if c.Order == "desc" {
+		s += " desc"
+	}
+	return s
+}
+
+// PrimaryIndex 单独配置的主键
+type PrimaryIndex struct {
+	Columns []string `yaml:"columns"`
+}
+
+func (p *PrimaryIndex) UnmarshalYAML(node *yaml.Node) error {
+	type plain PrimaryIndex
+	return strictDecode(node, (*plain)(p))
+}
+
+// ForeignKey 外键
+type ForeignKey struct {
+	Name       string              `yaml:"-"`
+	Columns    []string            `yaml:"columns"`
+	References ForeignKeyReference `yaml:"references"`
+	OnDelete   string              `yaml:"on_delete,omitempty"`
+	OnUpdate   string              `yaml:"on_update,omitempty"`
+}
+
+func (fk *ForeignKey) UnmarshalYAML(node *yaml.Node) error {
+	type plain ForeignKey
+	return strictDecode(node, (*plain)(fk))
+}
+
+// ForeignKeyReference 外键引用的表和字段
+type ForeignKeyReference struct {
+	Table   string   `yaml:"table"`
+	Columns []string `yaml:"columns"`
+}
+
+func (r *ForeignKeyReference) UnmarshalYAML(node *yaml.Node) error {
+	type plain ForeignKeyReference
+	return strictDecode(node, (*plain)(r))
+}
+
+// ForeignKeys 按文件中的顺序排列的外键
+type ForeignKeys []ForeignKey
+
+func (fks *ForeignKeys) UnmarshalYAML(node *yaml.Node) error {
+	return decodeMapping(node, func(key string, value *yaml.Node) error {
+		fk := ForeignKey{}
+		if err := value.Decode(&fk); err != nil {
+			return err
+		}
+		fk.Name = key
+		*fks = append(*fks, fk)
+		return nil
+	})
+}
+
+func (fks ForeignKeys) MarshalYAML() (interface{}, error) {
+	return encodeMapping(len(fks), func(i int) (string, interface{}) { return fks[i].Name, fks[i] })
+}
+
+// Check 检查约束，yml中可以只写表达式
+type Check struct {
+	Name       string `yaml:"-"`
+	Expression string `yaml:"expression"`
+	Enforced   *bool  `yaml:"enforced,omitempty"` // 为nil表示 ENFORCED
+}
+
+func (c *Check) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.Expression = node.Value
+		return nil
+	}
+	type plain Check
+	return strictDecode(node, (*plain)(c))
+}
+
+func (c Check) MarshalYAML() (interface{}, error) {
+	if c.Enforced == nil {
+		return c.Expression, nil
+	}
+	type plain Check
+	return plain(c), nil
+}
+
+// Checks 按文件中的顺序排列的检查约束
+type Checks []Check
+
+func (cs *Checks) UnmarshalYAML(node *yaml.Node) error {
+	return decodeMapping(node, func(key string, value *yaml.Node) error {
+		c := Check{}
+		if err := value.Decode(&c); err != nil {
+			return err
+		}
+		c.Name = key
+		*cs = append(*cs, c)
+		return nil
+	})
+}
+
+func (cs Checks) MarshalYAML() (interface{}, error) {
+	return encodeMapping(len(cs), func(i int) (string, interface{}) { return cs[i].Name, cs[i] })
+}
+
+// StringList 字符串或字符串数组，如 renamed_from、分区的 values
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = StringList{node.Value}
+		return nil
+	}
+	return node.Decode((*[]string)(l))
+}
+
+func (l StringList) MarshalYAML() (interface{}, error) {
+	if len(l) == 1 {
+		return l[0], nil
+	}
+	return []string(l), nil
+}
+
+// UnknownFieldError 配置中存在模型未定义的键
+type UnknownFieldError struct {
+	Field  string // 键名
+	Line   int    // 行号
+	Column int    // 列号
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("line %d: 未知的配置项:'%s'", e.Line, e.Field)
+}
+
+// schemaDocument yml文件的根节点
+type schemaDocument struct {
+	Version int    `yaml:"version,omitempty"`
+	Table   *Table `yaml:"Table"`
+}
+
+// ParseSchema 严格解析yml配置，存在未定义的键或版本不支持时返回错误
+func ParseSchema(content []byte) (*Table, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("缺少 Table 配置")
+	}
+	var doc schemaDocument
+	if err := strictDecode(root.Content[0], &doc); err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(doc.Version); err != nil {
+		return nil, err
+	}
+	if doc.Table == nil {
+		return nil, fmt.Errorf("缺少 Table 配置")
+	}
+	doc.Table.Version = doc.Version
+	if doc.Table.Version == 0 {
+		doc.Table.Version = SCHEMA_VERSION
+	}
+	return doc.Table, nil
+}
+
+// LoadSchemaFile 读取并解析一个yml配置文件
+func LoadSchemaFile(path string) (*Table, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &SchemaError{Code: ERR_CODE_READ_FILE, File: path, Message: "配置文件读取失败", Err: err}
+	}
+	return parseSchemaFile(path, content)
+}
+
+// parseSchemaFile 严格解析配置文件内容，错误统一转换为 *SchemaError
+func parseSchemaFile(path string, content []byte) (*Table, error) {
+	t, err := ParseSchema(content)
+	if err != nil {
+		var se *SchemaError
+		if errors.As(err, &se) {
+			se.File = path
+			return nil, se
+		}
+		code := ERR_CODE_PARSE_FILE
+		var ue *UnknownFieldError
+		if errors.As(err, &ue) {
+			code = ERR_CODE_UNKNOWN_FIELD
+		}
+		return nil, &SchemaError{Code: code, File: path, Message: "配置文件解析失败", Err: err}
+	}
+	t.File = path
+	return t, nil
+}
+
+// LoadSchemaDir 读取配置目录下的全部yml文件，所有文件的错误以 SchemaErrors 一起返回
+func LoadSchemaDir(dir string) (*Schema, error) {
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	files, err := listYamlFiles(dir)
+	if err != nil {
+		return nil, SchemaErrors{{Code: ERR_CODE_READ_DIR, File: dir, Message: "配置目录读取失败", Err: err}}
+	}
+	schema := &Schema{}
+	var errs SchemaErrors
+	for _, file := range files {
+		t, err := LoadSchemaFile(file)
+		if err != nil {
+			errs = append(errs, err.(*SchemaError))
+			continue
+		}
+		if other := schema.Table(t.Name); other != nil {
+			errs = append(errs, &SchemaError{Code: ERR_CODE_DUPLICATE_TABLE, File: file, Table: t.Name,
+				Message: fmt.Sprintf("重复定义的表，已在 %s 中定义", other.File)})
+			continue
+		}
+		schema.Tables = append(schema.Tables, t)
+	}
+	if len(errs) > 0 {
+		return schema, errs
+	}
+	return schema, nil
+}
+
+// MarshalYAML 将表配置输出为yml，总是写入当前的配置版本
+func MarshalYAML(t *Table) ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(schemaDocument{Version: SCHEMA_VERSION, Table: t}); err != nil {
+		return nil, err
+	}
+	enc.Close()
+	return b.Bytes(), nil
+}
+
+// checkSchemaVersion 检查配置版本，0表示未配置
+func checkSchemaVersion(version int) error {
+	if version < 0 || version > SCHEMA_VERSION {
+		return &SchemaError{Code: ERR_CODE_UNSUPPORTED_VERSION,
+			Message: fmt.Sprintf("不支持的配置版本:%d，当前支持的最高版本为%d", version, SCHEMA_VERSION)}
+	}
+	return nil
+}
+
+// strictDecode 解码前检查映射节点中的键都在 out 的 yaml 标签中。
+// yaml.v3 的 KnownFields 在自定义 UnmarshalYAML 中不生效，所以逐层检查
+func strictDecode(node *yaml.Node, out interface{}) error {
+	if node.Kind == yaml.MappingNode {
+		known := map[string]bool{}
+		rt := reflect.TypeOf(out).Elem()
+		for i := 0; i < rt.NumField(); i++ {
+			if name := strings.Split(rt.Field(i).Tag.Get("yaml"), ",")[0]; name != "" && name != "-" {
+				known[name] = true
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if key := node.Content[i]; !known[key.Value] {
+				return &UnknownFieldError{Field: key.Value, Line: key.Line, Column: key.Column}
+			}
+		}
+	}
+	return node.Decode(out)
+}
+
+// decodeMapping 依次解码映射节点中的每一项，保留在文件中的顺序
+func decodeMapping(node *yaml.Node, decode func(key string, value *yaml.Node) error) error {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: 应为键值对配置", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if err := decode(node.Content[i].Value, node.Content[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeMapping 按顺序输出映射节点
+func encodeMapping(n int, item func(i int) (string, interface{})) (*yaml.Node, error) {
+	m := yamlMapping()
+	for i := 0; i < n; i++ {
+		key, value := item(i)
+		node := &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			return nil, err
+		}
+		yamlSetNode(m, key, node)
+	}
+	return m, nil
+}
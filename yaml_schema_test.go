@@ -0,0 +1,225 @@
+package dataschema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const testSchemaYaml = `version: 1
+Table:
+  type: entity
+  table: article
+  renamed_from: post
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+    comment: 文章
+    partitions:
+      type: list columns
+      columns: [region]
+      items:
+        - name: p_east
+          values: ["'sh'", "'hz'"]
+  indexes:
+    idx_title:
+      columns:
+        - title(20) desc
+        - {name: created_at, order: DESC}
+      invisible: true
+  foreign_keys:
+    fk_article_user:
+      columns: [user_id]
+      references:
+        table: user
+        columns: [id]
+      on_delete: cascade
+  checks:
+    chk_hits: hits >= 0
+    chk_title:
+      expression: char_length(title) > 0
+      enforced: false
+  id:
+    id:
+      type: bigint unsigned
+      generator: AUTO_INCREMENT
+  fields:
+    title:
+      type: varchar
+      default: it's
+      comment: 标题
+    hits:
+      type: int
+      default: 0
+    user_id:
+      type: bigint unsigned
+    created_at:
+      type: datetime
+      default: CURRENT_TIMESTAMP
+`
+
+func TestParseSchema(t *testing.T) {
+	tbl, err := ParseSchema([]byte(testSchemaYaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tbl.Version != 1 || tbl.Name != "article" || !reflect.DeepEqual(tbl.RenamedFrom, StringList{"post"}) {
+		t.Errorf("Unexpected table: %+v", tbl)
+	}
+	var names []string
+	for _, c := range tbl.Fields {
+		names = append(names, c.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"title", "hits", "user_id", "created_at"}) {
+		t.Errorf("Expected fields in file order, got %v", names)
+	}
+	if c := tbl.Column("hits"); c == nil || c.Default == nil || *c.Default != "0" {
+		t.Errorf("Unexpected hits column: %+v", c)
+	}
+	if c := tbl.Column("user_id"); c == nil || c.Default != nil {
+		t.Errorf("Expected user_id without default, got %+v", c)
+	}
+	if tbl.Column("id") == nil || tbl.Column("missing") != nil {
+		t.Errorf("Unexpected column lookup")
+	}
+
+	idx := tbl.Indexes[0]
+	want := []IndexColumn{{Name: "title", Length: 20, Order: "desc"}, {Name: "created_at", Order: "desc"}}
+	if idx.Name != "idx_title" || !idx.Invisible || !reflect.DeepEqual(idx.Columns, want) {
+		t.Errorf("Unexpected index: %+v", idx)
+	}
+	if tbl.Checks[0].Expression != "hits >= 0" || tbl.Checks[0].Enforced != nil ||
+		tbl.Checks[1].Enforced == nil || *tbl.Checks[1].Enforced {
+		t.Errorf("Unexpected checks: %+v", tbl.Checks)
+	}
+	if fk := tbl.ForeignKeys[0]; fk.References.Table != "user" || fk.OnDelete != "cascade" {
+		t.Errorf("Unexpected foreign key: %+v", fk)
+	}
+	if p := tbl.Options.Partitions; p == nil || !reflect.DeepEqual(p.Items[0].Values, StringList{"'sh'", "'hz'"}) {
+		t.Errorf("Unexpected partitions: %+v", p)
+	}
+}
+
+func TestParseSchemaStrict(t *testing.T) {
+	_, err := ParseSchema([]byte("Table:\n  table: a\n  fields:\n    name:\n      type: varchar\n      nulable: true\n"))
+	var ue *UnknownFieldError
+	if !errors.As(err, &ue) || ue.Field != "nulable" || ue.Line != 6 {
+		t.Errorf("Expected unknown field error at line 6, got %v", err)
+	}
+
+	_, err = ParseSchema([]byte("Table:\n  table: a\n  options:\n    charst: utf8mb4\n"))
+	if !errors.As(err, &ue) || ue.Field != "charst" {
+		t.Errorf("Expected unknown option error, got %v", err)
+	}
+
+	_, err = ParseSchema([]byte("version: 2\nTable:\n  table: a\n"))
+	var se *SchemaError
+	if !errors.As(err, &se) || se.Code != ERR_CODE_UNSUPPORTED_VERSION {
+		t.Errorf("Expected unsupported version error, got %v", err)
+	}
+
+	tbl, err := ParseSchema([]byte("Table:\n  table: a\n"))
+	if err != nil || tbl.Version != SCHEMA_VERSION {
+		t.Errorf("Expected missing version to default to %d, got %+v %v", SCHEMA_VERSION, tbl, err)
+	}
+}
+
+func TestMarshalYAMLRoundTrip(t *testing.T) {
+	tbl, err := ParseSchema([]byte(testSchemaYaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := MarshalYAML(tbl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseSchema(out)
+	if err != nil {
+		t.Fatalf("Failed to parse marshaled yaml: %v\n%s", err, out)
+	}
+	if !reflect.DeepEqual(tbl, again) {
+		t.Errorf("Round trip differs:\n%s", out)
+	}
+}
+
+func TestLoadSchemaDir(t *testing.T) {
+	for _, dir := range []string{"cmd/test_yaml_to_sql/etc", "cmd/test_yaml_to_sql/etc2/"} {
+		schema, err := LoadSchemaDir(dir)
+		if err != nil {
+			t.Errorf("Failed to load %s: %v", dir, err)
+			continue
+		}
+		if len(schema.Tables) == 0 {
+			t.Errorf("Expected tables in %s", dir)
+		}
+	}
+
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.A.yml", "Table:\n  table: a\n")
+	writeTestYaml(t, dir, "Entity.B.yml", "Table:\n  table: a\n")
+	writeTestYaml(t, dir, "Entity.C.yml", "Table:\n  tabel: c\n")
+	schema, err := LoadSchemaDir(dir)
+	var errs SchemaErrors
+	if !errors.As(err, &errs) || len(errs) != 2 ||
+		errs[0].Code != ERR_CODE_DUPLICATE_TABLE || errs[1].Code != ERR_CODE_UNKNOWN_FIELD {
+		t.Errorf("Unexpected errors: %v", err)
+	}
+	if len(schema.Tables) != 1 || schema.Table("a").File != dir+"Entity.A.yml" {
+		t.Errorf("Unexpected schema: %+v", schema.Tables)
+	}
+}
+
+func TestValidateSchemaVersionAndUnknownFields(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.Article.yml", `version: 2
+Table:
+  table: article
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+  fields:
+    title:
+      type: varchar(255)
+      nulable: true
+`)
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	var got []string
+	for _, d := range ds {
+		got = append(got, d.Severity+":"+d.Code)
+	}
+	want := []string{SEVERITY_ERROR + ":" + ERR_CODE_UNSUPPORTED_VERSION, SEVERITY_ERROR + ":" + ERR_CODE_UNKNOWN_FIELD}
+	if !reflect.DeepEqual(got, want) || ds[0].Line != 1 || ds[1].Line != 10 {
+		t.Errorf("Unexpected diagnostics: %v", ds)
+	}
+}
+
+func TestGetYamlDatasRejectsUnknownFields(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.Article.yml", "Table:\n  table: article\n  fields:\n    title:\n      type: varchar\n      nullabel: true\n")
+	ts := NewYamlToSqlHandler().SetYamlPath(dir).getyamlFileFullPaths().getYamlDatas()
+	errs := ts.Errors()
+	if len(errs) != 1 || errs[0].Code != ERR_CODE_UNKNOWN_FIELD || errs[0].Table != "article" || errs[0].File != dir+"Entity.Article.yml" {
+		t.Errorf("Expected unknown field error, got %v", errs)
+	}
+	if len(ts.tables) != 0 {
+		t.Errorf("Expected the table to be skipped, got %v", ts.tables)
+	}
+}
+
+func TestValidateParseError(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.Article.yml", `Table:
+  table: article
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+  fields:
+    title:
+      type: varchar(255)
+      nullable: abc
+`)
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	if len(ds) != 1 || ds[0].Severity != SEVERITY_ERROR || ds[0].Code != ERR_CODE_PARSE_FILE || ds[0].Line != 9 {
+		t.Errorf("Unexpected diagnostics: %v", ds)
+	}
+}
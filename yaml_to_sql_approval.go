@@ -0,0 +1,113 @@
+package dataschema
+
+import (
+	"fmt"
+	"os"
+)
+
+// Approver 迁移计划审批，返回true时才会执行
+type Approver interface {
+	Approve(plan *MigrationPlan) bool
+}
+
+// ApproverFunc 使用函数作为审批器
+type ApproverFunc func(plan *MigrationPlan) bool
+
+func (f ApproverFunc) Approve(plan *MigrationPlan) bool {
+	return f(plan)
+}
+
+// IsAdditive 是否为只新增不修改的操作
+func (op PlanOperation) IsAdditive() bool {
+	switch op.Type {
+	case OP_CREATE_TABLE, OP_ADD_COLUMN, OP_ADD_PARTITION:
+		return true
+	case OP_ADD_INDEX, OP_ADD_FOREIGN_KEY, OP_ADD_CHECK:
+		// 修改索引、外键、检查约束时会先删除再新增，只有全新的才算新增
+		return op.OldDefinition == ""
+	}
+	return false
+}
+
+// IsDrop 是否包含删除操作
+func (op PlanOperation) IsDrop() bool {
+	switch op.Type {
+	case OP_DROP_COLUMN, OP_DROP_INDEX, OP_DROP_FOREIGN_KEY, OP_DROP_CHECK, OP_DROP_PARTITION:
+		return true
+	case OP_CHANGE_PRIMARY_KEY:
+		return op.OldDefinition != ""
+	}
+	return false
+}
+
+// StdinApprover 在终端中等待输入[ Y ]确认，未设置审批器时默认使用
+type StdinApprover struct{}
+
+func (StdinApprover) Approve(plan *MigrationPlan) bool {
+	fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
+	fmt.Printf("\x1b[%dm确认执行请输入[ Y ]： \x1b[0m\n", 34)
+	fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
+	commend := ""
+	fmt.Scanln(&commend)
+	return commend == "Y" || commend == "y"
+}
+
+// NewAdditiveOnlyApprover 只自动通过纯新增的计划(建表、加字段、加索引)，其他变更一律拒绝
+func NewAdditiveOnlyApprover() Approver {
+	return ApproverFunc(func(plan *MigrationPlan) bool {
+		for _, op := range plan.Operations() {
+			if !op.IsAdditive() {
+				fmt.Printf("\x1b[%dm 表: %s 的 [%s] %s 不是新增操作，需要人工审批 \x1b[0m\n", 33, op.Table, op.Type, op.Name)
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// NewRejectDropApprover 计划中包含任何删除操作时拒绝
+func NewRejectDropApprover() Approver {
+	return ApproverFunc(func(plan *MigrationPlan) bool {
+		for _, op := range plan.Operations() {
+			if op.IsDrop() {
+				fmt.Printf("\x1b[%dm 表: %s 的 [%s] %s 包含删除操作，已拒绝 \x1b[0m\n", 33, op.Table, op.Type, op.Name)
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// NewEnvTokenApprover 环境变量 envName 的值与 token 一致时通过
+func NewEnvTokenApprover(envName, token string) Approver {
+	return ApproverFunc(func(plan *MigrationPlan) bool {
+		if token == "" || os.Getenv(envName) != token {
+			fmt.Printf("\x1b[%dm 环境变量 %s 中的审批token不正确 \x1b[0m\n", 33, envName)
+			return false
+		}
+		return true
+	})
+}
+
+// NewAllApprover 所有审批器都通过时才通过，如 NewAllApprover(NewRejectDropApprover(), NewEnvTokenApprover(...))
+func NewAllApprover(approvers ...Approver) Approver {
+	return ApproverFunc(func(plan *MigrationPlan) bool {
+		for _, a := range approvers {
+			if !a.Approve(plan) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// SetApprover 设置 ExecuteSchemaSafeCheck 使用的审批器，默认为 StdinApprover
+func (ts *YamlToSqlHandler) SetApprover(approver Approver) *YamlToSqlHandler {
+	ts.approver = approver
+	return ts
+}
+
+// Approved 最近一次安全同步的计划是否通过了审批
+func (ts *YamlToSqlHandler) Approved() bool {
+	return ts.approved
+}
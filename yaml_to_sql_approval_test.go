@@ -0,0 +1,44 @@
+package dataschema
+
+import (
+	"testing"
+)
+
+func TestBuiltinApprovers(t *testing.T) {
+	additive := &MigrationPlan{Tables: []TablePlan{{Table: "user", Operations: []PlanOperation{
+		{Type: OP_CREATE_TABLE, Table: "user"},
+		{Type: OP_ADD_COLUMN, Table: "user", Name: "name"},
+		{Type: OP_ADD_INDEX, Table: "user", Name: "idx_name", NewDefinition: "INDEX idx_name (name)"},
+	}}}}
+	modify := &MigrationPlan{Tables: []TablePlan{{Table: "user", Operations: []PlanOperation{
+		{Type: OP_MODIFY_COLUMN, Table: "user", Name: "name"},
+	}}}}
+	drop := &MigrationPlan{Tables: []TablePlan{{Table: "user", Operations: []PlanOperation{
+		{Type: OP_DROP_COLUMN, Table: "user", Name: "name"},
+	}}}}
+
+	t.Setenv("DATASCHEMA_TEST_TOKEN", "secret")
+
+	tests := []struct {
+		name     string
+		approver Approver
+		plan     *MigrationPlan
+		want     bool
+	}{
+		{"AdditiveOnlyAdditive", NewAdditiveOnlyApprover(), additive, true},
+		{"AdditiveOnlyModify", NewAdditiveOnlyApprover(), modify, false},
+		{"RejectDropModify", NewRejectDropApprover(), modify, true},
+		{"RejectDropDrop", NewRejectDropApprover(), drop, false},
+		{"EnvTokenMatch", NewEnvTokenApprover("DATASCHEMA_TEST_TOKEN", "secret"), drop, true},
+		{"EnvTokenMismatch", NewEnvTokenApprover("DATASCHEMA_TEST_TOKEN", "other"), drop, false},
+		{"EnvTokenEmpty", NewEnvTokenApprover("DATASCHEMA_TEST_MISSING", ""), drop, false},
+		{"All", NewAllApprover(NewRejectDropApprover(), NewEnvTokenApprover("DATASCHEMA_TEST_TOKEN", "secret")), drop, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.approver.Approve(tt.plan); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
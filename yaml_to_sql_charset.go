@@ -0,0 +1,192 @@
+package dataschema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+// DEFAULT_ENGINE 未配置 options.engine 时建表使用的存储引擎
+const DEFAULT_ENGINE = "InnoDB"
+
+// rowFormats options.row_format 允许的取值
+var rowFormats = map[string]bool{
+	"default": true, "dynamic": true, "fixed": true, "compressed": true, "redundant": true, "compact": true,
+}
+
+// charsetTypes 可以设置字符集的字段类型
+var charsetTypes = map[string]bool{
+	"char": true, "varchar": true, "tinytext": true, "text": true, "mediumtext": true, "longtext": true,
+	"enum": true, "set": true,
+}
+
+// normalizeCharset 统一字符集名，MySQL 8.0.30 起 utf8 显示为 utf8mb3
+func normalizeCharset(charset string) string {
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	if charset == "utf8" {
+		return "utf8mb3"
+	}
+	return charset
+}
+
+// normalizeCollation 统一排序规则名，如 utf8_general_ci => utf8mb3_general_ci
+func normalizeCollation(collation string) string {
+	collation = strings.ToLower(strings.TrimSpace(collation))
+	if strings.HasPrefix(collation, "utf8_") {
+		return "utf8mb3_" + strings.TrimPrefix(collation, "utf8_")
+	}
+	return collation
+}
+
+// collationCharset 排序规则对应的字符集，如 utf8mb4_general_ci => utf8mb4
+func collationCharset(collation string) string {
+	collation = normalizeCollation(collation)
+	if i := strings.Index(collation, "_"); i > 0 {
+		return collation[:i]
+	}
+	return collation
+}
+
+// charsetDefinition 字符集定义，如 CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci
+func charsetDefinition(charset, collate string) string {
+	var def []string
+	if charset != "" {
+		def = append(def, "CHARACTER SET "+charset)
+	}
+	if collate != "" {
+		def = append(def, "COLLATE "+collate)
+	}
+	return strings.Join(def, " ")
+}
+
+// ymlEngine yml中配置的存储引擎，未配置时为 DEFAULT_ENGINE
+func ymlEngine(tbl gjson.Result) string {
+	if engine := tbl.Get("options.engine").String(); engine != "" {
+		return engine
+	}
+	return DEFAULT_ENGINE
+}
+
+// sameCharset 字符集/排序规则是否与数据库中一致，优先对比排序规则，没有配置的不对比
+func sameCharset(charset, collate, sqlCharset, sqlCollate string) bool {
+	if collate != "" {
+		return normalizeCollation(collate) == normalizeCollation(sqlCollate)
+	}
+	if charset != "" {
+		return normalizeCharset(charset) == normalizeCharset(sqlCharset)
+	}
+	return true
+}
+
+// getTableOptionOps 对比表的字符集、存储引擎和行格式，converting 表示是否需要 CONVERT TO CHARACTER SET。
+// 字符集只有在yml配置了 charset/collate 时才对比，engine/row_format 同样只在配置后维护
+func getTableOptionOps(tbl gjson.Result, sqlTbl information_schema.SqlTable) (ops []PlanOperation, converting bool) {
+	tname := tbl.Get("table").String()
+	charset := tbl.Get("options.charset").String()
+	collate := tbl.Get("options.collate").String()
+	if sqlTbl.TableCollation != "" && !sameCharset(charset, collate, collationCharset(sqlTbl.TableCollation), sqlTbl.TableCollation) {
+		clause := fmt.Sprintf("CONVERT TO %s", charsetDefinition(charset, collate))
+		//CONVERT TO 会重建整张表并修改所有字符字段，单独执行，且需要在修改字段之前执行
+		ops = append(ops, PlanOperation{
+			Type:          OP_ALTER_TABLE,
+			Table:         tname,
+			Name:          "charset",
+			OldDefinition: fmt.Sprintf("CONVERT TO %s", charsetDefinition(collationCharset(sqlTbl.TableCollation), sqlTbl.TableCollation)),
+			NewDefinition: clause,
+			Reason:        "字符集/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s %s", quoteIdent(tname), clause),
+		})
+		converting = true
+	}
+
+	if engine := tbl.Get("options.engine").String(); engine != "" && sqlTbl.Engine != "" &&
+		!strings.EqualFold(engine, sqlTbl.Engine) {
+		ops = append(ops, PlanOperation{
+			Type:          OP_ALTER_TABLE,
+			Table:         tname,
+			Name:          "engine",
+			OldDefinition: fmt.Sprintf("ENGINE = %s", sqlTbl.Engine),
+			NewDefinition: fmt.Sprintf("ENGINE = %s", engine),
+			Reason:        "存储引擎/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s ENGINE = %s", quoteIdent(tname), engine),
+			Clause:        fmt.Sprintf("ENGINE = %s", engine),
+		})
+	}
+
+	//DEFAULT 对应的实际行格式取决于数据库配置，不对比
+	if rowFormat := tbl.Get("options.row_format").String(); rowFormat != "" && !strings.EqualFold(rowFormat, "default") &&
+		sqlTbl.RowFormat != "" && !strings.EqualFold(rowFormat, sqlTbl.RowFormat) {
+		ops = append(ops, PlanOperation{
+			Type:          OP_ALTER_TABLE,
+			Table:         tname,
+			Name:          "row_format",
+			OldDefinition: fmt.Sprintf("ROW_FORMAT = %s", strings.ToUpper(sqlTbl.RowFormat)),
+			NewDefinition: fmt.Sprintf("ROW_FORMAT = %s", strings.ToUpper(rowFormat)),
+			Reason:        "行格式/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s ROW_FORMAT = %s", quoteIdent(tname), strings.ToUpper(rowFormat)),
+			Clause:        fmt.Sprintf("ROW_FORMAT = %s", strings.ToUpper(rowFormat)),
+		})
+	}
+	return ops, converting
+}
+
+// columnCharsetChanged 字符字段的字符集是否需要修改。字段没有配置 charset/collate 时应与表的一致，
+// converting 时 CONVERT TO 已经统一修改为表的字符集，只需要修改单独配置了字符集的字段
+func columnCharsetChanged(tbl gjson.Result, field gjson.Result, sqlCharset, sqlCollate string, converting bool) bool {
+	if sqlCollate == "" {
+		return false
+	}
+	charset := field.Get("charset").String()
+	collate := field.Get("collate").String()
+	tableCharset := tbl.Get("options.charset").String()
+	tableCollate := tbl.Get("options.collate").String()
+	if charset == "" && collate == "" {
+		if converting {
+			return false
+		}
+		charset, collate = tableCharset, tableCollate
+	}
+	if converting {
+		sqlCharset, sqlCollate = tableCharset, tableCollate
+		if sqlCharset == "" {
+			sqlCharset = collationCharset(tableCollate)
+		}
+	}
+	return !sameCharset(charset, collate, sqlCharset, sqlCollate)
+}
+
+// checkYmlCharsets 检查表和字段的字符集、存储引擎和行格式配置
+func checkYmlCharsets(tbJson gjson.Result, report func(d Diagnostic, path string)) {
+	tname := tbJson.Get("table").String()
+	charset := tbJson.Get("options.charset").String()
+	collate := tbJson.Get("options.collate").String()
+	if charset != "" && collate != "" && collationCharset(collate) != normalizeCharset(charset) {
+		report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_OPTION, Table: tname,
+			Message: fmt.Sprintf("options.collate:'%s' 与 options.charset:'%s' 不匹配", collate, charset)}, "Table.options.collate")
+	}
+	if rowFormat := tbJson.Get("options.row_format").String(); rowFormat != "" && !rowFormats[strings.ToLower(rowFormat)] {
+		report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_OPTION, Table: tname,
+			Message: fmt.Sprintf("不支持的 options.row_format:'%s'", rowFormat)}, "Table.options.row_format")
+	}
+
+	for _, section := range []string{"id", "fields"} {
+		tbJson.Get(section).ForEach(func(key, value gjson.Result) bool {
+			path := "Table." + section + "." + key.String()
+			charset := value.Get("charset").String()
+			collate := value.Get("collate").String()
+			if charset == "" && collate == "" {
+				return true
+			}
+			if dataType := baseDataType(value.Get("type").String()); dataType != "" && !charsetTypes[dataType] {
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_FIELD, Table: tname, Field: key.String(),
+					Message: fmt.Sprintf("字段类型 %s 不能设置字符集", dataType)}, path)
+			} else if charset != "" && collate != "" && collationCharset(collate) != normalizeCharset(charset) {
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_FIELD, Table: tname, Field: key.String(),
+					Message: fmt.Sprintf("collate:'%s' 与 charset:'%s' 不匹配", collate, charset)}, path+".collate")
+			}
+			return true
+		})
+	}
+}
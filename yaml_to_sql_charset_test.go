@@ -0,0 +1,115 @@
+package dataschema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+var testCharsetTable = gjson.Parse(`{
+	"table": "article",
+	"options": {"charset": "utf8mb4", "collate": "utf8mb4_general_ci", "engine": "InnoDB", "row_format": "compressed"},
+	"fields": {
+		"title": {"type": "varchar(255)"},
+		"code": {"type": "varchar(32)", "charset": "ascii", "collate": "ascii_bin"}
+	}
+}`)
+
+func TestGetTableOptionOps(t *testing.T) {
+	ops, converting := getTableOptionOps(testCharsetTable, information_schema.SqlTable{
+		TableName: "article", TableCollation: "utf8_general_ci", Engine: "MyISAM", RowFormat: "Dynamic",
+	})
+	if !converting || len(ops) != 3 {
+		t.Fatalf("Unexpected table option ops: %+v", ops)
+	}
+	if ops[0].Sql != "ALTER TABLE `article` CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci" || ops[0].Clause != "" {
+		t.Errorf("Unexpected convert op: %+v", ops[0])
+	}
+	if ops[0].RollbackSql() != "ALTER TABLE `article` CONVERT TO CHARACTER SET utf8mb3 COLLATE utf8_general_ci" {
+		t.Errorf("Unexpected convert rollback: %s", ops[0].RollbackSql())
+	}
+	if ops[1].Clause != "ENGINE = InnoDB" || ops[2].Clause != "ROW_FORMAT = COMPRESSED" {
+		t.Errorf("Unexpected engine/row_format ops: %+v", ops[1:])
+	}
+
+	ops, converting = getTableOptionOps(testCharsetTable, information_schema.SqlTable{
+		TableName: "article", TableCollation: "utf8mb4_general_ci", Engine: "InnoDB", RowFormat: "Compressed",
+	})
+	if converting || len(ops) != 0 {
+		t.Errorf("Expected no table option ops, got %+v", ops)
+	}
+
+	//utf8 与 utf8mb3 是同一个字符集
+	ops, _ = getTableOptionOps(gjson.Parse(`{"table": "t", "options": {"charset": "utf8"}}`),
+		information_schema.SqlTable{TableName: "t", TableCollation: "utf8mb3_general_ci"})
+	if len(ops) != 0 {
+		t.Errorf("Expected utf8 to match utf8mb3, got %+v", ops)
+	}
+}
+
+func TestColumnCharsetChanged(t *testing.T) {
+	title := testCharsetTable.Get("fields.title")
+	code := testCharsetTable.Get("fields.code")
+	cases := []struct {
+		field      gjson.Result
+		charset    string
+		collate    string
+		converting bool
+		want       bool
+	}{
+		{title, "utf8mb4", "utf8mb4_general_ci", false, false},
+		{title, "utf8mb4", "utf8mb4_bin", false, true},
+		{title, "utf8mb3", "utf8mb3_general_ci", true, false},
+		{code, "ascii", "ascii_bin", false, false},
+		{code, "ascii", "ascii_bin", true, true},
+		{code, "utf8mb4", "utf8mb4_general_ci", false, true},
+		{gjson.Parse(`{"type": "int"}`), "", "", false, false},
+	}
+	for i, c := range cases {
+		if got := columnCharsetChanged(testCharsetTable, c.field, c.charset, c.collate, c.converting); got != c.want {
+			t.Errorf("case %d: expected %v, got %v", i, c.want, got)
+		}
+	}
+}
+
+func TestCharsetDefinitions(t *testing.T) {
+	def := ymlColumnDefinition("code", testCharsetTable.Get("fields.code"))
+	if def != "`code` varchar(32) CHARACTER SET ascii COLLATE ascii_bin NOT NULL COMMENT ''" {
+		t.Errorf("Unexpected column definition: %s", def)
+	}
+
+	create := NewYamlToSqlHandler().getCreateTableSql(testCharsetTable, "")
+	if !strings.Contains(create, "DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci ENGINE = InnoDB ROW_FORMAT = COMPRESSED") {
+		t.Errorf("Unexpected create table sql: %s", create)
+	}
+}
+
+func TestValidateCharsets(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.Article.yml", `Table:
+  table: article
+  options:
+    charset: utf8mb4
+    collate: latin1_swedish_ci
+    row_format: tiny
+  fields:
+    title:
+      type: varchar(255)
+      charset: ascii
+      collate: utf8mb4_bin
+    hits:
+      type: int
+      charset: ascii
+`)
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	var codes []string
+	for _, d := range ds {
+		codes = append(codes, d.Code)
+	}
+	want := []string{ERR_CODE_INVALID_OPTION, ERR_CODE_INVALID_OPTION, ERR_CODE_INVALID_FIELD, ERR_CODE_INVALID_FIELD}
+	if strings.Join(codes, ",") != strings.Join(want, ",") || ds[0].Line != 5 {
+		t.Errorf("Unexpected diagnostics: %v", ds)
+	}
+}
@@ -0,0 +1,195 @@
+package dataschema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+var charsetIntroducerRegexp = regexp.MustCompile(`(?i)_[a-z0-9]+'`)
+
+// normalizeSqlExpression 规范化表达式用于比较，MySQL 保存时会加反引号、字符集前缀和括号
+func normalizeSqlExpression(expr string) string {
+	expr = strings.ReplaceAll(expr, `\'`, `'`)
+	expr = charsetIntroducerRegexp.ReplaceAllString(expr, "'")
+	expr = strings.ReplaceAll(expr, "`", "")
+	expr = strings.Join(strings.Fields(strings.ToLower(expr)), "")
+	for len(expr) > 1 && expr[0] == '(' && expr[len(expr)-1] == ')' && balancedParentheses(expr[1:len(expr)-1]) {
+		expr = expr[1 : len(expr)-1]
+	}
+	return expr
+}
+
+// balancedParentheses 括号是否配对
+func balancedParentheses(expr string) bool {
+	depth := 0
+	for _, c := range expr {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// generatedKind 生成列类型，普通字段为空
+func generatedKind(expression string, stored bool) string {
+	if expression == "" {
+		return ""
+	}
+	if stored {
+		return "STORED"
+	}
+	return "VIRTUAL"
+}
+
+// ymlCheckExpression 检查约束的表达式，支持 name: expr 和 name: {expression: expr} 两种写法
+func ymlCheckExpression(value gjson.Result) string {
+	if value.IsObject() {
+		return value.Get("expression").String()
+	}
+	return value.String()
+}
+
+// ymlCheckEnforced 检查约束是否生效，默认生效
+func ymlCheckEnforced(value gjson.Result) bool {
+	if value.IsObject() && value.Get("enforced").Exists() {
+		return value.Get("enforced").Bool()
+	}
+	return true
+}
+
+// checkDefinition 检查约束定义，建表和 ALTER TABLE ADD 通用
+func checkDefinition(name, expression string, enforced bool) string {
+	def := fmt.Sprintf("CONSTRAINT %s CHECK (%s)", quoteIdent(name), expression)
+	if !enforced {
+		def += " NOT ENFORCED"
+	}
+	return def
+}
+
+// checkYmlChecks 检查检查约束和生成列的配置
+func checkYmlChecks(tbJson gjson.Result, report func(d Diagnostic, path string)) {
+	tname := tbJson.Get("table").String()
+	tbJson.Get("checks").ForEach(func(key, value gjson.Result) bool {
+		path := "Table.checks." + key.String()
+		if isShardedTable(tbJson) {
+			report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_CHECK, Table: tname, Index: key.String(),
+				Message: "分表不支持检查约束，约束名在库中必须唯一"}, path)
+			return true
+		}
+		if strings.TrimSpace(ymlCheckExpression(value)) == "" {
+			report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_CHECK, Table: tname, Index: key.String(),
+				Message: "缺少检查约束的表达式"}, path)
+		}
+		return true
+	})
+
+	for _, section := range []string{"id", "fields"} {
+		tbJson.Get(section).ForEach(func(key, value gjson.Result) bool {
+			path := "Table." + section + "." + key.String()
+			if !value.IsObject() {
+				return true
+			}
+			if value.Get("expression").String() == "" {
+				if value.Get("stored").Exists() {
+					report(Diagnostic{Severity: SEVERITY_WARNING, Code: ERR_CODE_INVALID_FIELD, Table: tname, Field: key.String(),
+						Message: "stored 只对配置了 expression 的生成列有效"}, path+".stored")
+				}
+				return true
+			}
+			for _, option := range []string{"default", "generator"} {
+				if value.Get(option).Exists() {
+					report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_FIELD, Table: tname, Field: key.String(),
+						Message: fmt.Sprintf("生成列不能配置 %s", option)}, path+"."+option)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// getCheckOps 对比数据库中的检查约束，yml中配置了 checks 时才维护，
+// 返回需要先删除的约束和最后添加的约束，修改约束时先删除再添加
+func (ts *YamlToSqlHandler) getCheckOps(tbl gjson.Result, sqlTbl information_schema.SqlTable) (drops, adds []PlanOperation, err error) {
+	if !tbl.Get("checks").Exists() {
+		return
+	}
+	tname := tbl.Get("table").String()
+
+	var sqlChecks []information_schema.SqlCheckConstraint
+	err = ts.db.Raw(`SELECT t.CONSTRAINT_NAME, c.CHECK_CLAUSE, t.ENFORCED
+		FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS t
+		JOIN INFORMATION_SCHEMA.CHECK_CONSTRAINTS c
+		ON c.CONSTRAINT_SCHEMA = t.CONSTRAINT_SCHEMA AND c.CONSTRAINT_NAME = t.CONSTRAINT_NAME
+		WHERE t.TABLE_SCHEMA = ? AND t.TABLE_NAME = ? AND t.CONSTRAINT_TYPE = 'CHECK'`, ts.schema, sqlTbl.TableName).Scan(&sqlChecks).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(sqlChecks, func(i, j int) bool { return sqlChecks[i].ConstraintName < sqlChecks[j].ConstraintName })
+
+	existing := map[string]bool{}
+	for _, sc := range sqlChecks {
+		existing[sc.ConstraintName] = true
+		enforced := strings.ToUpper(sc.Enforced) != "NO"
+		oldDefinition := checkDefinition(sc.ConstraintName, sc.CheckClause, enforced)
+		drop := PlanOperation{
+			Type:          OP_DROP_CHECK,
+			Table:         tname,
+			Name:          sc.ConstraintName,
+			OldDefinition: oldDefinition,
+			Reason:        "配置中已不存在/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s DROP CHECK %s", quoteIdent(tname), quoteIdent(sc.ConstraintName)),
+			Clause:        fmt.Sprintf("DROP CHECK %s", quoteIdent(sc.ConstraintName)),
+		}
+		ymlCheck := tbl.Get("checks." + sc.ConstraintName)
+		if !ymlCheck.Exists() {
+			drops = append(drops, drop)
+			continue
+		}
+		if normalizeSqlExpression(ymlCheckExpression(ymlCheck)) == normalizeSqlExpression(sc.CheckClause) &&
+			ymlCheckEnforced(ymlCheck) == enforced {
+			continue
+		}
+		newDefinition := checkDefinition(sc.ConstraintName, ymlCheckExpression(ymlCheck), ymlCheckEnforced(ymlCheck))
+		drop.NewDefinition = newDefinition
+		drop.Reason = "检查约束/"
+		drops = append(drops, drop)
+		// 同名约束不能在同一条 ALTER TABLE 中先删后加，单独执行
+		adds = append(adds, PlanOperation{
+			Type:          OP_ADD_CHECK,
+			Table:         tname,
+			Name:          sc.ConstraintName,
+			OldDefinition: oldDefinition,
+			NewDefinition: newDefinition,
+			Reason:        "检查约束/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdent(tname), newDefinition),
+		})
+	}
+
+	tbl.Get("checks").ForEach(func(key, value gjson.Result) bool {
+		if existing[key.String()] {
+			return true
+		}
+		newDefinition := checkDefinition(key.String(), ymlCheckExpression(value), ymlCheckEnforced(value))
+		adds = append(adds, PlanOperation{
+			Type:          OP_ADD_CHECK,
+			Table:         tname,
+			Name:          key.String(),
+			NewDefinition: newDefinition,
+			Sql:           fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdent(tname), newDefinition),
+			Clause:        fmt.Sprintf("ADD %s", newDefinition),
+		})
+		return true
+	})
+	return
+}
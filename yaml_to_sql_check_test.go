@@ -0,0 +1,89 @@
+package dataschema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+func TestNormalizeSqlExpression(t *testing.T) {
+	cases := [][2]string{
+		{"(`age` >= 0)", "age >= 0"},
+		{"concat(`first_name`,_utf8mb4\\' \\',`last_name`)", "CONCAT(first_name, ' ', last_name)"},
+		{"((`a` > 0) and (`b` > 0))", "(a > 0) AND (b > 0)"},
+	}
+	for _, c := range cases {
+		if normalizeSqlExpression(c[0]) != normalizeSqlExpression(c[1]) {
+			t.Errorf("Expected %q and %q to be equal, got %q and %q", c[0], c[1], normalizeSqlExpression(c[0]), normalizeSqlExpression(c[1]))
+		}
+	}
+	if normalizeSqlExpression("(a > 0) and (b > 0)") == normalizeSqlExpression("a > 0) and (b > 0") {
+		t.Errorf("Expected unbalanced outer parentheses to be kept")
+	}
+}
+
+func TestGeneratedColumnDefinition(t *testing.T) {
+	field := gjson.Parse(`{"type": "varchar", "expression": "concat(first_name, ' ', last_name)", "stored": true, "comment": "姓名"}`)
+	want := "`full_name` varchar(255) GENERATED ALWAYS AS (concat(first_name, ' ', last_name)) STORED NOT NULL COMMENT '姓名'"
+	if got := ymlColumnDefinition("full_name", field); got != want {
+		t.Errorf("Unexpected definition:\n%s\nwant:\n%s", got, want)
+	}
+
+	sc := information_schema.SqlTableColumns{ColumnName: "full_name", ColumnType: "varchar(255)", IsNullable: "YES",
+		Extra: "VIRTUAL GENERATED", GenerationExpression: "concat(`first_name`,_utf8mb4\\' \\',`last_name`)"}
+	want = "`full_name` varchar(255) GENERATED ALWAYS AS (concat(`first_name`,_utf8mb4\\' \\',`last_name`)) VIRTUAL COMMENT ''"
+	if got := sqlColumnDefinition(sc); got != want {
+		t.Errorf("Unexpected sql definition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCreateTableWithChecks(t *testing.T) {
+	tbl := gjson.Parse(`{
+		"table": "user",
+		"options": {"charset": "utf8mb4", "collate": "utf8mb4_general_ci"},
+		"fields": {"age": {"type": "int"}},
+		"checks": {"chk_age": "age >= 0", "chk_age_max": {"expression": "age < 200", "enforced": false}}
+	}`)
+	tp := NewYamlToSqlHandler().getCreateTablePlan(tbl, "Entity.User.yml")
+	for _, want := range []string{"CONSTRAINT `chk_age` CHECK (age >= 0)", "CONSTRAINT `chk_age_max` CHECK (age < 200) NOT ENFORCED"} {
+		if len(tp.Operations) != 1 || !strings.Contains(tp.Operations[0].Sql, want) {
+			t.Errorf("Expected create sql to contain %q, got %+v", want, tp.Operations)
+		}
+	}
+}
+
+func TestValidateChecksAndGeneratedColumns(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.User.yml", `Table:
+  table: user
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+  fields:
+    age:
+      type: int
+      stored: true
+    age_next:
+      type: int
+      expression: age + 1
+      default: 1
+  checks:
+    chk_age:
+      enforced: false
+`)
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	var got []string
+	for _, d := range ds {
+		got = append(got, d.Severity+" "+d.Code+" "+d.Message)
+	}
+	want := []string{
+		"error invalid_check 缺少检查约束的表达式",
+		"warning invalid_field stored 只对配置了 expression 的生成列有效",
+		"error invalid_field 生成列不能配置 default",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected diagnostics:\n%s", strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,91 @@
+package dataschema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// quoteIdent 用反引号包裹表名、字段名、索引名等标识符，标识符中的反引号写两次，如 order => `order`
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// quoteIdents 多个标识符，以逗号连接
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdent(name)
+	}
+	return strings.Join(quoted, ",")
+}
+
+// showIndexesSql 查询表索引的sql，库名和表名可能是保留字或含有-等字符
+func showIndexesSql(schema string, table string) string {
+	return fmt.Sprintf("SHOW INDEXES FROM %s FROM %s", quoteIdent(table), quoteIdent(schema))
+}
+
+// quoteLiteral 单引号字符串字面量，其中的单引号写两次、反斜杠转义
+func quoteLiteral(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `''`)
+	return "'" + s + "'"
+}
+
+// commentClause 注释子句，如 COMMENT 'xxx'
+func commentClause(comment string) string {
+	return "COMMENT " + quoteLiteral(comment)
+}
+
+var defaultKeywordRegexp = regexp.MustCompile(`(?i)^(current_timestamp|now|localtime|localtimestamp)(\(\s*\d*\s*\))?$`)
+
+// isDefaultExpression 默认值是否为表达式：CURRENT_TIMESTAMP 等关键字，或用括号包裹的表达式如 (uuid())，
+// 其余的默认值都作为字符串
+func isDefaultExpression(value string) bool {
+	value = strings.TrimSpace(value)
+	if defaultKeywordRegexp.MatchString(value) {
+		return true
+	}
+	return len(value) > 1 && value[0] == '(' && value[len(value)-1] == ')' && balancedParentheses(value[1:len(value)-1])
+}
+
+// defaultClause 默认值子句，表达式原样输出，其余作为字符串转义
+func defaultClause(value string) string {
+	if isDefaultExpression(value) {
+		return "DEFAULT " + strings.TrimSpace(value)
+	}
+	return "DEFAULT " + quoteLiteral(value)
+}
+
+// sqlDefaultClause 数据库中的默认值子句，DEFAULT_GENERATED 的默认值是表达式，
+// INFORMATION_SCHEMA 中的表达式没有括号，如 uuid()；
+// MySQL 5.7 的 CURRENT_TIMESTAMP 等默认值没有 DEFAULT_GENERATED，同样按表达式处理
+func sqlDefaultClause(value string, generated bool) string {
+	if defaultKeywordRegexp.MatchString(strings.TrimSpace(value)) {
+		return "DEFAULT " + value
+	}
+	if !generated {
+		return "DEFAULT " + quoteLiteral(value)
+	}
+	if isDefaultExpression(value) {
+		return "DEFAULT " + value
+	}
+	return "DEFAULT (" + value + ")"
+}
+
+// sameDefault yml的默认值与数据库中的是否一致，表达式默认值忽略括号、大小写和空白后对比
+func sameDefault(field gjson.Result, sqlDefault gjson.Result, sqlGenerated bool) bool {
+	if !field.Get("default").Exists() {
+		return sqlDefault.String() == ""
+	}
+	if !sqlDefault.Exists() {
+		return false
+	}
+	value := field.Get("default").String()
+	if isDefaultExpression(value) {
+		return sqlGenerated && normalizeSqlExpression(value) == normalizeSqlExpression(sqlDefault.String())
+	}
+	return !sqlGenerated && value == sqlDefault.String()
+}
@@ -0,0 +1,108 @@
+package dataschema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+func TestQuoteIdentAndLiteral(t *testing.T) {
+	if got := quoteIdent("order"); got != "`order`" {
+		t.Errorf("Unexpected identifier: %s", got)
+	}
+	if got := quoteIdent("a`b"); got != "`a``b`" {
+		t.Errorf("Expected backtick to be doubled, got %s", got)
+	}
+	if got := quoteIdents([]string{"key", "order"}); got != "`key`,`order`" {
+		t.Errorf("Unexpected identifiers: %s", got)
+	}
+	if got := quoteLiteral(`user's c:\tmp`); got != `'user''s c:\\tmp'` {
+		t.Errorf("Unexpected literal: %s", got)
+	}
+}
+
+func TestShowIndexesSql(t *testing.T) {
+	if got := showIndexesSql("my-db", "order"); got != "SHOW INDEXES FROM `order` FROM `my-db`" {
+		t.Errorf("Unexpected sql: %s", got)
+	}
+}
+
+func TestDefaultClause(t *testing.T) {
+	cases := map[string]string{
+		"CURRENT_TIMESTAMP":    "DEFAULT CURRENT_TIMESTAMP",
+		"current_timestamp(3)": "DEFAULT current_timestamp(3)",
+		"(uuid())":             "DEFAULT (uuid())",
+		"3":                    "DEFAULT '3'",
+		"it's":                 "DEFAULT 'it''s'",
+		"(a) or (b)":           "DEFAULT '(a) or (b)'",
+	}
+	for value, want := range cases {
+		if got := defaultClause(value); got != want {
+			t.Errorf("defaultClause(%q) = %q, want %q", value, got, want)
+		}
+	}
+	if got := sqlDefaultClause("uuid()", true); got != "DEFAULT (uuid())" {
+		t.Errorf("Unexpected generated default: %s", got)
+	}
+	if got := sqlDefaultClause("CURRENT_TIMESTAMP", true); got != "DEFAULT CURRENT_TIMESTAMP" {
+		t.Errorf("Unexpected keyword default: %s", got)
+	}
+	for _, value := range []string{"CURRENT_TIMESTAMP", "CURRENT_TIMESTAMP(3)", "now()"} {
+		if got := sqlDefaultClause(value, false); got != "DEFAULT "+value {
+			t.Errorf("Expected MySQL 5.7 keyword default %s unquoted, got %s", value, got)
+		}
+	}
+}
+
+func TestSameDefault(t *testing.T) {
+	cases := []struct {
+		field        string
+		sqlDefault   string
+		sqlGenerated bool
+		want         bool
+	}{
+		{`{"default": "(uuid())"}`, `"uuid()"`, true, true},
+		{`{"default": "(UUID())"}`, `"uuid()"`, false, false},
+		{`{"default": "3"}`, `"3"`, false, true},
+		{`{"default": "3"}`, `"4"`, false, false},
+		{`{"default": "CURRENT_TIMESTAMP"}`, `"CURRENT_TIMESTAMP"`, true, true},
+		{`{}`, `null`, false, true},
+		{`{}`, `"3"`, false, false},
+		{`{"default": "3"}`, ``, false, false},
+	}
+	for i, c := range cases {
+		if got := sameDefault(gjson.Parse(c.field), gjson.Parse(c.sqlDefault), c.sqlGenerated); got != c.want {
+			t.Errorf("case %d: expected %v, got %v", i, c.want, got)
+		}
+	}
+}
+
+func TestQuotedColumnDefinitions(t *testing.T) {
+	field := gjson.Parse(`{"type": "varchar", "default": "it's", "comment": "user's nickname"}`)
+	want := "`order` varchar(255) NOT NULL DEFAULT 'it''s' COMMENT 'user''s nickname'"
+	if got := ymlColumnDefinition("order", field); got != want {
+		t.Errorf("Unexpected definition:\n%s\nwant:\n%s", got, want)
+	}
+
+	value := "uuid()"
+	sc := information_schema.SqlTableColumns{ColumnName: "key", ColumnType: "varchar(36)", IsNullable: "NO",
+		ColumnDefault: &value, Extra: "DEFAULT_GENERATED", ColumnComment: "it's"}
+	want = "`key` varchar(36) NOT NULL DEFAULT (uuid()) COMMENT 'it''s'"
+	if got := sqlColumnDefinition(sc); got != want {
+		t.Errorf("Unexpected sql definition:\n%s\nwant:\n%s", got, want)
+	}
+
+	create := NewYamlToSqlHandler().getCreateTableSql(gjson.Parse(`{
+		"table": "order",
+		"options": {"charset": "utf8mb4", "collate": "utf8mb4_general_ci", "comment": "user's orders"},
+		"id": {"key": {"type": "int"}},
+		"fields": {}
+	}`), "")
+	for _, want := range []string{"CREATE TABLE `order`(", "PRIMARY KEY(`key`)", "COMMENT = 'user''s orders'"} {
+		if !strings.Contains(create, want) {
+			t.Errorf("Expected create sql to contain %q, got:\n%s", want, create)
+		}
+	}
+}
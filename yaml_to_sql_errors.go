@@ -0,0 +1,166 @@
+package dataschema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 结构同步错误码，供调用方按类型处理错误
+const (
+	ERR_CODE_DB_CONNECT             = "db_connect"             // 数据库连接失败
+	ERR_CODE_READ_DIR               = "read_dir"               // 读取配置目录失败
+	ERR_CODE_READ_FILE              = "read_file"              // 读取配置文件失败
+	ERR_CODE_PARSE_FILE             = "parse_file"             // 配置文件解析失败
+	ERR_CODE_DUPLICATE_TABLE        = "duplicate_table"        // 重复定义的表
+	ERR_CODE_MISSING_TABLE          = "missing_table"          // 缺少表名
+	ERR_CODE_INVALID_TABLE          = "invalid_table"          // 表配置不正确
+	ERR_CODE_DUPLICATE_FIELD        = "duplicate_field"        // 主键字段和普通字段重名
+	ERR_CODE_INVALID_FIELD          = "invalid_field"          // 字段配置不正确
+	ERR_CODE_INVALID_OPTION         = "invalid_option"         // options 配置不正确
+	ERR_CODE_INVALID_INDEX          = "invalid_index"          // 索引配置不正确
+	ERR_CODE_INDEX_COLUMN_NOT_FOUND = "index_column_not_found" // 索引字段不存在
+	ERR_CODE_INVALID_FOREIGN_KEY    = "invalid_foreign_key"    // 外键配置不正确
+	ERR_CODE_INVALID_CHECK          = "invalid_check"          // 检查约束配置不正确
+	ERR_CODE_INVALID_PARTITION      = "invalid_partition"      // 分区配置不正确
+	ERR_CODE_INVALID_SHARDING       = "invalid_sharding"       // 分表配置不正确
+	ERR_CODE_QUERY_DB               = "query_db"               // 读取数据库结构失败
+	ERR_CODE_SERIALIZE              = "serialize"              // 序列化失败
+	ERR_CODE_BUILD_SCHEMA           = "build_schema"           // 编译产物读写失败
+	ERR_CODE_EXECUTE_SQL            = "execute_sql"            // 执行sql失败
+	ERR_CODE_WRITE_PLAN             = "write_plan"             // 迁移计划写入失败
+	ERR_CODE_HISTORY                = "history"                // 迁移历史读写失败
+	ERR_CODE_MIGRATION_STATE        = "migration_state"        // 迁移执行状态读写失败或存在未完成的迁移
+	ERR_CODE_UNSUPPORTED_VERSION    = "unsupported_version"    // 不支持的配置版本
+	ERR_CODE_UNKNOWN_FIELD          = "unknown_field"          // 配置中存在未定义的键
+)
+
+// SchemaError 结构同步过程中的错误信息
+type SchemaError struct {
+	Code    string // 错误码 ERR_CODE_*
+	Target  string // 目标库，多目标时设置
+	File    string // 配置文件路径
+	Table   string // 表名
+	Field   string // 字段名
+	Index   string // 索引名
+	Message string // 错误描述
+	Err     error  // 原始错误
+}
+
+func (e *SchemaError) Error() string {
+	var parts []string
+	parts = append(parts, fmt.Sprintf("[%s]", e.Code))
+	if e.Target != "" {
+		parts = append(parts, fmt.Sprintf("目标: %s", e.Target))
+	}
+	if e.File != "" {
+		parts = append(parts, fmt.Sprintf("文件: %s", e.File))
+	}
+	if e.Table != "" {
+		parts = append(parts, fmt.Sprintf("表: %s", e.Table))
+	}
+	if e.Field != "" {
+		parts = append(parts, fmt.Sprintf("字段: %s", e.Field))
+	}
+	if e.Index != "" {
+		parts = append(parts, fmt.Sprintf("索引: %s", e.Index))
+	}
+	if e.Message != "" {
+		parts = append(parts, e.Message)
+	}
+	if e.Err != nil {
+		parts = append(parts, e.Err.Error())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// SchemaErrors 一次同步过程中收集到的全部错误
+type SchemaErrors []*SchemaError
+
+func (es SchemaErrors) Error() string {
+	var msgs []string
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// addError 记录错误，后续步骤检测到错误后会跳过
+// 并发对比或执行时会在多个goroutine中调用
+func (ts *YamlToSqlHandler) addError(e *SchemaError) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.errs = append(ts.errs, e)
+}
+
+// addQueryError 记录读取数据库结构失败的错误，读取失败时不能当作结构为空继续对比
+func (ts *YamlToSqlHandler) addQueryError(file string, table string, message string, err error) {
+	ts.addError(&SchemaError{Code: ERR_CODE_QUERY_DB, File: file, Table: table, Message: message, Err: err})
+}
+
+// resetErrors 清空错误，每次执行开始时调用
+func (ts *YamlToSqlHandler) resetErrors() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.errs = nil
+}
+
+func (ts *YamlToSqlHandler) hasError() bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return len(ts.errs) > 0
+}
+
+// Errors 获取同步过程中收集到的错误，返回副本，并发执行时也可以安全调用
+func (ts *YamlToSqlHandler) Errors() SchemaErrors {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append(SchemaErrors(nil), ts.errs...)
+}
+
+// ErrorsByTable 按表名分组的错误，与具体表无关的错误表名为空
+func (ts *YamlToSqlHandler) ErrorsByTable() map[string]SchemaErrors {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	tables := map[string]SchemaErrors{}
+	for _, e := range ts.errs {
+		tables[e.Table] = append(tables[e.Table], e)
+	}
+	return tables
+}
+
+// tableErrors 指定表的错误
+func (ts *YamlToSqlHandler) tableErrors(table string) SchemaErrors {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	var errs SchemaErrors
+	for _, e := range ts.errs {
+		if e.Table == table {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// err 没有错误时返回nil，避免返回非空的空切片接口
+func (ts *YamlToSqlHandler) err() error {
+	if errs := ts.Errors(); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// mustNoError 兼容原有行为，有错误时打印并panic
+func (ts *YamlToSqlHandler) mustNoError() {
+	errs := ts.Errors()
+	if len(errs) == 0 {
+		return
+	}
+	for _, e := range errs {
+		fmt.Printf("\x1b[%dm %s \x1b[0m\n", 31, e.Error())
+	}
+	panic(errs)
+}
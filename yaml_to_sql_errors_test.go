@@ -0,0 +1,79 @@
+package dataschema
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+func TestExecuteSchemaEWithoutDsn(t *testing.T) {
+	yts := NewYamlToSqlHandler().SetYamlPath("./cmd/test_yaml_to_sql/etc2/")
+	err := yts.ExecuteSchemaE()
+	if err == nil {
+		t.Fatal("Expected error without dsn, got nil")
+	}
+
+	var errs SchemaErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected SchemaErrors, got %T", err)
+	}
+	if len(errs) != 1 || errs[0].Code != ERR_CODE_DB_CONNECT {
+		t.Errorf("Expected one %s error, got %v", ERR_CODE_DB_CONNECT, errs)
+	}
+}
+
+func TestSchemaErrorUnwrap(t *testing.T) {
+	cause := errors.New("cause")
+	e := &SchemaError{Code: ERR_CODE_READ_FILE, File: "a.yml", Table: "user", Err: cause}
+	if !errors.Is(e, cause) {
+		t.Error("Expected SchemaError to unwrap to cause")
+	}
+	if e.Error() != "[read_file] 文件: a.yml 表: user cause" {
+		t.Errorf("Unexpected message: %s", e.Error())
+	}
+}
+
+func TestErrorsReturnsCopy(t *testing.T) {
+	ts := NewYamlToSqlHandler()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ts.addError(&SchemaError{Code: ERR_CODE_READ_FILE})
+		}()
+		go func() {
+			defer wg.Done()
+			_ = ts.Errors()
+		}()
+	}
+	wg.Wait()
+
+	errs := ts.Errors()
+	errs[0] = nil
+	if len(ts.Errors()) != 10 || ts.Errors()[0] == nil {
+		t.Errorf("Expected Errors to return a copy, got %v", ts.Errors())
+	}
+}
+
+func TestDiffTableQueryErrors(t *testing.T) {
+	ts := NewYamlToSqlHandler().SetDB(openLazyDB(t)).SetSchema("pulingfu")
+	tbl := `{"Table": {"table": "user", "id": {"id": {"type": "int"}}, "fields": {"name": {"type": "varchar"}},
+		"foreign_keys": {"fk_user_org": {"columns": ["org_id"], "references": {"table": "org", "columns": ["id"]}}}}}`
+	if tp := ts.diffTable(tbl, "Entity.User.yml"); tp != nil {
+		t.Errorf("Expected no plan when the table query fails, got %+v", tp)
+	}
+
+	tp := ts.getChangeTablePlan(gjson.Get(tbl, "Table"), information_schema.SqlTable{TableName: "user"}, "Entity.User.yml")
+	if len(tp.Operations) != 0 {
+		t.Errorf("Expected no operations when the foreign key query fails, got %+v", tp.Operations)
+	}
+
+	errs := ts.Errors()
+	if len(errs) != 2 || errs[0].Code != ERR_CODE_QUERY_DB || errs[1].Code != ERR_CODE_QUERY_DB || errs[1].Message != "读取外键失败" {
+		t.Errorf("Expected query errors, got %v", errs)
+	}
+}
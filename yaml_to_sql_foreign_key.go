@@ -0,0 +1,236 @@
+package dataschema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+// foreignKeyRules 外键 ON DELETE/ON UPDATE 允许的取值
+var foreignKeyRules = map[string]bool{"RESTRICT": true, "CASCADE": true, "SET NULL": true, "NO ACTION": true, "SET DEFAULT": true}
+
+// normalizeForeignKeyRule InnoDB 中 NO ACTION 与 RESTRICT 等价，未配置时也按 RESTRICT 处理
+func normalizeForeignKeyRule(rule string) string {
+	rule = strings.ToUpper(strings.Join(strings.Fields(rule), " "))
+	if rule == "" || rule == "NO ACTION" {
+		return "RESTRICT"
+	}
+	return rule
+}
+
+// foreignKeyDefinition 外键定义，建表和 ALTER TABLE ADD 通用
+func foreignKeyDefinition(name string, columns []string, refTable string, refColumns []string, onDelete, onUpdate string) string {
+	return fmt.Sprintf("CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s) ON DELETE %s ON UPDATE %s",
+		quoteIdent(name), quoteIdents(columns), quoteIdent(refTable), quoteIdents(refColumns),
+		normalizeForeignKeyRule(onDelete), normalizeForeignKeyRule(onUpdate))
+}
+
+// ymlForeignKeyDefinition yml中外键配置对应的定义
+func ymlForeignKeyDefinition(name string, fk gjson.Result) string {
+	return foreignKeyDefinition(name, gjsonStrings(fk.Get("columns")),
+		fk.Get("references.table").String(), gjsonStrings(fk.Get("references.columns")),
+		fk.Get("on_delete").String(), fk.Get("on_update").String())
+}
+
+// ymlForeignKeyTables 外键引用的其他表
+func ymlForeignKeyTables(tbl gjson.Result) []string {
+	var tables []string
+	tbl.Get("foreign_keys").ForEach(func(key, value gjson.Result) bool {
+		if ref := value.Get("references.table").String(); ref != "" && ref != tbl.Get("table").String() {
+			tables = append(tables, ref)
+		}
+		return true
+	})
+	return tables
+}
+
+// foreignKeyOrder 按外键依赖排序，被引用的表在前，循环引用时保持原有顺序
+func foreignKeyOrder(tables []gjson.Result) []int {
+	indexes := map[string]int{}
+	for i, tbl := range tables {
+		indexes[tbl.Get("table").String()] = i
+	}
+	var order []int
+	state := make([]int, len(tables)) // 0 未访问 1 访问中 2 已完成
+	var visit func(i int)
+	visit = func(i int) {
+		if state[i] != 0 {
+			return
+		}
+		state[i] = 1
+		for _, ref := range ymlForeignKeyTables(tables[i]) {
+			if j, ok := indexes[ref]; ok {
+				visit(j)
+			}
+		}
+		state[i] = 2
+		order = append(order, i)
+	}
+	for i := range tables {
+		visit(i)
+	}
+	return order
+}
+
+// checkYmlForeignKeys 检查单张表的外键配置
+func checkYmlForeignKeys(tbJson gjson.Result, report func(d Diagnostic, path string)) {
+	tname := tbJson.Get("table").String()
+	tbJson.Get("foreign_keys").ForEach(func(key, value gjson.Result) bool {
+		path := "Table.foreign_keys." + key.String()
+		fkError := func(message, subPath string) {
+			report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_FOREIGN_KEY, Table: tname, Index: key.String(),
+				Message: message}, path+subPath)
+		}
+		if isShardedTable(tbJson) {
+			fkError("分表不支持外键，外键名在库中必须唯一", "")
+			return true
+		}
+		columns := value.Get("columns")
+		if !columns.IsArray() || len(columns.Array()) < 1 {
+			fkError(fmt.Sprintf("foreign_keys:'%s' columns 必须是数组", key.String()), "")
+			return true
+		}
+		for i, v := range columns.Array() {
+			if !tbJson.Get("fields."+v.String()).Exists() && !tbJson.Get("id."+v.String()).Exists() {
+				fkError(fmt.Sprintf("foreign_keys columns:'%s' is not find", v.String()), fmt.Sprintf(".columns.%d", i))
+			}
+		}
+		if value.Get("references.table").String() == "" {
+			fkError("缺少引用的表 references.table", "")
+		}
+		refColumns := value.Get("references.columns")
+		if !refColumns.IsArray() || len(refColumns.Array()) != len(columns.Array()) {
+			fkError("references.columns 与 columns 的数量不一致", ".references")
+		}
+		for _, option := range []string{"on_delete", "on_update"} {
+			rule := value.Get(option).String()
+			if rule != "" && !foreignKeyRules[strings.ToUpper(strings.Join(strings.Fields(rule), " "))] {
+				fkError(fmt.Sprintf("不支持的 %s:'%s'", option, rule), "."+option)
+			}
+		}
+		return true
+	})
+}
+
+// checkYmlForeignKeyReferences 检查外键引用的表和字段是否在yml中定义
+func checkYmlForeignKeyReferences(tbJson gjson.Result, tables map[string]gjson.Result, report func(d Diagnostic, path string)) {
+	tname := tbJson.Get("table").String()
+	tbJson.Get("foreign_keys").ForEach(func(key, value gjson.Result) bool {
+		path := "Table.foreign_keys." + key.String() + ".references"
+		refName := value.Get("references.table").String()
+		if refName == "" {
+			return true
+		}
+		ref, ok := tables[refName]
+		if refName == tname {
+			ref, ok = tbJson, true
+		}
+		if !ok {
+			report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_FOREIGN_KEY, Table: tname, Index: key.String(),
+				Message: fmt.Sprintf("外键引用的表:'%s' 不在配置中", refName)}, path+".table")
+			return true
+		}
+		if isShardedTable(ref) {
+			report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_FOREIGN_KEY, Table: tname, Index: key.String(),
+				Message: fmt.Sprintf("外键不能引用分表:'%s'", refName)}, path+".table")
+			return true
+		}
+		for i, v := range value.Get("references.columns").Array() {
+			if !ref.Get("fields."+v.String()).Exists() && !ref.Get("id."+v.String()).Exists() {
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_FOREIGN_KEY, Table: tname, Index: key.String(), Field: v.String(),
+					Message: fmt.Sprintf("外键引用的字段:'%s.%s' 不存在", refName, v.String())}, fmt.Sprintf("%s.columns.%d", path, i))
+			}
+		}
+		return true
+	})
+}
+
+// getForeignKeyOps 对比数据库中的外键，yml中配置了 foreign_keys 时才维护，
+// 返回需要先删除的外键和最后添加的外键，修改外键时先删除再添加
+func (ts *YamlToSqlHandler) getForeignKeyOps(tbl gjson.Result, sqlTbl information_schema.SqlTable) (drops, adds []PlanOperation, err error) {
+	if !tbl.Get("foreign_keys").Exists() {
+		return
+	}
+	tname := tbl.Get("table").String()
+
+	var sqlForeignKeys []information_schema.SqlForeignKey
+	err = ts.db.Raw(`SELECT k.CONSTRAINT_NAME, k.COLUMN_NAME, k.ORDINAL_POSITION, k.REFERENCED_TABLE_NAME,
+		k.REFERENCED_COLUMN_NAME, r.UPDATE_RULE, r.DELETE_RULE
+		FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE k
+		JOIN INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS r
+		ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME AND r.TABLE_NAME = k.TABLE_NAME
+		WHERE k.TABLE_SCHEMA = ? AND k.TABLE_NAME = ? AND k.REFERENCED_TABLE_NAME IS NOT NULL
+		ORDER BY k.CONSTRAINT_NAME, k.ORDINAL_POSITION`, ts.schema, sqlTbl.TableName).Scan(&sqlForeignKeys).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sqlDefinitions := map[string]string{}
+	var names []string
+	for i := 0; i < len(sqlForeignKeys); {
+		fk := sqlForeignKeys[i]
+		var columns, refColumns []string
+		for ; i < len(sqlForeignKeys) && sqlForeignKeys[i].ConstraintName == fk.ConstraintName; i++ {
+			columns = append(columns, sqlForeignKeys[i].ColumnName)
+			refColumns = append(refColumns, sqlForeignKeys[i].ReferencedColumnName)
+		}
+		names = append(names, fk.ConstraintName)
+		sqlDefinitions[fk.ConstraintName] = foreignKeyDefinition(fk.ConstraintName, columns,
+			fk.ReferencedTableName, refColumns, fk.DeleteRule, fk.UpdateRule)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		ymlForeignKey := tbl.Get("foreign_keys." + name)
+		drop := PlanOperation{
+			Type:          OP_DROP_FOREIGN_KEY,
+			Table:         tname,
+			Name:          name,
+			OldDefinition: sqlDefinitions[name],
+			Reason:        "配置中已不存在/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", quoteIdent(tname), quoteIdent(name)),
+			Clause:        fmt.Sprintf("DROP FOREIGN KEY %s", quoteIdent(name)),
+		}
+		if !ymlForeignKey.Exists() {
+			drops = append(drops, drop)
+			continue
+		}
+		newDefinition := ymlForeignKeyDefinition(name, ymlForeignKey)
+		if newDefinition == sqlDefinitions[name] {
+			continue
+		}
+		drop.NewDefinition = newDefinition
+		drop.Reason = "外键/"
+		drops = append(drops, drop)
+		// 同名外键不能在同一条 ALTER TABLE 中先删后加，单独执行
+		adds = append(adds, PlanOperation{
+			Type:          OP_ADD_FOREIGN_KEY,
+			Table:         tname,
+			Name:          name,
+			OldDefinition: sqlDefinitions[name],
+			NewDefinition: newDefinition,
+			Reason:        "外键/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdent(tname), newDefinition),
+		})
+	}
+
+	tbl.Get("foreign_keys").ForEach(func(key, value gjson.Result) bool {
+		if _, ok := sqlDefinitions[key.String()]; ok {
+			return true
+		}
+		newDefinition := ymlForeignKeyDefinition(key.String(), value)
+		adds = append(adds, PlanOperation{
+			Type:          OP_ADD_FOREIGN_KEY,
+			Table:         tname,
+			Name:          key.String(),
+			NewDefinition: newDefinition,
+			Sql:           fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdent(tname), newDefinition),
+			Clause:        fmt.Sprintf("ADD %s", newDefinition),
+		})
+		return true
+	})
+	return
+}
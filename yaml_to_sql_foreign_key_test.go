@@ -0,0 +1,111 @@
+package dataschema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestForeignKeyDefinition(t *testing.T) {
+	fk := gjson.Parse(`{"columns": ["user_id"], "references": {"table": "user", "columns": ["id"]}, "on_delete": "set  null"}`)
+	want := "CONSTRAINT `fk_order_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE SET NULL ON UPDATE RESTRICT"
+	if got := ymlForeignKeyDefinition("fk_order_user", fk); got != want {
+		t.Errorf("Unexpected definition:\n%s\nwant:\n%s", got, want)
+	}
+	// 数据库中的 NO ACTION 与未配置等价
+	if got := foreignKeyDefinition("fk_order_user", []string{"user_id"}, "user", []string{"id"}, "SET NULL", "NO ACTION"); got != want {
+		t.Errorf("Expected NO ACTION to match RESTRICT, got %s", got)
+	}
+}
+
+func TestForeignKeyOrder(t *testing.T) {
+	tables := []gjson.Result{
+		gjson.Parse(`{"table": "order_item", "foreign_keys": {"fk_item_order": {"references": {"table": "order"}}}}`),
+		gjson.Parse(`{"table": "order", "foreign_keys": {"fk_order_user": {"references": {"table": "user"}}}}`),
+		gjson.Parse(`{"table": "user", "foreign_keys": {"fk_user_parent": {"references": {"table": "user"}}}}`),
+		gjson.Parse(`{"table": "a", "foreign_keys": {"fk_a_b": {"references": {"table": "b"}}}}`),
+		gjson.Parse(`{"table": "b", "foreign_keys": {"fk_b_a": {"references": {"table": "a"}}}}`),
+	}
+	if got := foreignKeyOrder(tables); !reflect.DeepEqual(got, []int{2, 1, 0, 4, 3}) {
+		t.Errorf("Unexpected order: %v", got)
+	}
+}
+
+func TestValidateForeignKeys(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.Order.yml", `Table:
+  table: order
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+  id:
+    id:
+      type: integer unsigned
+  fields:
+    user_id:
+      type: integer unsigned
+  foreign_keys:
+    fk_order_user:
+      columns: [user_id]
+      references:
+        table: user
+        columns: [uid]
+      on_delete: cascade
+    fk_order_shop:
+      columns: [user_id]
+      references:
+        table: shop
+        columns: [id]
+      on_update: drop
+`)
+	writeTestYaml(t, dir, "Entity.User.yml", `Table:
+  table: user
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+  id:
+    id:
+      type: integer unsigned
+`)
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	var messages []string
+	for _, d := range ds {
+		if d.Code != ERR_CODE_INVALID_FOREIGN_KEY {
+			t.Errorf("Unexpected diagnostic: %v", d)
+		}
+		messages = append(messages, d.Message)
+	}
+	got := strings.Join(messages, "\n")
+	for _, want := range []string{"不支持的 on_update:'drop'", "外键引用的字段:'user.uid' 不存在", "外键引用的表:'shop' 不在配置中"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected diagnostic %q, got:\n%s", want, got)
+		}
+	}
+	if len(ds) != 3 {
+		t.Errorf("Expected 3 diagnostics, got %v", ds)
+	}
+}
+
+func TestCreateTableWithForeignKey(t *testing.T) {
+	tbl := gjson.Parse(`{
+		"table": "order",
+		"options": {"charset": "utf8mb4", "collate": "utf8mb4_general_ci"},
+		"fields": {"id": {"type": "int"}, "user_id": {"type": "int"}},
+		"foreign_keys": {"fk_order_user": {"columns": ["user_id"], "references": {"table": "user", "columns": ["id"]}, "on_delete": "cascade"}}
+	}`)
+	tp := NewYamlToSqlHandler().getCreateTablePlan(tbl, "Entity.Order.yml")
+	want := "CONSTRAINT `fk_order_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`) ON DELETE CASCADE ON UPDATE RESTRICT"
+	if len(tp.Operations) != 1 || !strings.Contains(tp.Operations[0].Sql, want) {
+		t.Errorf("Expected create sql to contain %q, got %+v", want, tp.Operations)
+	}
+
+	add := PlanOperation{Type: OP_ADD_FOREIGN_KEY, Table: "order", Name: "fk_order_user", NewDefinition: want}
+	if got := add.RollbackSql(); got != "ALTER TABLE `order` DROP FOREIGN KEY `fk_order_user`" {
+		t.Errorf("Unexpected rollback: %s", got)
+	}
+	if !add.IsAdditive() {
+		t.Errorf("Expected new foreign key to be additive")
+	}
+}
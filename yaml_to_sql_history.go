@@ -0,0 +1,290 @@
+package dataschema
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/k-kkong/dataschema/information_schema"
+)
+
+// 默认的迁移历史表名
+const DEFAULT_HISTORY_TABLE = "dataschema_migrations"
+
+// MigrationRecord 迁移历史记录
+type MigrationRecord struct {
+	Id         int64     `gorm:"column:id;primaryKey"`
+	Checksum   string    `gorm:"column:checksum"`    // 编译后的yml结构的校验和
+	DbSnapshot string    `gorm:"column:db_snapshot"` // 执行后数据库中每张表结构的校验和，json
+	Statements string    `gorm:"column:statements"`  // 执行的sql
+	Rollback   string    `gorm:"column:rollback"`    // 对应的回滚sql
+	Executor   string    `gorm:"column:executor"`    // 执行者
+	StartedAt  time.Time `gorm:"column:started_at"`  // 开始时间
+	DurationMs int64     `gorm:"column:duration_ms"` // 耗时(毫秒)
+	Success    bool      `gorm:"column:success"`     // 是否成功
+	Error      string    `gorm:"column:error"`       // 失败原因
+}
+
+// DriftReport 数据库结构漂移检测结果
+type DriftReport struct {
+	LastRecord    *MigrationRecord // 最近一次成功且有结构快照的迁移记录，没有时为nil
+	ChangedTables []string         // 结构被修改的表
+	AddedTables   []string         // 新出现的表
+	RemovedTables []string         // 被删除的表
+}
+
+// HasDrift 是否存在手动修改
+func (r *DriftReport) HasDrift() bool {
+	return len(r.ChangedTables) > 0 || len(r.AddedTables) > 0 || len(r.RemovedTables) > 0
+}
+
+// SetHistoryTable 设置迁移历史表名，默认为 dataschema_migrations
+func (ts *YamlToSqlHandler) SetHistoryTable(table string) *YamlToSqlHandler {
+	ts.historyTable = table
+	return ts
+}
+
+// SetRecordHistory 设置执行迁移时是否记录历史，默认记录
+func (ts *YamlToSqlHandler) SetRecordHistory(record bool) *YamlToSqlHandler {
+	ts.recordHistory = record
+	return ts
+}
+
+// SetExecutor 设置记录在历史中的执行者，默认为 用户@主机名
+func (ts *YamlToSqlHandler) SetExecutor(executor string) *YamlToSqlHandler {
+	ts.executor = executor
+	return ts
+}
+
+// History 获取最近的迁移历史，limit<=0 时返回全部，历史表不存在时返回空
+func (ts *YamlToSqlHandler) History(limit int) ([]MigrationRecord, error) {
+	ts.connectSql()
+	if err := ts.err(); err != nil {
+		return nil, err
+	}
+	exists, err := ts.historyTableExists()
+	if err != nil || !exists {
+		return nil, err
+	}
+	var records []MigrationRecord
+	qr := ts.db.Table(ts.historyTable).Order("id desc")
+	if limit > 0 {
+		qr = qr.Limit(limit)
+	}
+	if err := qr.Find(&records).Error; err != nil {
+		return nil, &SchemaError{Code: ERR_CODE_HISTORY, Table: ts.historyTable, Message: "读取迁移历史失败", Err: err}
+	}
+	return records, nil
+}
+
+// DetectDrift 对比最近一次成功迁移后的数据库结构，检测是否有人手动修改了数据库，
+// 没有迁移历史时返回空的结果
+func (ts *YamlToSqlHandler) DetectDrift() (*DriftReport, error) {
+	records, err := ts.History(0)
+	if err != nil {
+		return nil, err
+	}
+	//快照读取失败的记录没有快照，不能作为对比的基准
+	var lastRecord *MigrationRecord
+	for i := range records {
+		if records[i].Success && records[i].DbSnapshot != "" {
+			lastRecord = &records[i]
+			break
+		}
+	}
+	if lastRecord == nil {
+		return &DriftReport{}, nil
+	}
+
+	last := map[string]string{}
+	if err := json.Unmarshal([]byte(lastRecord.DbSnapshot), &last); err != nil {
+		return nil, &SchemaError{Code: ERR_CODE_HISTORY, Table: ts.historyTable, Message: "迁移历史中的结构快照不正确", Err: err}
+	}
+	current, err := ts.dbSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	report := compareSnapshots(last, current)
+	report.LastRecord = lastRecord
+	return report, nil
+}
+
+// compareSnapshots 对比两次结构快照，不依赖数据库连接
+func compareSnapshots(last map[string]string, current map[string]string) *DriftReport {
+	report := &DriftReport{}
+	for tname, sum := range current {
+		lastSum, ok := last[tname]
+		if !ok {
+			report.AddedTables = append(report.AddedTables, tname)
+		} else if lastSum != sum {
+			report.ChangedTables = append(report.ChangedTables, tname)
+		}
+	}
+	for tname := range last {
+		if _, ok := current[tname]; !ok {
+			report.RemovedTables = append(report.RemovedTables, tname)
+		}
+	}
+	sort.Strings(report.ChangedTables)
+	sort.Strings(report.AddedTables)
+	sort.Strings(report.RemovedTables)
+	return report
+}
+
+// historyTableExists 历史表是否存在，只读查询时用它代替 ensureHistoryTable，避免修改数据库
+func (ts *YamlToSqlHandler) historyTableExists() (bool, error) {
+	var count int64
+	err := ts.db.Table("INFORMATION_SCHEMA.TABLES").
+		Where("TABLE_SCHEMA=?", ts.schema).
+		Where("TABLE_NAME=?", ts.historyTable).
+		Count(&count).Error
+	if err != nil {
+		return false, &SchemaError{Code: ERR_CODE_HISTORY, Table: ts.historyTable, Message: "查询迁移历史表失败", Err: err}
+	}
+	return count > 0, nil
+}
+
+// ensureHistoryTable 历史表不存在时创建
+func (ts *YamlToSqlHandler) ensureHistoryTable() error {
+	sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+	id bigint unsigned NOT NULL AUTO_INCREMENT,
+	checksum varchar(64) NOT NULL DEFAULT '' COMMENT '编译后的yml结构的校验和',
+	db_snapshot longtext COMMENT '执行后数据库中每张表结构的校验和',
+	statements longtext COMMENT '执行的sql',
+	rollback longtext COMMENT '对应的回滚sql',
+	executor varchar(255) NOT NULL DEFAULT '' COMMENT '执行者',
+	started_at datetime NOT NULL COMMENT '开始时间',
+	duration_ms bigint NOT NULL DEFAULT 0 COMMENT '耗时(毫秒)',
+	success tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否成功',
+	error text COMMENT '失败原因',
+	PRIMARY KEY(id)
+) DEFAULT CHARACTER SET utf8mb4 ENGINE = InnoDB COMMENT = 'dataschema迁移历史'`, quoteIdent(ts.historyTable))
+	if err := ts.db.Exec(sql).Error; err != nil {
+		return &SchemaError{Code: ERR_CODE_HISTORY, Table: ts.historyTable, Message: "创建迁移历史表失败", Err: err}
+	}
+	return nil
+}
+
+// saveHistory 记录一次迁移的执行结果
+func (ts *YamlToSqlHandler) saveHistory(startedAt time.Time, statements []string, execErr error) {
+	if !ts.recordHistory {
+		return
+	}
+	if err := ts.ensureHistoryTable(); err != nil {
+		ts.addError(err.(*SchemaError))
+		return
+	}
+
+	//快照读取失败时仍记录本次迁移，但不保存快照，避免之后与空结构对比误报漂移
+	snapshot, err := ts.dbSnapshot()
+	if err != nil {
+		ts.addError(err.(*SchemaError))
+	}
+	record := newMigrationRecord(ts.run, snapshot, statements, ts.getExecutor(), startedAt, execErr)
+	if err := ts.db.Table(ts.historyTable).Create(&record).Error; err != nil {
+		ts.addError(&SchemaError{Code: ERR_CODE_HISTORY, Table: ts.historyTable, Message: "写入迁移历史失败", Err: err})
+	}
+}
+
+// newMigrationRecord 生成一次迁移的历史记录，snapshot 为nil时不保存快照
+func newMigrationRecord(run *MigrationRun, snapshot map[string]string, statements []string, executor string,
+	startedAt time.Time, execErr error) MigrationRecord {
+	record := MigrationRecord{
+		Checksum:   run.Checksum,
+		Statements: strings.Join(statements, ";\n"),
+		Rollback:   strings.Join(run.Rollback, ";\n"),
+		Executor:   executor,
+		StartedAt:  startedAt,
+		DurationMs: time.Since(startedAt).Milliseconds(),
+		Success:    execErr == nil,
+	}
+	if snapshot != nil {
+		content, _ := json.Marshal(snapshot)
+		record.DbSnapshot = string(content)
+	}
+	if execErr != nil {
+		record.Error = execErr.Error()
+	}
+	return record
+}
+
+// schemaChecksum 编译后的yml结构的校验和
+func (ts *YamlToSqlHandler) schemaChecksum() string {
+	tables := append([]string{}, ts.tables...)
+	sort.Strings(tables)
+	return checksum(strings.Join(tables, "\n"))
+}
+
+// dbSnapshot 计算数据库中每张表(历史表除外)的结构校验和
+func (ts *YamlToSqlHandler) dbSnapshot() (map[string]string, error) {
+	snapshotError := func(err error) error {
+		return &SchemaError{Code: ERR_CODE_HISTORY, Table: ts.historyTable, Message: "读取数据库结构快照失败", Err: err}
+	}
+	var sqlTables []information_schema.SqlTable
+	err := ts.db.Table("INFORMATION_SCHEMA.TABLES").
+		Where("TABLE_SCHEMA=?", ts.schema).
+		Where("TABLE_NAME<>?", ts.historyTable).
+		Find(&sqlTables).Error
+	if err != nil {
+		return nil, snapshotError(err)
+	}
+	var sqlColumns []information_schema.SqlTableColumns
+	err = ts.db.Table("INFORMATION_SCHEMA.COLUMNS").
+		Where("TABLE_SCHEMA=?", ts.schema).
+		Where("TABLE_NAME<>?", ts.historyTable).
+		Order("TABLE_NAME,ORDINAL_POSITION").
+		Find(&sqlColumns).Error
+	if err != nil {
+		return nil, snapshotError(err)
+	}
+	var sqlStatistics []information_schema.SqlStatistics
+	err = ts.db.Table("INFORMATION_SCHEMA.STATISTICS").
+		Where("TABLE_SCHEMA=?", ts.schema).
+		Where("TABLE_NAME<>?", ts.historyTable).
+		Order("TABLE_NAME,INDEX_NAME,SEQ_IN_INDEX").
+		Find(&sqlStatistics).Error
+	if err != nil {
+		return nil, snapshotError(err)
+	}
+	return schemaSnapshot(sqlTables, sqlColumns, sqlStatistics), nil
+}
+
+// schemaSnapshot 按表计算结构校验和，不依赖数据库连接
+func schemaSnapshot(sqlTables []information_schema.SqlTable, sqlColumns []information_schema.SqlTableColumns,
+	sqlStatistics []information_schema.SqlStatistics) map[string]string {
+	lines := map[string][]string{}
+	for _, t := range sqlTables {
+		lines[t.TableName] = append(lines[t.TableName], fmt.Sprintf("comment:%s", t.TableComment))
+	}
+	for _, c := range sqlColumns {
+		lines[c.TableName] = append(lines[c.TableName], fmt.Sprintf("column:%s", sqlColumnDefinition(c)))
+	}
+	for _, s := range sqlStatistics {
+		lines[s.TableName] = append(lines[s.TableName],
+			fmt.Sprintf("index:%s:%d:%s:%s", s.IndexName, s.NonUnique, s.IndexType, s.ColumnName))
+	}
+
+	snapshot := map[string]string{}
+	for tname, ls := range lines {
+		snapshot[tname] = checksum(strings.Join(ls, "\n"))
+	}
+	return snapshot
+}
+
+func (ts *YamlToSqlHandler) getExecutor() string {
+	if ts.executor != "" {
+		return ts.executor
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s", os.Getenv("USER"), host)
+}
+
+func checksum(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
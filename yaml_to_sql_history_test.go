@@ -0,0 +1,84 @@
+package dataschema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/k-kkong/dataschema/information_schema"
+)
+
+func TestNewMigrationRecord(t *testing.T) {
+	run := &MigrationRun{Checksum: "abc", Rollback: []string{"ALTER TABLE `user` DROP COLUMN `age`", "DROP TABLE `log`"}}
+	startedAt := time.Now().Add(-time.Second)
+	record := newMigrationRecord(run, map[string]string{"user": "1"}, []string{"ALTER TABLE `user` ADD COLUMN `age` int", "CREATE TABLE `log`(id int)"},
+		"dev@host", startedAt, nil)
+	if record.Checksum != "abc" || !record.Success || record.Error != "" || record.Executor != "dev@host" || record.StartedAt != startedAt {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+	if record.Statements != "ALTER TABLE `user` ADD COLUMN `age` int;\nCREATE TABLE `log`(id int)" ||
+		record.Rollback != "ALTER TABLE `user` DROP COLUMN `age`;\nDROP TABLE `log`" {
+		t.Errorf("Unexpected statements: %q %q", record.Statements, record.Rollback)
+	}
+	if record.DurationMs < 1000 {
+		t.Errorf("Expected duration since start, got %d", record.DurationMs)
+	}
+	snapshot := map[string]string{}
+	if err := json.Unmarshal([]byte(record.DbSnapshot), &snapshot); err != nil || snapshot["user"] != "1" {
+		t.Errorf("Unexpected snapshot: %s", record.DbSnapshot)
+	}
+
+	record = newMigrationRecord(run, nil, nil, "dev@host", startedAt, errors.New("duplicate column"))
+	if record.Success || record.Error != "duplicate column" || record.DbSnapshot != "" {
+		t.Errorf("Unexpected failed record: %+v", record)
+	}
+}
+
+func TestCompareSnapshots(t *testing.T) {
+	report := compareSnapshots(
+		map[string]string{"user": "1", "order": "2", "log": "3"},
+		map[string]string{"user": "1", "order": "4", "tmp": "5", "audit": "6"},
+	)
+	if !reflect.DeepEqual(report.ChangedTables, []string{"order"}) ||
+		!reflect.DeepEqual(report.AddedTables, []string{"audit", "tmp"}) ||
+		!reflect.DeepEqual(report.RemovedTables, []string{"log"}) || !report.HasDrift() {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if report := compareSnapshots(map[string]string{"user": "1"}, map[string]string{"user": "1"}); report.HasDrift() {
+		t.Errorf("Expected no drift, got %+v", report)
+	}
+}
+
+func TestSchemaSnapshot(t *testing.T) {
+	tables := []information_schema.SqlTable{{TableName: "user"}, {TableName: "log"}}
+	columns := []information_schema.SqlTableColumns{{TableName: "user", ColumnName: "id", ColumnType: "int", IsNullable: "NO"}}
+	before := schemaSnapshot(tables, columns, nil)
+	if len(before) != 2 || before["user"] == before["log"] {
+		t.Errorf("Unexpected snapshot: %v", before)
+	}
+
+	statistics := []information_schema.SqlStatistics{{TableName: "user", IndexName: "PRIMARY", ColumnName: "id", IndexType: "BTREE"}}
+	after := schemaSnapshot(tables, columns, statistics)
+	if after["user"] == before["user"] || after["log"] != before["log"] {
+		t.Errorf("Expected only user to change: %v %v", before, after)
+	}
+}
+
+func TestSaveHistoryDisabled(t *testing.T) {
+	run := &MigrationRun{Checksum: "abc"}
+	ts := NewYamlToSqlHandler().SetDB(openLazyDB(t)).SetRecordHistory(false)
+	ts.run = run
+	ts.saveHistory(time.Now(), []string{"SELECT 1"}, nil)
+	if errs := ts.Errors(); len(errs) != 0 {
+		t.Errorf("Expected history to be skipped, got %v", errs)
+	}
+
+	ts = NewYamlToSqlHandler().SetDB(openLazyDB(t))
+	ts.run = run
+	ts.saveHistory(time.Now(), []string{"SELECT 1"}, nil)
+	if errs := ts.Errors(); len(errs) != 1 || errs[0].Code != ERR_CODE_HISTORY {
+		t.Errorf("Expected history to be written by default, got %v", errs)
+	}
+}
@@ -0,0 +1,275 @@
+package dataschema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+// indexColumn 索引中的一个字段，如 name(20) DESC
+type indexColumn struct {
+	Name   string // 字段名
+	Length int    // 前缀长度，0表示整个字段
+	Desc   bool   // 是否降序
+}
+
+// String yml中的写法，如 name(20) DESC
+func (c indexColumn) String() string {
+	return c.format(c.Name)
+}
+
+// definition sql中的写法，如 `name`(20) DESC
+func (c indexColumn) definition() string {
+	return c.format(quoteIdent(c.Name))
+}
+
+func (c indexColumn) format(name string) string {
+	s := name
+	if c.Length > 0 {
+		s = fmt.Sprintf("%s(%d)", s, c.Length)
+	}
+	if c.Desc {
+		s += " DESC"
+	}
+	return s
+}
+
+// indexSpec 一个普通/唯一/全文/空间索引
+type indexSpec struct {
+	Kind      string        // INDEX/UNIQUE INDEX/FULLTEXT INDEX/SPATIAL INDEX
+	Name      string        // 索引名
+	Columns   []indexColumn // 索引字段
+	Using     string        // 索引类型 BTREE/HASH，为空时使用存储引擎默认
+	Invisible bool          // 是否为不可见索引
+	Parser    string        // 全文索引分词器
+}
+
+// columnsDefinition 索引字段定义，如 `name`(20) DESC,`uuid`
+func (s indexSpec) columnsDefinition() string {
+	var columns []string
+	for _, c := range s.Columns {
+		columns = append(columns, c.definition())
+	}
+	return strings.Join(columns, ",")
+}
+
+// options 索引选项，如 USING HASH INVISIBLE
+func (s indexSpec) options() string {
+	var options []string
+	if s.Using != "" {
+		options = append(options, "USING "+s.Using)
+	}
+	if s.Parser != "" {
+		options = append(options, "WITH PARSER "+s.Parser)
+	}
+	if s.Invisible {
+		options = append(options, "INVISIBLE")
+	}
+	return strings.Join(options, " ")
+}
+
+// Definition 建表及 ALTER TABLE ADD 中的索引定义，如 UNIQUE INDEX `uniq_uuid` (`uuid`,`name`(20) DESC) INVISIBLE
+func (s indexSpec) Definition() string {
+	def := fmt.Sprintf("%s %s (%s)", s.Kind, quoteIdent(s.Name), s.columnsDefinition())
+	if options := s.options(); options != "" {
+		def = fmt.Sprintf("%s %s", def, options)
+	}
+	return def
+}
+
+// CreateSql 新建索引的sql
+func (s indexSpec) CreateSql(table string) string {
+	sql := fmt.Sprintf("CREATE %s %s ON %s(%s)", s.Kind, quoteIdent(s.Name), quoteIdent(table), s.columnsDefinition())
+	if options := s.options(); options != "" {
+		sql = fmt.Sprintf("%s %s", sql, options)
+	}
+	return sql
+}
+
+// sameIndex yml中的索引与数据库中的是否一致，分词器不对比；
+// using 只在yml中配置时对比，InnoDB 会把 HASH 静默建为 BTREE，此时也不对比
+func sameIndex(yml, sql indexSpec, engine string) bool {
+	if yml.columnsDefinition() != sql.columnsDefinition() || yml.Invisible != sql.Invisible {
+		return false
+	}
+	if yml.Using == "" || (strings.EqualFold(engine, DEFAULT_ENGINE) && yml.Using == "HASH") {
+		return true
+	}
+	return yml.Using == sql.Using
+}
+
+// indexVisibility 索引可见性，ALTER INDEX 使用
+func indexVisibility(invisible bool) string {
+	if invisible {
+		return "INVISIBLE"
+	}
+	return "VISIBLE"
+}
+
+var indexColumnRegexp = regexp.MustCompile(`(?i)^\s*([\w$]+)\s*(?:\(\s*(\d+)\s*\))?\s*(asc|desc)?\s*$`)
+
+// parseIndexColumn 解析yml中的索引字段，支持 name、name(20)、name(20) desc，
+// 或 {name: name, length: 20, order: desc}
+func parseIndexColumn(value gjson.Result) (indexColumn, error) {
+	if value.IsObject() {
+		c := indexColumn{Name: value.Get("name").String(), Length: int(value.Get("length").Int())}
+		order := strings.ToLower(value.Get("order").String())
+		if c.Name == "" || value.Get("length").Int() < 0 || (order != "" && order != "asc" && order != "desc") {
+			return c, fmt.Errorf("索引字段配置不正确:'%s'", value.Raw)
+		}
+		c.Desc = order == "desc"
+		return c, nil
+	}
+	m := indexColumnRegexp.FindStringSubmatch(value.String())
+	if m == nil {
+		return indexColumn{}, fmt.Errorf("索引字段配置不正确:'%s'", value.String())
+	}
+	c := indexColumn{Name: m[1], Desc: strings.EqualFold(m[3], "desc")}
+	if m[2] != "" {
+		c.Length, _ = strconv.Atoi(m[2])
+	}
+	return c, nil
+}
+
+// ymlIndexSpec yml中的索引配置，无法解析的字段会被忽略，由校验时报告
+func ymlIndexSpec(kind, name string, value gjson.Result) indexSpec {
+	spec := indexSpec{
+		Kind:      kind,
+		Name:      name,
+		Using:     strings.ToUpper(value.Get("using").String()),
+		Invisible: value.Get("invisible").Bool(),
+		Parser:    value.Get("with_parser").String(),
+	}
+	for _, v := range value.Get("columns").Array() {
+		if c, err := parseIndexColumn(v); err == nil {
+			spec.Columns = append(spec.Columns, c)
+		}
+	}
+	return spec
+}
+
+// sqlIndexSpecs 将 show indexes 的结果按yml的索引配置节点分组，主键单独返回
+func sqlIndexSpecs(sqlIndexes []information_schema.SqlIndexes) (sections map[string]map[string]indexSpec, primary []string) {
+	sections = map[string]map[string]indexSpec{}
+	for _, section := range ymlIndexSections {
+		sections[section.Name] = map[string]indexSpec{}
+	}
+	sort.SliceStable(sqlIndexes, func(i, j int) bool {
+		return sqlIndexes[i].Seq_in_index < sqlIndexes[j].Seq_in_index
+	})
+	for _, sqlIndex := range sqlIndexes {
+		if strings.ToLower(sqlIndex.Key_name) == "primary" {
+			primary = append(primary, sqlIndex.Column_name)
+			continue
+		}
+		section := sqlIndexSection(sqlIndex)
+		if section == "" {
+			continue
+		}
+		spec, ok := sections[section][sqlIndex.Key_name]
+		if !ok {
+			spec = indexSpec{Name: sqlIndex.Key_name, Invisible: strings.EqualFold(sqlIndex.Visible, "NO")}
+			for _, s := range ymlIndexSections {
+				if s.Name == section {
+					spec.Kind = s.Kind
+				}
+			}
+			if section == "indexes" || section == "unique_indexes" {
+				spec.Using = strings.ToUpper(sqlIndex.IndexType)
+			}
+		}
+		spec.Columns = append(spec.Columns, sqlIndexColumn(sqlIndex))
+		sections[section][sqlIndex.Key_name] = spec
+	}
+	return sections, primary
+}
+
+// sqlIndexColumn show indexes 中的一行对应的索引字段
+func sqlIndexColumn(sqlIndex information_schema.SqlIndexes) indexColumn {
+	c := indexColumn{Name: sqlIndex.Column_name, Desc: sqlIndex.Collation != nil && *sqlIndex.Collation == "D"}
+	if sqlIndex.Sub_part != nil {
+		c.Length = *sqlIndex.Sub_part
+	}
+	return c
+}
+
+// sqlIndexSection 数据库中的索引对应的yml配置节点，不支持的索引类型返回空
+func sqlIndexSection(sqlIndex information_schema.SqlIndexes) string {
+	switch strings.ToLower(sqlIndex.IndexType) {
+	case "fulltext":
+		return "fulltext_indexes"
+	case "spatial":
+		return "spatial_indexes"
+	case "btree", "hash":
+		if sqlIndex.Non_unique == 0 {
+			return "unique_indexes"
+		}
+		return "indexes"
+	}
+	return ""
+}
+
+// sortedIndexNames 按名称排序的索引名，保证计划的顺序稳定
+func sortedIndexNames(specs map[string]indexSpec) []string {
+	var names []string
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkYmlIndexes 检查索引配置
+func checkYmlIndexes(tbJson gjson.Result, report func(d Diagnostic, path string)) {
+	tname := tbJson.Get("table").String()
+	for _, section := range ymlIndexSections {
+		tbJson.Get(section.Name).ForEach(func(key, value gjson.Result) bool {
+			path := "Table." + section.Name + "." + key.String()
+			if !value.Get("columns").IsArray() {
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_INDEX, Table: tname, Index: key.String(),
+					Message: fmt.Sprintf("%s:'%s' 必须是数组", section.Name, key.String())}, path)
+				return true
+			}
+			for i, v := range value.Get("columns").Array() {
+				c, err := parseIndexColumn(v)
+				if err != nil {
+					report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_INDEX, Table: tname, Index: key.String(),
+						Message: err.Error()}, fmt.Sprintf("%s.columns.%d", path, i))
+					continue
+				}
+				if !tbJson.Get("fields."+c.Name).Exists() && !tbJson.Get("id."+c.Name).Exists() {
+					report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INDEX_COLUMN_NOT_FOUND, Table: tname, Index: key.String(), Field: c.Name,
+						Message: fmt.Sprintf("%s columns:'%s' is not find", section.Name, c.Name)}, fmt.Sprintf("%s.columns.%d", path, i))
+				}
+				if (c.Length > 0 || c.Desc) && (section.Name == "fulltext_indexes" || section.Name == "spatial_indexes") {
+					report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_INDEX, Table: tname, Index: key.String(), Field: c.Name,
+						Message: fmt.Sprintf("%s 不支持前缀长度和排序", section.Name)}, fmt.Sprintf("%s.columns.%d", path, i))
+				}
+			}
+
+			using := strings.ToUpper(value.Get("using").String())
+			switch {
+			case using == "":
+			case section.Name == "fulltext_indexes" || section.Name == "spatial_indexes":
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_INDEX, Table: tname, Index: key.String(),
+					Message: fmt.Sprintf("%s 不支持 using", section.Name)}, path+".using")
+			case using != "BTREE" && using != "HASH":
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_INDEX, Table: tname, Index: key.String(),
+					Message: fmt.Sprintf("不支持的 using:'%s'，可选 btree|hash", value.Get("using").String())}, path+".using")
+			case using == "HASH" && strings.EqualFold(ymlEngine(tbJson), DEFAULT_ENGINE):
+				report(Diagnostic{Severity: SEVERITY_WARNING, Code: ERR_CODE_INVALID_INDEX, Table: tname, Index: key.String(),
+					Message: "InnoDB 不支持 HASH 索引，实际会创建为 BTREE"}, path+".using")
+			}
+			if section.Name == "spatial_indexes" && len(value.Get("columns").Array()) != 1 {
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_INDEX, Table: tname, Index: key.String(),
+					Message: "空间索引只能包含一个字段"}, path+".columns")
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package dataschema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+func TestParseIndexColumn(t *testing.T) {
+	cases := map[string]indexColumn{
+		`"name"`:              {Name: "name"},
+		`"name(20)"`:          {Name: "name", Length: 20},
+		`"created_at desc"`:   {Name: "created_at", Desc: true},
+		`" title ( 8 ) ASC "`: {Name: "title", Length: 8},
+		`{"name": "title", "length": 8, "order": "DESC"}`: {Name: "title", Length: 8, Desc: true},
+	}
+	for raw, want := range cases {
+		c, err := parseIndexColumn(gjson.Parse(raw))
+		if err != nil || c != want {
+			t.Errorf("%s: expected %+v, got %+v %v", raw, want, c, err)
+		}
+	}
+	for _, raw := range []string{`"name desc asc"`, `"name(x)"`, `"a,b"`, `{"length": 8}`, `{"name": "a", "order": "up"}`} {
+		if _, err := parseIndexColumn(gjson.Parse(raw)); err == nil {
+			t.Errorf("Expected error for %s", raw)
+		}
+	}
+}
+
+func TestIndexSpecDefinition(t *testing.T) {
+	spec := ymlIndexSpec("INDEX", "idx_title", gjson.Parse(`{"columns": ["title(20)", "created_at desc"], "using": "btree", "invisible": true}`))
+	if got := spec.Definition(); got != "INDEX `idx_title` (`title`(20),`created_at` DESC) USING BTREE INVISIBLE" {
+		t.Errorf("Unexpected definition: %s", got)
+	}
+	if got := spec.CreateSql("article"); got != "CREATE INDEX `idx_title` ON `article`(`title`(20),`created_at` DESC) USING BTREE INVISIBLE" {
+		t.Errorf("Unexpected create sql: %s", got)
+	}
+
+	spatial := ymlIndexSpec("SPATIAL INDEX", "sp_location", gjson.Parse(`{"columns": ["location"]}`))
+	if got := spatial.Definition(); got != "SPATIAL INDEX `sp_location` (`location`)" {
+		t.Errorf("Unexpected spatial definition: %s", got)
+	}
+}
+
+func TestSqlIndexSpecs(t *testing.T) {
+	sub := 20
+	desc := "D"
+	asc := "A"
+	sections, primary := sqlIndexSpecs([]information_schema.SqlIndexes{
+		{Non_unique: 1, Key_name: "idx_title", Seq_in_index: 2, Column_name: "created_at", IndexType: "BTREE", Collation: &desc, Visible: "NO"},
+		{Non_unique: 1, Key_name: "idx_title", Seq_in_index: 1, Column_name: "title", IndexType: "BTREE", Sub_part: &sub, Collation: &asc, Visible: "NO"},
+		{Non_unique: 0, Key_name: "PRIMARY", Seq_in_index: 1, Column_name: "id", IndexType: "BTREE", Collation: &asc},
+		{Non_unique: 1, Key_name: "sp_location", Seq_in_index: 1, Column_name: "location", IndexType: "SPATIAL", Sub_part: new(int), Visible: "YES"},
+	})
+	if !reflect.DeepEqual(primary, []string{"id"}) {
+		t.Errorf("Unexpected primary: %v", primary)
+	}
+	title := sections["indexes"]["idx_title"]
+	if got := title.Definition(); got != "INDEX `idx_title` (`title`(20),`created_at` DESC) USING BTREE INVISIBLE" {
+		t.Errorf("Unexpected index from db: %s", got)
+	}
+	if _, ok := sections["spatial_indexes"]["sp_location"]; !ok {
+		t.Errorf("Expected spatial index, got %+v", sections)
+	}
+
+	yml := ymlIndexSpec("INDEX", "idx_title", gjson.Parse(`{"columns": ["title(20)", "created_at desc"], "invisible": true}`))
+	if !sameIndex(yml, title, "InnoDB") {
+		t.Error("Expected index without using to match")
+	}
+	yml.Using = "HASH"
+	if !sameIndex(yml, title, "InnoDB") || sameIndex(yml, title, "MEMORY") {
+		t.Error("Expected HASH to be ignored only on InnoDB")
+	}
+	yml = ymlIndexSpec("INDEX", "idx_title", gjson.Parse(`{"columns": ["title(20)", "created_at desc"]}`))
+	if sameIndex(yml, title, "InnoDB") {
+		t.Error("Expected visibility change to be detected")
+	}
+}
+
+func TestValidateIndexes(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.Article.yml", `Table:
+  table: article
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+  indexes:
+    idx_title:
+      columns:
+        - title(20) desc
+        - name desc asc
+      using: hash
+  fulltext_indexes:
+    ft_title:
+      columns:
+        - title(10)
+  spatial_indexes:
+    sp_location:
+      columns:
+        - location
+  fields:
+    title:
+      type: varchar(255)
+    location:
+      type: point
+`)
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	var got []string
+	for _, d := range ds {
+		got = append(got, d.Severity+":"+d.Code)
+	}
+	want := []string{
+		SEVERITY_ERROR + ":" + ERR_CODE_INVALID_INDEX,
+		SEVERITY_WARNING + ":" + ERR_CODE_INVALID_INDEX,
+		SEVERITY_ERROR + ":" + ERR_CODE_INVALID_INDEX,
+	}
+	if !reflect.DeepEqual(got, want) || ds[0].Line != 10 {
+		t.Errorf("Unexpected diagnostics: %v", ds)
+	}
+}
@@ -6,8 +6,9 @@ import (
 	"fmt"
 	"os"
 	"path"
-
+	"regexp"
 	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 	"gorm.io/driver/mysql"
@@ -24,14 +25,44 @@ type YamlToSqlHandler struct {
 	EncryKey                 string // 加密key
 	BuildSchemaDest          string //
 
-	dsn string   //数据库连接dsn,列：用户:密码@(127.0.0.1:3306)/数据库?charset=utf8mb4&parseTime=True&loc=Local
-	db  *gorm.DB //数据库连接
+	dsn   string   //数据库连接dsn,列：用户:密码@(127.0.0.1:3306)/数据库?charset=utf8mb4&parseTime=True&loc=Local
+	db    *gorm.DB //数据库连接
+	ownDB bool     //连接是否由 connectSql 打开，需要由处理器关闭
+
+	schema  string   //目标库名，为空时使用连接的当前库
+	targets []Target //多个目标库，按顺序应用同一套yml
 
 	YamlPath          string //yaml文件路径
 	yamlFileFullPaths []string
 	tables            []string
+	tableFiles        []string            //与tables一一对应的配置文件路径
+	shardings         []shardingExpansion //按模板展开的分表
+
+	sql      []string
+	plan     *MigrationPlan //迁移计划
+	approver Approver       //安全同步时的审批器
+	approved bool           //最近一次计划是否通过审批
+
+	allowDestructive bool //是否允许删除yml中未声明的字段和索引
+
+	algorithm string //全局 ALGORITHM，可被yml中的 options.algorithm 覆盖
+	lock      string //全局 LOCK，可被yml中的 options.lock 覆盖
+
+	splitStatements bool //是否每个变更单独一条语句，默认同一张表的变更合并为一条 ALTER TABLE
+	fixColumnOrder  bool //是否按yml中的顺序调整已有字段的顺序
+
+	historyTable  string //迁移历史表
+	recordHistory bool   //是否记录迁移历史
+	executor      string //执行者
 
-	sql []string
+	stateFile string        //迁移执行状态文件
+	run       *MigrationRun //最近一次迁移的执行记录
+
+	concurrency int                   //对比和执行的并发数
+	progress    func(p TableProgress) //进度回调
+
+	mu   sync.Mutex   //保护errs，并发时使用
+	errs SchemaErrors //同步过程中收集到的错误
 }
 
 // NewYamlToSqlHandler 创建表结构维护器
@@ -40,6 +71,8 @@ func NewYamlToSqlHandler() *YamlToSqlHandler {
 		IsOutputBuildSchema:      false,
 		IsEncryOutputBuildSchema: false,
 		BuildSchemaDest:          "./dataschema.value",
+		historyTable:             DEFAULT_HISTORY_TABLE,
+		recordHistory:            true,
 	}
 }
 
@@ -55,18 +88,57 @@ func (ts *YamlToSqlHandler) SetDB(db *gorm.DB) *YamlToSqlHandler {
 	return ts
 }
 
-func (ts *YamlToSqlHandler) connectSql() {
+func (ts *YamlToSqlHandler) connectSql() *YamlToSqlHandler {
 	if ts.db == nil {
 		if ts.dsn == "" {
-			panic("数据库连接不能为空")
+			ts.addError(&SchemaError{Code: ERR_CODE_DB_CONNECT, Message: "数据库连接不能为空"})
+			return ts
+		}
+		dsn, err := dsnWithSchema(ts.dsn, ts.schema)
+		if err != nil {
+			ts.addError(&SchemaError{Code: ERR_CODE_DB_CONNECT, Message: "数据库连接dsn不正确", Err: err})
+			return ts
 		}
 		var configs = &gorm.Config{}
-		db, err := gorm.Open(mysql.Open(ts.dsn), configs)
+		db, err := gorm.Open(mysql.Open(dsn), configs)
 		if err != nil {
-			panic(err)
+			ts.addError(&SchemaError{Code: ERR_CODE_DB_CONNECT, Message: "数据库连接失败", Err: err})
+			return ts
 		}
 		ts.db = db
+		ts.ownDB = true
+		if ts.schema != "" {
+			return ts
+		}
+	}
+	//对比按 schema 查询 INFORMATION_SCHEMA，DDL 却在连接的当前库执行，两者必须一致
+	var current string
+	if err := ts.db.Raw("SELECT DATABASE()").Scan(&current).Error; err != nil {
+		ts.addError(&SchemaError{Code: ERR_CODE_DB_CONNECT, Message: "查询当前数据库失败", Err: err})
+		return ts
 	}
+	switch {
+	case ts.schema == "" && current == "":
+		ts.addError(&SchemaError{Code: ERR_CODE_DB_CONNECT, Message: "未指定数据库，请在dsn中指定或调用 SetSchema"})
+	case ts.schema == "":
+		ts.schema = current
+	case ts.schema != current:
+		ts.addError(&SchemaError{Code: ERR_CODE_DB_CONNECT,
+			Message: fmt.Sprintf("连接的当前库 %s 与目标库 %s 不一致", current, ts.schema)})
+	}
+	return ts
+}
+
+// closeDB 关闭 connectSql 打开的连接池，外部传入的连接不关闭
+func (ts *YamlToSqlHandler) closeDB() {
+	if !ts.ownDB {
+		return
+	}
+	if sqlDB, err := ts.db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	ts.db = nil
+	ts.ownDB = false
 }
 
 // SetYamlPath 设置yaml配置文件路径
@@ -89,136 +161,163 @@ func (ts *YamlToSqlHandler) SetBuildSchemaDest(dest string) *YamlToSqlHandler {
 	return ts
 }
 
+// reset 清空上一次同步的状态，便于重复调用
+func (ts *YamlToSqlHandler) reset() *YamlToSqlHandler {
+	ts.yamlFileFullPaths = nil
+	ts.tables = nil
+	ts.tableFiles = nil
+	ts.shardings = nil
+	ts.sql = nil
+	ts.plan = nil
+	ts.approved = false
+	ts.resetErrors()
+	return ts
+}
+
 func (ts *YamlToSqlHandler) getyamlFileFullPaths() *YamlToSqlHandler {
+	if ts.hasError() {
+		return ts
+	}
 
-	files, err := os.ReadDir(ts.YamlPath)
+	files, err := listYamlFiles(ts.YamlPath)
 	if err != nil {
-		fmt.Println(err)
-	}
-	for _, f := range files {
-		// fmt.Println(f.Name())
-		filename := string(f.Name())
-		if strings.Contains(filename, ".yml") {
-			filename = fmt.Sprintf("%s%s", ts.YamlPath, filename)
-			ts.yamlFileFullPaths = append(ts.yamlFileFullPaths, filename)
-			// fmt.Println(ts.yamlFileFullPaths)
-		}
+		ts.addError(&SchemaError{Code: ERR_CODE_READ_DIR, File: ts.YamlPath, Message: "配置目录读取失败", Err: err})
+		return ts
 	}
+	ts.yamlFileFullPaths = append(ts.yamlFileFullPaths, files...)
 
 	return ts
 }
 
 func (ts *YamlToSqlHandler) getYamlDatas() *YamlToSqlHandler {
+	if ts.hasError() {
+		return ts
+	}
 	var buildmapping = map[string]interface{}{}
 	for _, v := range ts.yamlFileFullPaths {
 
 		yamlFile, err := os.ReadFile(v)
 		if err != nil {
-			fmt.Println(err.Error())
+			ts.addError(&SchemaError{Code: ERR_CODE_READ_FILE, File: v, Message: "配置文件读取失败", Err: err})
+			continue
+		}
+		//通过yaml节点转换，保留字段在文件中的顺序
+		var doc yaml.Node
+		err = yaml.Unmarshal(yamlFile, &doc)
+		if err != nil {
+			ts.addError(&SchemaError{Code: ERR_CODE_PARSE_FILE, File: v, Message: "配置文件解析失败", Err: err})
+			continue
 		}
-		table := map[string]interface{}{}
-		err = yaml.Unmarshal(yamlFile, &table)
+		jb, err := yamlNodeToJSON(&doc)
 		if err != nil {
-			fmt.Println(err.Error())
+			ts.addError(&SchemaError{Code: ERR_CODE_SERIALIZE, File: v, Message: "配置文件序列化失败", Err: err})
+			continue
 		}
-		jb, err := json.Marshal(&table)
 
 		tvalue := string(jb)
 		tname := gjson.Parse(tvalue).Get("Table.table").String()
+		//严格解析，未定义的键(如拼写错误)和不支持的版本都作为错误，不会被静默忽略
+		if _, err := parseSchemaFile(v, yamlFile); err != nil {
+			se := err.(*SchemaError)
+			se.Table = tname
+			ts.addError(se)
+			continue
+		}
 		if _, ok := buildmapping[tname]; ok {
-			fmt.Printf("\x1b[%dm配置文件: %s 序列化失败，重复定义的表 \x1b[0m\n", 31, v)
-			panic(fmt.Sprintf("\x1b[%dm配置文件: %s 序列化失败\x1b[0m\n", 31, v))
+			ts.addError(&SchemaError{Code: ERR_CODE_DUPLICATE_TABLE, File: v, Table: tname, Message: "重复定义的表"})
+			continue
 		}
 
-		sharding_tables := gjson.Parse(tvalue).Get("Table.sharding_tables").String()
-		if sharding_tables != "" {
-			for _, sharding_name := range strings.Split(sharding_tables, ",") {
-				if sharding_name == "" {
-					continue
-				}
-				sharding_tblv, _ := sjson.Set(tvalue, "Table.table", sharding_name)
-				ts.tables = append(ts.tables, sharding_tblv)
-			}
-		} else {
-			ts.tables = append(ts.tables, tvalue)
-		}
-		buildmapping[tname] = table
-		// t, err := json.Marshal(&table)
-		// fmt.Println("json:", string(jb))
-		if err != nil {
-			fmt.Printf("\x1b[%dm配置文件: %s 序列化失败\x1b[0m\n", 31, v)
-			panic(fmt.Sprintf("\x1b[%dm配置文件: %s 序列化失败\x1b[0m\n", 31, v))
-		}
+		ts.appendTable(tvalue, v)
+		buildmapping[tname] = json.RawMessage(jb)
+	}
 
+	if ts.IsOutputBuildSchema && !ts.hasError() {
+		ts.outputBuildSchema(buildmapping)
 	}
 
-	if ts.IsOutputBuildSchema {
-		jb, err := json.Marshal(&buildmapping)
-		if err != nil {
-			fmt.Printf("\x1b[%dm 序列化编译产物失败 \x1b[0m\n", 31)
-			panic(fmt.Sprintf("\x1b[%dm 序列化编译产物失败 \x1b[0m\n", 31))
-		}
+	return ts
+}
 
-		bvalue := string(jb)
-		if ts.IsEncryOutputBuildSchema {
-			bvalue, err = EncryptString(bvalue, []byte(ts.EncryKey))
-			if err != nil {
-				fmt.Printf("\x1b[%dm 序列化编译产物加密失败: %s \x1b[0m\n", 31, err.Error())
-				panic(fmt.Sprintf("\x1b[%dm 序列化编译产物加密失败: %s \x1b[0m\n", 31, err.Error()))
-			}
-		}
+// appendTable 添加表配置，配置了分表的按分表展开
+func (ts *YamlToSqlHandler) appendTable(tvalue string, file string) {
+	tbJson := gjson.Get(tvalue, "Table")
+	if !isShardedTable(tbJson) {
+		ts.tables = append(ts.tables, tvalue)
+		ts.tableFiles = append(ts.tableFiles, file)
+		return
+	}
+	sharding_names, err := ymlShardingTables(tbJson)
+	if err != nil {
+		ts.addError(&SchemaError{Code: ERR_CODE_INVALID_SHARDING, File: file, Table: tbJson.Get("table").String(), Message: "分表配置不正确", Err: err})
+		return
+	}
+	for _, sharding_name := range sharding_names {
+		sharding_tblv, _ := sjson.Set(tvalue, "Table.table", sharding_name)
+		ts.tables = append(ts.tables, sharding_tblv)
+		ts.tableFiles = append(ts.tableFiles, file)
+	}
+	//按模板展开的分表，记录下来用于检查数据库中未覆盖的分表
+	if pattern := tbJson.Get("sharding.pattern").String(); pattern != "" {
+		ts.shardings = append(ts.shardings, shardingExpansion{Table: tbJson.Get("table").String(), File: file, Pattern: pattern, Expanded: sharding_names})
+	}
+}
 
-		os.MkdirAll(path.Dir(ts.BuildSchemaDest), os.ModePerm)
-		file, err := os.Create(ts.BuildSchemaDest)
-		if err != nil {
-			fmt.Printf("\x1b[%dm 序列化产物写入失败 \x1b[0m\n", 31)
-			panic(fmt.Sprintf("\x1b[%dm 序列化产物写入失败 %s \x1b[0m\n", 31, err.Error()))
-		}
-		defer file.Close()
+// outputBuildSchema 输出编译产物
+func (ts *YamlToSqlHandler) outputBuildSchema(buildmapping map[string]interface{}) {
+	jb, err := json.Marshal(&buildmapping)
+	if err != nil {
+		ts.addError(&SchemaError{Code: ERR_CODE_BUILD_SCHEMA, File: ts.BuildSchemaDest, Message: "序列化编译产物失败", Err: err})
+		return
+	}
 
-		writer := bufio.NewWriter(file)
-		_, err = fmt.Fprint(writer, bvalue)
+	bvalue := string(jb)
+	if ts.IsEncryOutputBuildSchema {
+		bvalue, err = EncryptString(bvalue, []byte(ts.EncryKey))
 		if err != nil {
-			fmt.Printf("\x1b[%dm 序列化编译产物写入失败 %s\x1b[0m\n", 31, err.Error())
-			panic(fmt.Sprintf("\x1b[%dm 序列化编译产物写入失败 %s \x1b[0m\n", 31, err.Error()))
+			ts.addError(&SchemaError{Code: ERR_CODE_BUILD_SCHEMA, File: ts.BuildSchemaDest, Message: "序列化编译产物加密失败", Err: err})
+			return
 		}
-		writer.Flush()
+	}
 
+	os.MkdirAll(path.Dir(ts.BuildSchemaDest), os.ModePerm)
+	file, err := os.Create(ts.BuildSchemaDest)
+	if err != nil {
+		ts.addError(&SchemaError{Code: ERR_CODE_BUILD_SCHEMA, File: ts.BuildSchemaDest, Message: "序列化产物写入失败", Err: err})
+		return
 	}
+	defer file.Close()
 
-	return ts
+	writer := bufio.NewWriter(file)
+	_, err = fmt.Fprint(writer, bvalue)
+	if err != nil {
+		ts.addError(&SchemaError{Code: ERR_CODE_BUILD_SCHEMA, File: ts.BuildSchemaDest, Message: "序列化编译产物写入失败", Err: err})
+		return
+	}
+	writer.Flush()
 }
 
 func (ts *YamlToSqlHandler) loadFromBuildSchema() *YamlToSqlHandler {
+	if ts.hasError() {
+		return ts
+	}
 
 	bvalue, err := os.ReadFile(ts.BuildSchemaDest)
 	if err != nil {
-		fmt.Printf("\x1b[%dm 序列化编译产物读取失败 \x1b[0m\n", 31)
-		panic(fmt.Sprintf("\x1b[%dm 序列化编译产物读取失败 \x1b[0m\n", 31))
+		ts.addError(&SchemaError{Code: ERR_CODE_BUILD_SCHEMA, File: ts.BuildSchemaDest, Message: "序列化编译产物读取失败", Err: err})
+		return ts
 	}
 
 	bvaluestr := string(bvalue)
 	if ts.IsEncryOutputBuildSchema {
 		bvaluestr, err = DecryptString(bvaluestr, []byte(ts.EncryKey))
 		if err != nil {
-			fmt.Printf("\x1b[%dm 序列化编译产物解密失败 \x1b[0m\n", 31)
-			panic(fmt.Sprintf("\x1b[%dm 序列化编译产物解密失败 \x1b[0m\n", 31))
+			ts.addError(&SchemaError{Code: ERR_CODE_BUILD_SCHEMA, File: ts.BuildSchemaDest, Message: "序列化编译产物解密失败", Err: err})
+			return ts
 		}
 	}
 	gjson.Parse(bvaluestr).ForEach(func(key, value gjson.Result) bool {
-		sharding_tables := value.Get("Table.sharding_tables").String()
-		_value := value.String()
-		if sharding_tables != "" {
-			for _, sharding_name := range strings.Split(sharding_tables, ",") {
-				if sharding_name == "" {
-					continue
-				}
-				sharding_tblv, _ := sjson.Set(_value, "Table.table", sharding_name)
-				ts.tables = append(ts.tables, sharding_tblv)
-			}
-		} else {
-			ts.tables = append(ts.tables, value.String())
-		}
+		ts.appendTable(value.String(), ts.BuildSchemaDest)
 		return true
 	})
 
@@ -226,482 +325,485 @@ func (ts *YamlToSqlHandler) loadFromBuildSchema() *YamlToSqlHandler {
 }
 
 func (ts *YamlToSqlHandler) doSchema() *YamlToSqlHandler {
-	// fmt.Println(ts.tables)
+	if ts.hasError() {
+		return ts
+	}
 
-	for i, tbl := range ts.tables {
-		// sql := ""
-		tbJson := gjson.Get(tbl, "Table")
+	ts.plan = &MigrationPlan{}
+	//被外键引用的表先创建
+	var tbJsons []gjson.Result
+	for _, tbl := range ts.tables {
+		tbJsons = append(tbJsons, gjson.Get(tbl, "Table"))
+	}
+	order := foreignKeyOrder(tbJsons)
+	plans := make([]*TablePlan, len(order))
+	pc := newProgressCounter(PROGRESS_DIFF, len(order))
+	runPool(ts.concurrency, len(order), func(k int) {
+		i := order[k]
+		plans[k] = ts.diffTable(ts.tables[i], ts.tableFiles[i])
+		ts.finishTable(pc, tbJsons[i].Get("table").String())
+	})
+	//按原有顺序汇总，保证计划与并发数无关
+	for _, tp := range plans {
+		if tp == nil {
+			continue
+		}
+		ts.plan.Tables = append(ts.plan.Tables, *tp)
+		ts.sql = append(ts.sql, tp.Sql())
+	}
+	ts.plan.UncoveredShards = ts.getShardingReports()
 
-		//primary field
-		var tbljsonv = tbJson.String()
-		tbJson.Get("id").ForEach(func(key, value gjson.Result) bool {
-			if tbJson.Get("fields." + key.String()).Exists() {
-				panic("主键字段和普通字段重名")
-			}
-			tbljsonv, _ = sjson.Set(tbljsonv, "fields."+key.String(), value.Value())
-			return true
-		})
+	return ts
+}
+
+// diffTable 对比单张表并生成迁移计划，配置有误时返回nil，会在多个goroutine中并发调用
+func (ts *YamlToSqlHandler) diffTable(tbl string, file string) *TablePlan {
+	tbJson := gjson.Get(tbl, "Table")
+	if !tbJson.Exists() {
+		ts.addError(&SchemaError{Code: ERR_CODE_INVALID_TABLE, File: file, Message: "配置文件不正确"})
+		return nil
+	}
+
+	tname := tbJson.Get("table")
+	if !tname.Exists() {
+		ts.addError(&SchemaError{Code: ERR_CODE_MISSING_TABLE, File: file, Message: "缺少表名"})
+		return nil
+	}
+
+	//primary field
+	var dupErr bool
+	tbJson.Get("id").ForEach(func(key, value gjson.Result) bool {
+		if tbJson.Get("fields." + key.String()).Exists() {
+			ts.addError(&SchemaError{Code: ERR_CODE_DUPLICATE_FIELD, File: file, Table: tname.String(), Field: key.String(), Message: "主键字段和普通字段重名"})
+			dupErr = true
+			return false
+		}
+		return true
+	})
+	if dupErr {
+		return nil
+	}
+	//主键字段放在最前面，保持yml中的顺序
+	if tbJson.Get("id").Exists() {
+		tbljsonv, _ := sjson.SetRaw(tbJson.String(), "fields", ymlMergedFields(tbJson))
 		tbJson = gjson.Parse(tbljsonv)
+	}
 
-		if tbJson.Exists() {
-			tname := tbJson.Get("table")
-			// fmt.Println(tname)
-			if tname.Exists() {
-				var sqlTbl information_schema.SqlTable
-				ts.db.Table("INFORMATION_SCHEMA.TABLES").
-					Select("*").
-					Where("TABLE_SCHEMA=database()").
-					Where("TABLE_NAME=?", tname.String()).Find(&sqlTbl)
-				// fmt.Println(sqlTbl)
-				//数据库里没有这张表
-				if sqlTbl.TableName == "" {
-					create := ts.getCreateTableSql(tbJson)
-					// sql = fmt.Sprintf("%s;\n%s", sql, create)
-					ts.sql = append(ts.sql, create)
-					// fmt.Println(create)
-				} else {
-					change := ts.getGetChangeTableSql(tbJson, sqlTbl)
-					ts.sql = append(ts.sql, change)
-					// fmt.Println(change)
-				}
-			} else {
-				fmt.Printf("\x1b[%dm 文件: %s 缺少表名\x1b[0m\n", 31, ts.yamlFileFullPaths[i])
-				panic("缺少表名")
+	//读取失败时不能当作表不存在，否则会对已有的表生成 CREATE TABLE
+	var sqlTbl information_schema.SqlTable
+	err := ts.db.Table("INFORMATION_SCHEMA.TABLES").
+		Select("*").
+		Where("TABLE_SCHEMA=?", ts.schema).
+		Where("TABLE_NAME=?", tname.String()).Find(&sqlTbl).Error
+	if err != nil {
+		ts.addQueryError(file, tname.String(), "读取表信息失败", err)
+		return nil
+	}
+	//表改名：新表不存在时按 renamed_from 查找旧表
+	if sqlTbl.TableName == "" && !isShardedTable(tbJson) {
+		for _, oldName := range ymlPreviousNames(tbJson) {
+			err := ts.db.Table("INFORMATION_SCHEMA.TABLES").
+				Select("*").
+				Where("TABLE_SCHEMA=?", ts.schema).
+				Where("TABLE_NAME=?", oldName).Find(&sqlTbl).Error
+			if err != nil {
+				ts.addQueryError(file, tname.String(), "读取表信息失败", err)
+				return nil
+			}
+			if sqlTbl.TableName != "" {
+				break
 			}
-
-		} else {
-			fmt.Printf("\x1b[%dm 文件: %s 不正确\x1b[0m\n", 31, ts.yamlFileFullPaths[i])
-			panic("配置文件不正确")
 		}
+	}
 
+	var tp TablePlan
+	//数据库里没有这张表
+	if sqlTbl.TableName == "" {
+		tp = ts.getCreateTablePlan(tbJson, file)
+	} else {
+		tp = ts.getChangeTablePlan(tbJson, sqlTbl, file)
 	}
+	tp.Split = ts.splitStatements
+	return &tp
+}
 
-	return ts
+// yml索引配置节点及对应的索引类型
+var ymlIndexSections = []struct {
+	Name string
+	Kind string
+}{
+	{"indexes", "INDEX"},
+	{"unique_indexes", "UNIQUE INDEX"},
+	{"fulltext_indexes", "FULLTEXT INDEX"},
+	{"spatial_indexes", "SPATIAL INDEX"},
 }
 
-func (ts *YamlToSqlHandler) getCreateTableSql(tbl gjson.Result) string {
+func (ts *YamlToSqlHandler) getCreateTablePlan(tbl gjson.Result, file string) TablePlan {
+	tname := tbl.Get("table").String()
+	tp := TablePlan{Table: tname, File: file}
+	create := ts.getCreateTableSql(tbl, file)
+	if create != "" {
+		tp.add(PlanOperation{Type: OP_CREATE_TABLE, Name: tname, NewDefinition: create, Sql: create})
+	}
+	return tp
+}
 
-	tname := tbl.Get("table")
+func (ts *YamlToSqlHandler) getCreateTableSql(tbl gjson.Result, file string) string {
 
-	// sql := fmt.Sprintf("CREATE TABLE %s()", tname.String())
-	sql := ""
+	tname := tbl.Get("table").String()
 
-	createPrefix := fmt.Sprintf("CREATE TABLE %s(\n", tname.String())
 	if tbl.Get("options.charset").String() == "" {
-		fmt.Printf("\x1b[%dm 表: %s charset 不正确\x1b[0m\n", 31, tname)
-		panic("配置文件不正确")
+		ts.addError(&SchemaError{Code: ERR_CODE_INVALID_OPTION, File: file, Table: tname, Message: "charset 不正确"})
+		return ""
 	}
 	if tbl.Get("options.collate").String() == "" {
-		fmt.Printf("\x1b[%dm 表: %s collate 不正确\x1b[0m\n", 31, tname)
-		panic("配置文件不正确")
+		ts.addError(&SchemaError{Code: ERR_CODE_INVALID_OPTION, File: file, Table: tname, Message: "collate 不正确"})
+		return ""
 	}
-	createSuffix := fmt.Sprintf(")\nDEFAULT CHARACTER SET %s COLLATE %s ENGINE = InnoDB ",
+	createSuffix := fmt.Sprintf(")\nDEFAULT CHARACTER SET %s COLLATE %s ENGINE = %s",
 		tbl.Get("options.charset").String(),
 		tbl.Get("options.collate").String(),
+		ymlEngine(tbl),
 	)
+	if rowFormat := tbl.Get("options.row_format").String(); rowFormat != "" {
+		createSuffix = fmt.Sprintf("%s ROW_FORMAT = %s", createSuffix, strings.ToUpper(rowFormat))
+	}
 	if tbl.Get("options.comment").String() != "" {
-		createSuffix = fmt.Sprintf("%s COMMENT = '%s' ;",
+		createSuffix = fmt.Sprintf("%s COMMENT = %s",
 			createSuffix,
-			tbl.Get("options.comment").String(),
+			quoteLiteral(tbl.Get("options.comment").String()),
 		)
 	}
+	if partitions := tbl.Get("options.partitions"); partitions.Exists() {
+		createSuffix = fmt.Sprintf("%s\n%s", createSuffix, ymlPartitionBy(partitions))
+	}
 
-	// var Ids []information_schema.TalbeIdInfo
-	// gjson.ForEachLine(tbl.Get("id").String(), func(line gjson.Result) bool {
-	// 	fmt.Println(line)
-	// 	return true
-	// })
-	// fmt.Println(tbl.Get("id.id.type").IsObject())
-	// fmt.Println(tbl.Get("id.id").IsObject())
-	// fmt.Println(tbl.Get("id").IsObject())
-
-	var noId bool
-	// var noUniqueIndex bool
-	// var noIndex bool
-
-	columns := ""
-
-	// primary_keys
-	var primary_key string
-	{
-		// 如果配置了主键就从主键里招，没有就从ID里
-		var primary_columns []string
-		if tbl.Get("primary_indexes").Exists() {
-			for _, col := range tbl.Get("primary_indexes.columns").Array() {
-				if col.String() == "" {
-					continue
-				}
-				primary_columns = append(primary_columns, col.String())
-			}
-		} else {
-			if tbl.Get("id").IsObject() {
-				tbl.Get("id").ForEach(func(key, value gjson.Result) bool {
-					if key.String() != "" {
-						primary_columns = append(primary_columns, key.String())
-					} else {
-						noId = true
-					}
-					return true
-				})
-			}
-		}
-		if len(primary_columns) > 0 {
-			primary_key = fmt.Sprintf(`PRIMARY KEY(%s)`, strings.Join(primary_columns, ","))
-		} else {
-			noId = true
+	var definitions []string
+	var invalidField bool
+	tbl.Get("fields").ForEach(func(key, value gjson.Result) bool {
+		if !value.IsObject() {
+			ts.addError(&SchemaError{Code: ERR_CODE_INVALID_FIELD, File: file, Table: tname, Field: key.String(), Message: "字段配置不正确"})
+			invalidField = true
+			return true
 		}
+		definitions = append(definitions, ymlColumnDefinition(key.String(), value))
+		return true
+	})
+	if invalidField {
+		return ""
 	}
 
-	if tbl.Get("fields").IsObject() {
-		tbl.Get("fields").ForEach(func(key, value gjson.Result) bool {
-			if value.IsObject() {
-
-				def := value.Get("default").String()
-				generator := value.Get("generator").String()
-				comment := value.Get("comment")
-				columnType := value.Get("type").String()
-				// if columnType == "varchar" {
-				// 	columnType = "varchar(255)"
-				// }
-				columnType = getTypeYml2SqlMapping(columnType)
-				if value.Get("nullable").Bool() {
-					//不能有默认值的或者不写默认值
-					if !value.Get("default").Exists() || isNoDefaultType(columnType) {
-						if isNoDefaultType(columnType) {
-							columns = fmt.Sprintf("%s\t%s %s COMMENT '%s' ,\n",
-								columns,
-								key.String(),
-								columnType,
-								comment,
-							)
-						} else {
-							columns = fmt.Sprintf("%s\t%s %s DEFAULT NULL %s COMMENT '%s' ,\n",
-								columns,
-								key.String(),
-								columnType,
-								generator,
-								comment,
-							)
-						}
-
-					} else {
-						columns = fmt.Sprintf("%s\t%s %s DEFAULT '%s' %s COMMENT '%s' ,\n",
-							columns,
-							key.String(),
-							columnType,
-							def,
-							generator,
-							comment,
-						)
-					}
+	for _, section := range ymlIndexSections {
+		tbl.Get(section.Name).ForEach(func(key, value gjson.Result) bool {
+			if value.Get("columns").IsArray() {
+				definitions = append(definitions, ymlIndexSpec(section.Kind, key.String(), value).Definition())
+			}
+			return true
+		})
+	}
 
-				} else {
-					if !value.Get("default").Exists() || isNoDefaultType(columnType) {
-						if isNoDefaultType(columnType) {
-							columns = fmt.Sprintf("%s\t%s %s NOT NULL COMMENT '%s' ,\n",
-								columns,
-								key.String(),
-								columnType,
-								comment,
-							)
-						} else {
-							columns = fmt.Sprintf("%s\t%s %s NOT NULL %s COMMENT '%s' ,\n",
-								columns,
-								key.String(),
-								columnType,
-								generator,
-								comment,
-							)
-						}
-
-					} else {
-						columns = fmt.Sprintf("%s\t%s %s DEFAULT '%s'  NOT NULL %s COMMENT '%s' ,\n",
-							columns,
-							key.String(),
-							columnType,
-							def,
-							generator,
-							comment,
-						)
-					}
+	// 如果配置了主键就从主键里招，没有就从ID里
+	if primary_columns := ymlPrimaryColumns(tbl); len(primary_columns) > 0 {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY(%s)", quoteIdents(primary_columns)))
+	}
 
-				}
+	tbl.Get("foreign_keys").ForEach(func(key, value gjson.Result) bool {
+		definitions = append(definitions, ymlForeignKeyDefinition(key.String(), value))
+		return true
+	})
 
-			} else {
-				fmt.Printf("\x1b[%dm 表: %s 的 %s 不正确\x1b[0m\n", 31, tname, key.String())
-				panic("配置文件不正确")
-			}
+	tbl.Get("checks").ForEach(func(key, value gjson.Result) bool {
+		definitions = append(definitions, checkDefinition(key.String(), ymlCheckExpression(value), ymlCheckEnforced(value)))
+		return true
+	})
 
+	return fmt.Sprintf("CREATE TABLE %s(\n\t%s\n%s", quoteIdent(tname), strings.Join(definitions, ",\n\t"), createSuffix)
+}
+
+// ymlPrimaryColumns 获取主键字段，配置了primary_indexes优先，否则取id下的字段
+func ymlPrimaryColumns(tbl gjson.Result) []string {
+	var primary_columns []string
+	if tbl.Get("primary_indexes").Exists() {
+		for _, col := range tbl.Get("primary_indexes.columns").Array() {
+			if col.String() == "" {
+				continue
+			}
+			primary_columns = append(primary_columns, col.String())
+		}
+	} else if tbl.Get("id").IsObject() {
+		tbl.Get("id").ForEach(func(key, value gjson.Result) bool {
+			if key.String() != "" {
+				primary_columns = append(primary_columns, key.String())
+			}
 			return true
 		})
+	}
+	return primary_columns
+}
+
+// ymlColumnDefinition yml字段对应的列定义，如 `name` varchar(255) NOT NULL DEFAULT 'x' COMMENT '名称'
+func ymlColumnDefinition(name string, value gjson.Result) string {
+	columnType := getTypeYml2SqlMapping(value.Get("type").String())
+	nullable := value.Get("nullable").Bool()
 
+	def := []string{quoteIdent(name), columnType}
+	if charset := charsetDefinition(value.Get("charset").String(), value.Get("collate").String()); charset != "" {
+		def = append(def, charset)
+	}
+	//生成列，如 full_name varchar(255) GENERATED ALWAYS AS (concat(a,b)) VIRTUAL NOT NULL COMMENT ''
+	if expression := value.Get("expression").String(); expression != "" {
+		def = append(def, fmt.Sprintf("GENERATED ALWAYS AS (%s) %s", expression, generatedKind(expression, value.Get("stored").Bool())))
+		if !nullable {
+			def = append(def, "NOT NULL")
+		}
+		def = append(def, commentClause(value.Get("comment").String()))
+		return strings.Join(def, " ")
+	}
+	if !nullable {
+		def = append(def, "NOT NULL")
 	}
+	//有些数据库类型不允许有默认值
+	if !isNoDefaultType(columnType) {
+		if value.Get("default").Exists() {
+			def = append(def, defaultClause(value.Get("default").String()))
+		} else if nullable {
+			def = append(def, "DEFAULT NULL")
+		}
+	}
+	if generator := value.Get("generator").String(); generator != "" {
+		def = append(def, generator)
+	}
+	def = append(def, commentClause(value.Get("comment").String()))
+	return strings.Join(def, " ")
+}
 
-	if tbl.Get("indexes").IsObject() {
-		tbl.Get("indexes").ForEach(func(key, value gjson.Result) bool {
-			if value.Get("columns").IsArray() {
-				indexColumns := value.Get("columns").Array()
-				indexKeys := ""
-				for _, ic := range indexColumns {
-					indexKeys = fmt.Sprintf("%s%s,", indexKeys, ic.String())
-				}
-				indexKeys = indexKeys[:len(indexKeys)-1]
-				columns = fmt.Sprintf("%s\tINDEX %s (%s),\n",
-					columns,
-					key.String(),
-					indexKeys,
-				)
-			}
+var defaultGeneratedRegexp = regexp.MustCompile(`(?i)default_generated`)
 
-			return true
-		})
+// sqlColumnDefinition 数据库现有列的定义，用于展示变更前的状态
+func sqlColumnDefinition(sc information_schema.SqlTableColumns) string {
+	nullable := strings.ToLower(sc.IsNullable) == "yes"
+
+	def := []string{quoteIdent(sc.ColumnName), sc.ColumnType}
+	if sc.CollationName != nil && *sc.CollationName != "" {
+		def = append(def, charsetDefinition(collationCharset(*sc.CollationName), *sc.CollationName))
+	}
+	if sc.GenerationExpression != "" {
+		def = append(def, fmt.Sprintf("GENERATED ALWAYS AS (%s) %s", sc.GenerationExpression,
+			generatedKind(sc.GenerationExpression, strings.Contains(strings.ToUpper(sc.Extra), "STORED"))))
+		if !nullable {
+			def = append(def, "NOT NULL")
+		}
+		def = append(def, commentClause(sc.ColumnComment))
+		return strings.Join(def, " ")
+	}
+	if !nullable {
+		def = append(def, "NOT NULL")
 	}
+	if sc.ColumnDefault != nil {
+		def = append(def, sqlDefaultClause(*sc.ColumnDefault, defaultGeneratedRegexp.MatchString(sc.Extra)))
+	} else if nullable && !isNoDefaultType(sc.DataType) {
+		def = append(def, "DEFAULT NULL")
+	}
+	if extra := strings.TrimSpace(defaultGeneratedRegexp.ReplaceAllString(sc.Extra, "")); extra != "" {
+		def = append(def, extra)
+	}
+	def = append(def, commentClause(sc.ColumnComment))
+	return strings.Join(def, " ")
+}
 
-	if tbl.Get("unique_indexes").IsObject() {
-		tbl.Get("unique_indexes").ForEach(func(key, value gjson.Result) bool {
-			if value.Get("columns").IsArray() {
-				indexColumns := value.Get("columns").Array()
-				indexKeys := ""
-				for _, ic := range indexColumns {
-					indexKeys = fmt.Sprintf("%s%s,", indexKeys, ic.String())
-				}
-				indexKeys = indexKeys[:len(indexKeys)-1]
-				columns = fmt.Sprintf("%s\tUNIQUE INDEX %s (%s),\n",
-					columns,
-					key.String(),
-					indexKeys,
-				)
-			}
+// ymlFulltextParser 全文索引分词器，未配置时默认ngram
+func ymlFulltextParser(value gjson.Result) string {
+	with_parser := value.Get("with_parser").String()
+	if with_parser == "" {
+		with_parser = "ngram"
+	}
+	return with_parser
+}
 
-			return true
-		})
+// ymlPreviousNames 表或字段的 renamed_from，支持字符串或数组
+func ymlPreviousNames(value gjson.Result) []string {
+	renamedFrom := value.Get("renamed_from")
+	if renamedFrom.IsArray() {
+		return gjsonStrings(renamedFrom)
 	}
-	if tbl.Get("fulltext_indexes").IsObject() {
-		tbl.Get("fulltext_indexes").ForEach(func(key, value gjson.Result) bool {
-			if value.Get("columns").IsArray() {
-				indexColumns := value.Get("columns").Array()
-				indexKeys := ""
-				for _, ic := range indexColumns {
-					indexKeys = fmt.Sprintf("%s%s,", indexKeys, ic.String())
-				}
-				indexKeys = indexKeys[:len(indexKeys)-1]
-				columns = fmt.Sprintf("%s\tFULLTEXT INDEX %s (%s),\n",
-					columns,
-					key.String(),
-					indexKeys,
-				)
-			}
+	if renamedFrom.String() != "" {
+		return []string{renamedFrom.String()}
+	}
+	return nil
+}
 
-			return true
-		})
+func gjsonStringSet(r gjson.Result) map[string]bool {
+	values := map[string]bool{}
+	for _, v := range r.Array() {
+		values[v.String()] = true
 	}
+	return values
+}
 
-	if !noId {
-		columns = fmt.Sprintf("%s\t%s\n",
-			columns,
-			primary_key,
-		)
-	} else {
-		columns = columns[:len(columns)-2]
+func gjsonStrings(r gjson.Result) []string {
+	var values []string
+	for _, v := range r.Array() {
+		values = append(values, v.String())
 	}
-	sql = fmt.Sprintf("%s%s%s\n", createPrefix, columns, createSuffix)
-	return sql
+	return values
 }
 
 func isNoDefaultType(dataType string) bool {
 	switch dataType {
 	case "tinytext", "mediumtext", "text", "longtext", "blob", "tinyblob",
-		"mediumblob", "longblob":
+		"mediumblob", "longblob",
+		"geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon", "geometrycollection":
 		return true
 	}
 	return false
 }
 
+// dataTypes yml中 type 允许的基础类型(去掉长度)及其分类：
+// 1 整数 2 浮点 3 布尔 4 字符串/二进制 5 时间 6 空间
+var dataTypes = map[string]int{
+	"int": 1, "integer": 1, "tinyint": 1, "smallint": 1, "mediumint": 1, "bigint": 1,
+	"int unsigned": 1, "integer unsigned": 1, "tinyint unsigned": 1, "smallint unsigned": 1,
+	"mediumint unsigned": 1, "bigint unsigned": 1, "bit": 1,
+	"float": 2, "double": 2, "decimal": 2,
+	"bool": 3, "boolean": 3,
+	"enum": 4, "set": 4, "varchar": 4, "char": 4, "tinytext": 4, "mediumtext": 4, "text": 4, "longtext": 4,
+	"blob": 4, "tinyblob": 4, "mediumblob": 4, "longblob": 4, "binary": 4, "varbinary": 4, "json": 4,
+	"date": 5, "datetime": 5, "timestamp": 5, "time": 5, "year": 5,
+	"geometry": 6, "point": 6, "linestring": 6, "polygon": 6, "multipoint": 6, "multilinestring": 6,
+	"multipolygon": 6, "geometrycollection": 6,
+}
+
+// verifyDataType 返回基础类型的分类，不支持的类型返回0
 func verifyDataType(dataType string) int {
-	switch dataType {
-	case "int", "integer", "tinyint", "smallint", "mediumint", "bigint",
-		"int unsigned", "integer unsigned", "tinyint unsigned", "smallint unsigned",
-		"mediumint unsigned", "bigint unsigned", "bit":
-		return 1
-	case "float", "double", "decimal":
-		return 2
-	case "bool":
-		return 3
-	case "enum", "set", "varchar", "char", "tinytext", "mediumtext", "text", "longtext", "blob", "tinyblob",
-		"mediumblob", "longblob", "binary", "varbinary":
-		return 4
-	case "date", "datetime", "timestamp", "time":
-		return 5
-
-		//不能有默认值的类型
-	}
-
-	return 0
+	return dataTypes[dataType]
 }
 
-func (ts *YamlToSqlHandler) doSqlSafe() *YamlToSqlHandler {
+// printPlan 输出将要执行的结构操作
+func (ts *YamlToSqlHandler) printPlan() {
 	// fmt.Println("您将要执行的结构操作为：")
 	fmt.Printf("\x1b[%dm您将要执行的结构操作为： \x1b[0m\n", 34)
-	for k, v := range ts.sql {
-		vv := strings.ReplaceAll(v, "\n", "")
-		vv = strings.ReplaceAll(vv, " ", "")
-		if vv == "" {
+	for _, tp := range ts.plan.Tables {
+		if tp.IsEmpty() {
 			continue
 		}
-		fmt.Println(">>>>>>>>>>>>>", gjson.Parse(ts.tables[k]).Get("Table.table").String(), ">>>>>>>>>>>>>")
-		fmt.Printf("\x1b[%dm%s \x1b[0m\n", 33, v)
-		fmt.Println("<<<<<<<<<<<<<", gjson.Parse(ts.tables[k]).Get("Table.table").String(), "<<<<<<<<<<<<<")
-	}
-	fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
-	fmt.Printf("\x1b[%dm确认执行请输入[ Y ]： \x1b[0m\n", 34)
-	fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
-	commend := ""
-	fmt.Scanln(&commend)
-	if commend == "Y" || commend == "y" {
-
-		// ts.db.Exec("sql")
-		// tx := ts.db.Begin()
-		errsql := ""
-		err := ts.db.Transaction(func(tx *gorm.DB) error {
-			for _, tsql := range ts.sql {
-				// fmt.Println(">>>>>>>>>>>>>", ts.yamlFileFullPaths[k], ">>>>>>>>>>>>>")
-				// fmt.Printf("\x1b[%dm正在执行sql:\n%s \x1b[0m\n", 34, v)
-				vv := strings.ReplaceAll(tsql, "\n", "")
-				vv = strings.ReplaceAll(vv, " ", "")
-				if vv == "" {
-					continue
-				}
-
-				subsqls := strings.Split(tsql, ";")
-				for _, subsql := range subsqls {
-
-					ss := strings.ReplaceAll(subsql, ";", "")
-					ss = strings.ReplaceAll(ss, " ", "")
-					ss = strings.ReplaceAll(ss, "\n", "")
-					if ss == "" {
-						continue
-					}
-					fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
-					fmt.Printf("\x1b[%dm正在执行sql:\n%s \x1b[0m\n", 34,
-						subsql+";")
-					fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
-					subsql += ";"
-					err := tx.Exec(subsql).Error
-					if err != nil {
-						tx.Rollback()
-						errsql = subsql
-						return err
-					}
-				}
-				// fmt.Println("<<<<<<<<<<<<<", ts.yamlFileFullPaths[k], "<<<<<<<<<<<<<")
-			}
-			// tx.Commit()
-			return nil
-		})
-		if err != nil {
-			fmt.Printf("\x1b[%dm执行sql:\n%s\n时出现错误 \x1b[0m\n", 31, errsql)
-			panic(err)
+		fmt.Println(">>>>>>>>>>>>>", tp.Table, ">>>>>>>>>>>>>")
+		fmt.Printf("\x1b[%dm%s \x1b[0m\n", 33, tp.Sql())
+		fmt.Println("<<<<<<<<<<<<<", tp.Table, "<<<<<<<<<<<<<")
+	}
+	for _, tp := range ts.plan.Tables {
+		for _, op := range tp.Skipped {
+			fmt.Printf("\x1b[%dm已跳过破坏性操作(可在dropped_fields/dropped_indexes/dropped_partitions中声明): %s; \x1b[0m\n", 35, op.Sql)
 		}
-		fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
-		fmt.Printf("\x1b[%dmSQL更新完毕： \x1b[0m\n", 36)
-		fmt.Printf("\x1b[%dm<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<： \x1b[0m\n", 34)
 	}
-
-	return ts
+	for _, sr := range ts.plan.UncoveredShards {
+		fmt.Printf("\x1b[%dm表 %s 的分表模板 %s 未覆盖数据库中的分表: %s \x1b[0m\n", 35, sr.Table, sr.Pattern, strings.Join(sr.Uncovered, ","))
+	}
 }
 
-func (ts *YamlToSqlHandler) doSql() *YamlToSqlHandler {
-	// fmt.Println("您将要执行的结构操作为：")
-	fmt.Printf("\x1b[%dm您将要执行的结构操作为： \x1b[0m\n", 34)
-	for k, v := range ts.sql {
-		vv := strings.ReplaceAll(v, "\n", "")
-		vv = strings.ReplaceAll(vv, " ", "")
-		if vv == "" {
-			continue
-		}
-		fmt.Println(">>>>>>>>>>>>>", ts.yamlFileFullPaths[k], ">>>>>>>>>>>>>")
-		fmt.Printf("\x1b[%dm%s \x1b[0m\n", 33, v)
-		fmt.Println("<<<<<<<<<<<<<", ts.yamlFileFullPaths[k], "<<<<<<<<<<<<<")
+func (ts *YamlToSqlHandler) doSqlSafe() *YamlToSqlHandler {
+	if ts.hasError() || ts.plan == nil {
+		return ts
 	}
-	fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
-	fmt.Printf("\x1b[%dm确认执行请输入[ Y ]： \x1b[0m\n", 34)
-	fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
-
-	errsql := ""
-	err := ts.db.Transaction(func(tx *gorm.DB) error {
-		for _, tsql := range ts.sql {
-
-			vv := strings.ReplaceAll(tsql, "\n", "")
-			vv = strings.ReplaceAll(vv, " ", "")
-			if vv == "" {
-				continue
-			}
+	ts.printPlan()
+	approver := ts.approver
+	if approver == nil {
+		approver = StdinApprover{}
+	}
+	ts.approved = approver.Approve(ts.plan)
+	if !ts.approved {
+		fmt.Printf("\x1b[%dm迁移计划未通过审批，已跳过执行 \x1b[0m\n", 33)
+		return ts
+	}
+	ts.execPlan()
 
-			subsqls := strings.Split(tsql, ";")
-			for _, subsql := range subsqls {
+	return ts
+}
 
-				ss := strings.ReplaceAll(subsql, ";", "")
-				ss = strings.ReplaceAll(ss, " ", "")
-				ss = strings.ReplaceAll(ss, "\n", "")
-				if ss == "" {
-					continue
-				}
-				fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
-				fmt.Printf("\x1b[%dm正在执行sql:\n%s \x1b[0m\n", 34,
-					subsql+";")
-				fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
-				subsql += ";"
-				err := tx.Exec(subsql).Error
-				if err != nil {
-					tx.Rollback()
-					errsql = subsql
-					return err
-				}
-			}
-			// fmt.Println("<<<<<<<<<<<<<", ts.yamlFileFullPaths[k], "<<<<<<<<<<<<<")
-		}
-		// tx.Commit()
-		return nil
-	})
-	if err != nil {
-		fmt.Printf("\x1b[%dm执行sql:\n%s\n时出现错误 \x1b[0m\n", 31, errsql)
-		panic(err)
+func (ts *YamlToSqlHandler) doSql() *YamlToSqlHandler {
+	if ts.hasError() || ts.plan == nil {
+		return ts
 	}
-
-	fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
-	fmt.Printf("\x1b[%dmSQL更新完毕： \x1b[0m\n", 36)
-	fmt.Printf("\x1b[%dm<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<： \x1b[0m\n", 34)
+	ts.printPlan()
+	ts.execPlan()
 
 	return ts
 }
 
-func (ts *YamlToSqlHandler) getGetChangeTableSql(tbl gjson.Result, sqlTbl information_schema.SqlTable) string {
+// getChangeTablePlan 对比数据库中已有的表，计算变更操作
+func (ts *YamlToSqlHandler) getChangeTablePlan(tbl gjson.Result, sqlTbl information_schema.SqlTable, file string) TablePlan {
 	tname := tbl.Get("table").String()
-	sql := "\n"
+	tp := TablePlan{Table: tname, File: file, Algorithm: ts.tableAlgorithm(tbl), Lock: ts.tableLock(tbl)}
+	//删除操作统一放到最后
+	var drops []PlanOperation
+
+	//数据库中的表名与配置不同说明是通过 renamed_from 找到的旧表，先改名
+	if sqlTbl.TableName != tname {
+		tp.add(PlanOperation{
+			Type:          OP_RENAME_TABLE,
+			Name:          tname,
+			OldDefinition: sqlTbl.TableName,
+			NewDefinition: tname,
+			Reason:        "改名/",
+			Sql:           fmt.Sprintf("RENAME TABLE %s TO %s", quoteIdent(sqlTbl.TableName), quoteIdent(tname)),
+		})
+	}
+
+	//字符集、存储引擎、行格式
+	tableOptionOps, converting := getTableOptionOps(tbl, sqlTbl)
+	for _, op := range tableOptionOps {
+		tp.add(op)
+	}
+
 	if sqlTbl.TableComment != tbl.Get("options.comment").String() {
-		sql = fmt.Sprintf("%sALTER TABLE %s comment '%s';\n", sql, tname, tbl.Get("options.comment").String())
+		tp.add(PlanOperation{
+			Type:          OP_ALTER_TABLE,
+			Name:          "comment",
+			OldDefinition: commentClause(sqlTbl.TableComment),
+			NewDefinition: commentClause(tbl.Get("options.comment").String()),
+			Reason:        "备注/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s %s", quoteIdent(tname), commentClause(tbl.Get("options.comment").String())),
+			Clause:        commentClause(tbl.Get("options.comment").String()),
+		})
+	}
+	//外键先删除，避免修改外键字段时失败
+	fkDrops, fkAdds, err := ts.getForeignKeyOps(tbl, sqlTbl)
+	if err != nil {
+		ts.addQueryError(file, tname, "读取外键失败", err)
+		return TablePlan{Table: tname, File: file}
+	}
+	for _, op := range fkDrops {
+		tp.add(op)
+	}
+	//检查约束同样先删除，避免修改字段时约束校验失败
+	checkDrops, checkAdds, err := ts.getCheckOps(tbl, sqlTbl)
+	if err != nil {
+		ts.addQueryError(file, tname, "读取检查约束失败", err)
+		return TablePlan{Table: tname, File: file}
+	}
+	for _, op := range checkDrops {
+		tp.add(op)
 	}
+
 	//行
 	//计算sql行
+	//读取失败时不能当作没有字段，否则会重复添加全部字段
 	var sqlColumns []information_schema.SqlTableColumns
-	ts.db.Table("`INFORMATION_SCHEMA`.`COLUMNS`").
-		Where("TABLE_SCHEMA=database()").
-		Where("TABLE_NAME=?", tname).
-		Find(&sqlColumns)
+	err = ts.db.Table("`INFORMATION_SCHEMA`.`COLUMNS`").
+		Where("TABLE_SCHEMA=?", ts.schema).
+		Where("TABLE_NAME=?", sqlTbl.TableName).
+		Order("ORDINAL_POSITION").
+		Find(&sqlColumns).Error
+	if err != nil {
+		ts.addQueryError(file, tname, "读取字段失败", err)
+		return TablePlan{Table: tname, File: file}
+	}
 	var sqlColumnsSerialize = information_schema.SqlColumnsSerialize{}
-	// sqlColumnsSerialize = map[string]map[string]string{}
+	var sqlColumnsMap = map[string]information_schema.SqlTableColumns{}
 	for _, sc := range sqlColumns {
-		// if strings.ToLower(sc.ColumnName) == "id" {
-		// 	continue
-		// }
+		sqlColumnsMap[sc.ColumnName] = sc
 		if sqlColumnsSerialize[sc.ColumnName] == nil {
 			sqlColumnsSerialize[sc.ColumnName] = map[string]string{}
 		}
-		// if !strings.Contains(sc.ColumnType, "varchar") && strings.Contains(sc.ColumnType, "(") {
-		// 	b := strings.Index(sc.ColumnType, "(")
-		// 	e := strings.Index(sc.ColumnType, ")")
-		// 	sc.ColumnType = sc.ColumnType[:b] + sc.ColumnType[e+1:]
-		// }
 		sc.ColumnType = getTypeYml2SqlMapping(sc.ColumnType)
 
 		sqlColumnsSerialize[sc.ColumnName]["type"] = sc.ColumnType
@@ -713,35 +815,65 @@ func (ts *YamlToSqlHandler) getGetChangeTableSql(tbl gjson.Result, sqlTbl inform
 		}
 
 		sqlColumnsSerialize[sc.ColumnName]["comment"] = sc.ColumnComment
-		if sc.ColumnDefault == nil {
-			// sqlColumnsSerialize[sc.ColumnName]["default"] = ""
-		} else {
+		if sc.ColumnDefault != nil {
 			sqlColumnsSerialize[sc.ColumnName]["default"] = *sc.ColumnDefault
 		}
 		sqlColumnsSerialize[sc.ColumnName]["generator"] = sc.Extra
+		if sc.CollationName != nil {
+			sqlColumnsSerialize[sc.ColumnName]["collate"] = *sc.CollationName
+		}
+		if sc.CharacterSetName != nil {
+			sqlColumnsSerialize[sc.ColumnName]["charset"] = *sc.CharacterSetName
+		}
+		//生成列的 EXTRA 为 VIRTUAL GENERATED/STORED GENERATED，不是 generator
+		if sc.GenerationExpression != "" {
+			sqlColumnsSerialize[sc.ColumnName]["generator"] = ""
+			sqlColumnsSerialize[sc.ColumnName]["expression"] = sc.GenerationExpression
+			sqlColumnsSerialize[sc.ColumnName]["kind"] = generatedKind(sc.GenerationExpression, strings.Contains(strings.ToUpper(sc.Extra), "STORED"))
+		}
 	}
 	sqlColumnsJ, err := json.Marshal(&sqlColumnsSerialize)
 	if err != nil {
-		fmt.Printf("\x1b[%dm 表: %s 序列化失败 \x1b[0m\n", 31, tname)
-		panic("配置文件不正确")
+		ts.addError(&SchemaError{Code: ERR_CODE_SERIALIZE, File: file, Table: tname, Message: "序列化失败", Err: err})
+		return tp
 	}
+	//字段改名：新字段不存在而 renamed_from 中的旧字段存在
+	renames := map[string]string{}
+	tbl.Get("fields").ForEach(func(key, value gjson.Result) bool {
+		if _, ok := sqlColumnsMap[key.String()]; ok {
+			return true
+		}
+		for _, oldName := range ymlPreviousNames(value) {
+			if _, ok := sqlColumnsMap[oldName]; ok && !tbl.Get("fields."+oldName).Exists() {
+				renames[oldName] = key.String()
+				break
+			}
+		}
+		return true
+	})
+
 	//计算删除和修改
-	// var dropCloumns map[string]bool = map[string]bool{}
-	// dropCloumns
-	dropColumnsSql := ""
 	sqlColumnsgj := gjson.Parse(string(sqlColumnsJ))
-	// fmt.Println(tbl.String())
 	sqlColumnsgj.ForEach(func(key, value gjson.Result) bool {
-		if tbl.Get("fields." + key.String()).Exists() {
+		field := tbl.Get("fields." + key.String())
+		if newName, ok := renames[key.String()]; ok {
+			newDefinition := ymlColumnDefinition(newName, tbl.Get("fields."+newName))
+			tp.add(PlanOperation{
+				Type:          OP_RENAME_COLUMN,
+				Name:          newName,
+				OldDefinition: sqlColumnDefinition(sqlColumnsMap[key.String()]),
+				NewDefinition: newDefinition,
+				Reason:        "改名/",
+				Sql:           fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s", quoteIdent(tname), quoteIdent(key.String()), newDefinition),
+				Clause:        fmt.Sprintf("CHANGE COLUMN %s %s", quoteIdent(key.String()), newDefinition),
+			})
+		} else if field.Exists() {
 			var refresh bool
 			var yy string
 			//类型
-			if tbl.Get("fields." + key.String() + ".type").Exists() {
-				ymlt := tbl.Get("fields." + key.String() + ".type").String()
+			if field.Get("type").Exists() {
+				ymlt := field.Get("type").String()
 				ymlt = strings.ReplaceAll(ymlt, "integer", "int")
-				// if ymlt == "varchar" {
-				// 	ymlt = "varchar(255)"
-				// }
 				ymlt = getTypeYml2SqlMapping(ymlt)
 				sqlt := value.Get("type").String()
 				sqlt = strings.ReplaceAll(sqlt, "integer", "int")
@@ -752,14 +884,14 @@ func (ts *YamlToSqlHandler) getGetChangeTableSql(tbl gjson.Result, sqlTbl inform
 					yy += "类型/"
 				}
 			} else {
-				fmt.Printf("\x1b[%dm 表: %s 配置文件不正确 \x1b[0m\n", 31, tname)
-				panic("配置文件不正确")
+				ts.addError(&SchemaError{Code: ERR_CODE_INVALID_FIELD, File: file, Table: tname, Field: key.String(), Message: "缺少字段类型"})
+				return true
 			}
 
 			//nullable
-			if tbl.Get("fields." + key.String() + ".nullable").Exists() {
+			if field.Get("nullable").Exists() {
 				if strings.Compare(strings.ToLower(value.Get("nullable").String()),
-					strings.ToLower(tbl.Get("fields."+key.String()+".nullable").String())) != 0 {
+					strings.ToLower(field.Get("nullable").String())) != 0 {
 					refresh = true
 					yy += "空不空/"
 				}
@@ -771,17 +903,30 @@ func (ts *YamlToSqlHandler) getGetChangeTableSql(tbl gjson.Result, sqlTbl inform
 			}
 
 			//comment
-			if tbl.Get("fields." + key.String() + ".comment").Exists() {
-				if strings.Compare(value.Get("comment").String(),
-					tbl.Get("fields."+key.String()+".comment").String()) != 0 {
-					refresh = true
-					yy += "备注/"
-				}
-			} else {
-				if value.Get("comment").String() != "" {
-					refresh = true
-					yy += "备注/"
-				}
+			if strings.Compare(value.Get("comment").String(), field.Get("comment").String()) != 0 {
+				refresh = true
+				yy += "备注/"
+			}
+
+			//字符集
+			if columnCharsetChanged(tbl, field, value.Get("charset").String(), value.Get("collate").String(), converting) {
+				refresh = true
+				yy += "字符集/"
+			}
+
+			//生成列
+			ymlKind := generatedKind(field.Get("expression").String(), field.Get("stored").Bool())
+			sqlKind := value.Get("kind").String()
+			var recreate bool
+			if ymlKind != sqlKind {
+				refresh = true
+				yy += "生成列/"
+				//虚拟列与其他类型之间不能直接修改，需要删除后重新添加
+				recreate = ymlKind == "VIRTUAL" || sqlKind == "VIRTUAL"
+			} else if ymlKind != "" &&
+				normalizeSqlExpression(field.Get("expression").String()) != normalizeSqlExpression(value.Get("expression").String()) {
+				refresh = true
+				yy += "生成列/"
 			}
 
 			//default
@@ -789,655 +934,353 @@ func (ts *YamlToSqlHandler) getGetChangeTableSql(tbl gjson.Result, sqlTbl inform
 			//有些数据库类型不允许有默认值
 			if !isNoDefaultType(value.Get("type").String()) {
 				if strings.ToLower(value.Get("default").String()) == "current_timestamp" &&
-					strings.ToLower(value.Get("generator").String()) == "default_generated" {
-					if tbl.Get("fields." + key.String() + ".generator").Exists() {
-						if strings.ToLower(tbl.Get("fields."+key.String()+".generator").String()) !=
-							"default current_timestamp" {
-							refresh = true
-							yy += "默认/"
-						}
-					} else {
-						// fmt.Println(tbl)
-						// fmt.Println(tbl.Get("fields." + key.String() + ".generator"))
+					strings.HasPrefix(strings.ToLower(value.Get("generator").String()), "default_generated") {
+					expected := "default current_timestamp"
+					if strings.Contains(strings.ToLower(value.Get("generator").String()), "on update current_timestamp") {
+						expected += " on update current_timestamp"
+					}
+					if strings.ToLower(field.Get("generator").String()) != expected {
 						refresh = true
 						yy += "默认/"
 					}
 				} else {
-					if tbl.Get("fields." + key.String() + ".default").Exists() {
-						if value.Get("default").Exists() {
-							if strings.Compare(value.Get("default").String(),
-								tbl.Get("fields."+key.String()+".default").String()) != 0 {
-								refresh = true
-								yy += "默认/"
-							}
-						} else {
-							refresh = true
-							yy += "默认/"
-						}
-					} else {
-						if value.Get("default").String() != "" {
-							refresh = true
-							yy += "默认/"
-						}
+					//MySQL 5.7 的 CURRENT_TIMESTAMP 默认值没有 DEFAULT_GENERATED 标记
+					sqlGenerated := defaultGeneratedRegexp.MatchString(value.Get("generator").String()) ||
+						defaultKeywordRegexp.MatchString(value.Get("default").String())
+					if !sameDefault(field, value.Get("default"), sqlGenerated) {
+						refresh = true
+						yy += "默认/"
 					}
 				}
 			}
 
 			//generator
 			sqlg := strings.ToLower(value.Get("generator").String())
-			if tbl.Get("fields." + key.String() + ".generator").Exists() {
-				// if !strings.Contains(sqlg, "default_generated") {
-				// 	if strings.Compare(sqlg,
-				// 		strings.ToLower(tbl.Get("fields."+key.String()+".generator").String())) != 0 {
-				// 		refresh = true
-				// 		yy += "自动/"
-				// 	}
-				// }
-
-			} else {
+			if !field.Get("generator").Exists() {
 				if value.Get("generator").String() != "" && !strings.Contains(sqlg, "default_generated") {
 					refresh = true
 					yy += "自动/"
 				}
 			}
-			if refresh {
-				ymlt := tbl.Get("fields." + key.String() + ".type").String()
-				// if ymlt == "varchar" {
-				// 	ymlt = "varchar(255)"
-				// }
-				ymlt = getTypeYml2SqlMapping(ymlt)
-				if isNoDefaultType(ymlt) {
-					if tbl.Get("fields."+key.String()+".nullable").Exists() &&
-						tbl.Get("fields."+key.String()+".nullable").String() == "true" {
-						sql = fmt.Sprintf("%sALTER TABLE %s MODIFY COLUMN %s %s %s COMMENT '%s';\n",
-							sql,
-							tname,
-							key.String(),
-							ymlt,
-							tbl.Get("fields."+key.String()+".generator").String(),
-							tbl.Get("fields."+key.String()+".comment").String(),
-						)
-					} else {
-
-						sql = fmt.Sprintf("%sALTER TABLE %s MODIFY COLUMN %s %s NOT NULL %s COMMENT '%s';\n",
-							sql,
-							tname,
-							key.String(),
-							ymlt,
-							tbl.Get("fields."+key.String()+".generator").String(),
-							tbl.Get("fields."+key.String()+".comment").String(),
-						)
-					}
-
-				} else {
-
-					if tbl.Get("fields."+key.String()+".nullable").Exists() &&
-						tbl.Get("fields."+key.String()+".nullable").String() == "true" {
-						if tbl.Get("fields." + key.String() + ".default").Exists() {
-							sql = fmt.Sprintf("%sALTER TABLE %s MODIFY COLUMN %s %s %s DEFAULT '%s' COMMENT '%s';\n",
-								sql,
-								tname,
-								key.String(),
-								ymlt,
-								tbl.Get("fields."+key.String()+".generator").String(),
-								tbl.Get("fields."+key.String()+".default").String(),
-								tbl.Get("fields."+key.String()+".comment").String(),
-							)
-						} else {
-							sql = fmt.Sprintf("%sALTER TABLE %s MODIFY COLUMN %s %s %s DEFAULT NULL COMMENT '%s';\n",
-								sql,
-								tname,
-								key.String(),
-								ymlt,
-								tbl.Get("fields."+key.String()+".generator").String(),
-								// tbl.Get("fields."+key.String()+".default").String(),
-								tbl.Get("fields."+key.String()+".comment").String(),
-							)
-						}
-
-					} else {
-
-						if tbl.Get("fields." + key.String() + ".default").Exists() {
-							sql = fmt.Sprintf("%sALTER TABLE %s MODIFY COLUMN %s %s NOT NULL %s DEFAULT '%s' COMMENT '%s';\n",
-								sql,
-								tname,
-								key.String(),
-								ymlt,
-								tbl.Get("fields."+key.String()+".generator").String(),
-								tbl.Get("fields."+key.String()+".default").String(),
-								tbl.Get("fields."+key.String()+".comment").String(),
-							)
-						} else {
-							sql = fmt.Sprintf("%sALTER TABLE %s MODIFY COLUMN %s %s NOT NULL %s COMMENT '%s';\n",
-								sql,
-								tname,
-								key.String(),
-								ymlt,
-								tbl.Get("fields."+key.String()+".generator").String(),
-								// tbl.Get("fields."+key.String()+".default").String(),
-								tbl.Get("fields."+key.String()+".comment").String(),
-							)
-						}
-
-					}
-
-				}
-
-				// fmt.Println(">>>>>>更新行==")
-				// fmt.Println("库= ", key.String())
-				// fmt.Println("yml= ", tbl.Get("fields."+key.String()).String())
-				// fmt.Println("sql= ", value.String())
-				// fmt.Println("原因：", yy)
-				// fmt.Println("<<<<<===")
-			} else {
-
-				// fmt.Println(">>>>>>保留行==")
-				// fmt.Println("库= ", key.String())
-				// fmt.Println("yml= ", tbl.Get("fields."+key.String()).String())
-				// fmt.Println("sql= ", value.String())
-				// fmt.Println("原因：", yy)
-				// fmt.Println("<<<<<===")
+			if refresh && recreate {
+				newDefinition := ymlColumnDefinition(key.String(), field)
+				position := columnPosition(ymlColumnNames(tbl), key.String())
+				tp.add(PlanOperation{
+					Type:          OP_DROP_COLUMN,
+					Name:          key.String(),
+					OldDefinition: sqlColumnDefinition(sqlColumnsMap[key.String()]),
+					Reason:        yy,
+					Sql:           fmt.Sprintf("ALTER TABLE %s DROP %s", quoteIdent(tname), quoteIdent(key.String())),
+					Clause:        fmt.Sprintf("DROP %s", quoteIdent(key.String())),
+				})
+				tp.add(PlanOperation{
+					Type:          OP_ADD_COLUMN,
+					Name:          key.String(),
+					NewDefinition: newDefinition,
+					Reason:        yy,
+					Sql:           fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(tname), newDefinition, position),
+					Clause:        fmt.Sprintf("ADD COLUMN %s %s", newDefinition, position),
+				})
+			} else if refresh {
+				newDefinition := ymlColumnDefinition(key.String(), field)
+				tp.add(PlanOperation{
+					Type:          OP_MODIFY_COLUMN,
+					Name:          key.String(),
+					OldDefinition: sqlColumnDefinition(sqlColumnsMap[key.String()]),
+					NewDefinition: newDefinition,
+					Reason:        yy,
+					Sql:           fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", quoteIdent(tname), newDefinition),
+					Clause:        fmt.Sprintf("MODIFY COLUMN %s", newDefinition),
+				})
 			}
 
 		} else if tbl.Get("id." + key.String()).Exists() {
 			//主键区，暂时用不上
 		} else {
-			dropColumnsSql = fmt.Sprintf("%sALTER  TABLE %s DROP %s;\n",
-				dropColumnsSql,
-				tname,
-				key.String(),
-			)
+			drops = append(drops, PlanOperation{
+				Type:          OP_DROP_COLUMN,
+				Table:         tname,
+				Name:          key.String(),
+				OldDefinition: sqlColumnDefinition(sqlColumnsMap[key.String()]),
+				Reason:        "配置中已不存在/",
+				Sql:           fmt.Sprintf("ALTER TABLE %s DROP %s", quoteIdent(tname), quoteIdent(key.String())),
+				Clause:        fmt.Sprintf("DROP %s", quoteIdent(key.String())),
+				Destructive:   true,
+			})
 		}
 
 		return true
 	})
 
+	//字段顺序
+	columnNames := ymlColumnNames(tbl)
+	if ts.fixColumnOrder {
+		ts.addColumnMoves(&tp, tbl, sqlColumns, renames, columnNames)
+	}
+
 	//计算新增
+	renamed := map[string]bool{}
+	for _, newName := range renames {
+		renamed[newName] = true
+	}
 	tbl.Get("fields").ForEach(func(key, value gjson.Result) bool {
-
-		if !sqlColumnsgj.Get(key.String()).Exists() {
-
-			ymlt := value.Get("type").String()
-			// if ymlt == "varchar" {
-			// 	ymlt = "varchar(255)"
-			// }
-			ymlt = getTypeYml2SqlMapping(ymlt)
-			if isNoDefaultType(ymlt) {
-				if value.Get("nullable").Exists() &&
-					value.Get("nullable").String() != "true" {
-					sql = fmt.Sprintf("%sALTER TABLE %s ADD COLUMN %s %s NOT NULL %s COMMENT '%s';\n",
-						sql,
-						tname,
-						key.String(),
-						ymlt,
-						tbl.Get("fields."+key.String()+".generator").String(),
-						tbl.Get("fields."+key.String()+".comment").String(),
-					)
-				} else {
-					sql = fmt.Sprintf("%sALTER TABLE %s ADD COLUMN %s %s %s COMMENT '%s';\n",
-						sql,
-						tname,
-						key.String(),
-						ymlt,
-						tbl.Get("fields."+key.String()+".generator").String(),
-						tbl.Get("fields."+key.String()+".comment").String(),
-					)
-				}
-
-			} else {
-
-				if value.Get("nullable").Exists() &&
-					value.Get("nullable").String() != "true" {
-					if value.Get("default").Exists() {
-						sql = fmt.Sprintf("%sALTER TABLE %s ADD COLUMN %s %s NOT NULL %s DEFAULT '%s' COMMENT '%s';\n",
-							sql,
-							tname,
-							key.String(),
-							ymlt,
-							tbl.Get("fields."+key.String()+".generator").String(),
-							tbl.Get("fields."+key.String()+".default").String(),
-							tbl.Get("fields."+key.String()+".comment").String(),
-						)
-					} else {
-						sql = fmt.Sprintf("%sALTER TABLE %s ADD COLUMN %s %s NOT NULL %s COMMENT '%s';\n",
-							sql,
-							tname,
-							key.String(),
-							ymlt,
-							tbl.Get("fields."+key.String()+".generator").String(),
-							// tbl.Get("fields."+key.String()+".default").String(),
-							tbl.Get("fields."+key.String()+".comment").String(),
-						)
-					}
-
-				} else {
-					if value.Get("default").Exists() {
-						sql = fmt.Sprintf("%sALTER TABLE %s ADD COLUMN %s %s %s DEFAULT '%s' COMMENT '%s';\n",
-							sql,
-							tname,
-							key.String(),
-							ymlt,
-							tbl.Get("fields."+key.String()+".generator").String(),
-							tbl.Get("fields."+key.String()+".default").String(),
-							tbl.Get("fields."+key.String()+".comment").String(),
-						)
-					} else {
-						sql = fmt.Sprintf("%sALTER TABLE %s ADD COLUMN %s %s %s DEFAULT NULL COMMENT '%s';\n",
-							sql,
-							tname,
-							key.String(),
-							ymlt,
-							tbl.Get("fields."+key.String()+".generator").String(),
-							// tbl.Get("fields."+key.String()+".default").String(),
-							tbl.Get("fields."+key.String()+".comment").String(),
-						)
-					}
-
-				}
-
-			}
-
+		if !sqlColumnsgj.Get(key.String()).Exists() && !renamed[key.String()] {
+			newDefinition := ymlColumnDefinition(key.String(), value)
+			position := columnPosition(columnNames, key.String())
+			tp.add(PlanOperation{
+				Type:          OP_ADD_COLUMN,
+				Name:          key.String(),
+				NewDefinition: newDefinition,
+				Sql:           fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(tname), newDefinition, position),
+				Clause:        fmt.Sprintf("ADD COLUMN %s %s", newDefinition, position),
+			})
 		}
-
 		return true
 	})
-	// fmt.Println(string(sqlColumnsJ))
-	///
+
 	//索引
+	//读取失败时不能当作没有索引，否则会重复添加全部索引
 	var sqlIndexes []information_schema.SqlIndexes
-	ts.db.Raw(fmt.Sprintf("show indexes from %s", tname)).Scan(&sqlIndexes)
-
-	var sqlIndexesSerialize information_schema.SqlIndexesSerialize
-	sqlIndexesSerialize.UnqIndexes = map[string]map[string][]string{}
-	sqlIndexesSerialize.Indexes = map[string]map[string][]string{}
-	sqlIndexesSerialize.FulltextIndexes = map[string]map[string][]string{}
-	sqlIndexesSerialize.PrimaryIndexes = map[string][]string{}
-
-	//计算sql
-	for _, sqlIndex := range sqlIndexes {
-		if strings.ToLower(sqlIndex.Key_name) == "primary" {
-			sqlIndexesSerialize.PrimaryIndexes["columns"] = append(sqlIndexesSerialize.PrimaryIndexes["columns"], sqlIndex.Column_name)
-			continue
-		}
-		if strings.ToLower(sqlIndex.IndexType) == "fulltext" {
-			if sqlIndexesSerialize.FulltextIndexes[sqlIndex.Key_name] == nil {
-				sqlIndexesSerialize.FulltextIndexes[sqlIndex.Key_name] = map[string][]string{}
-			}
-			sqlIndexesSerialize.FulltextIndexes[sqlIndex.Key_name]["columns"] = append(sqlIndexesSerialize.FulltextIndexes[sqlIndex.Key_name]["columns"], sqlIndex.Column_name)
-		} else if strings.ToLower(sqlIndex.IndexType) == "btree" {
-			if sqlIndex.Non_unique == 0 {
-				// fmt.Println(sqlIndex.Key_name)
-				if sqlIndexesSerialize.UnqIndexes[sqlIndex.Key_name] == nil {
-					sqlIndexesSerialize.UnqIndexes[sqlIndex.Key_name] = map[string][]string{}
-				}
-				sqlIndexesSerialize.UnqIndexes[sqlIndex.Key_name]["columns"] = append(sqlIndexesSerialize.UnqIndexes[sqlIndex.Key_name]["columns"], sqlIndex.Column_name)
-			}
-			if sqlIndex.Non_unique == 1 {
-				// fmt.Println(sqlIndex.Key_name)
-				if sqlIndexesSerialize.Indexes[sqlIndex.Key_name] == nil {
-					sqlIndexesSerialize.Indexes[sqlIndex.Key_name] = map[string][]string{}
-				}
-				sqlIndexesSerialize.Indexes[sqlIndex.Key_name]["columns"] = append(sqlIndexesSerialize.Indexes[sqlIndex.Key_name]["columns"], sqlIndex.Column_name)
-			}
-		}
+	if err := ts.db.Raw(showIndexesSql(ts.schema, sqlTbl.TableName)).Scan(&sqlIndexes).Error; err != nil {
+		ts.addQueryError(file, tname, "读取索引失败", err)
+		return TablePlan{Table: tname, File: file}
 	}
 
-	sqlIndexesJ, err := json.Marshal(&sqlIndexesSerialize)
-	if err != nil {
-		fmt.Printf("\x1b[%dm 表: %s 序列化失败 \x1b[0m\n", 31, tname)
-		panic("配置文件不正确")
-	}
-	// fmt.Println(string(sqlIndexesJ))
-	// var keepThisKey bool
+	sqlSpecs, sqlPrimary := sqlIndexSpecs(sqlIndexes)
+
 	//计算删除+修改
-	// var dropIndexes map[string]bool = map[string]bool{}
-	dropIndexesSql := ""
-	if gjson.Get(string(sqlIndexesJ), "unique_indexes").Exists() {
-		gjson.Get(string(sqlIndexesJ), "unique_indexes").ForEach(func(key, value gjson.Result) bool {
-			if tbl.Get("unique_indexes." + key.String()).Exists() {
-				if strings.Compare(value.String(), tbl.Get("unique_indexes."+key.String()).String()) == 0 {
-					// keepThisKey = true
-					// fmt.Println(">>>>>>保留==")
-					// fmt.Println("unqkey= ", key.String())
-					// fmt.Println("yml= ", tbl.Get("unique_indexes."+key.String()).String())
-					// fmt.Println("sql= ", value.String())
-					// fmt.Println("<<<<<===")
-				} else {
-					sql = fmt.Sprintf("%sDROP INDEX %s ON %s;\n",
-						sql,
-						key.String(),
-						tname,
-					)
-					sqlcol := ""
-					for _, v := range tbl.Get("unique_indexes." + key.String() + ".columns").Array() {
-						sqlcol = fmt.Sprintf("%s%s,", sqlcol, v)
-					}
-					sqlcol = sqlcol[:len(sqlcol)-1]
-					sql = fmt.Sprintf("%sCREATE UNIQUE INDEX %s ON %s(%s);\n",
-						sql,
-						key.String(),
-						tname,
-						sqlcol,
-					)
-					// keepThisKey = false
-					// needReaddKey[key.String()] = true
+	for _, section := range ymlIndexSections {
+		for _, name := range sortedIndexNames(sqlSpecs[section.Name]) {
+			sqlSpec := sqlSpecs[section.Name][name]
+			ymlIndex := tbl.Get(section.Name + "." + name)
+			oldDefinition := sqlSpec.Definition()
+			dropIndex := PlanOperation{
+				Type:          OP_DROP_INDEX,
+				Table:         tname,
+				Name:          name,
+				OldDefinition: oldDefinition,
+				Sql:           fmt.Sprintf("DROP INDEX %s ON %s", quoteIdent(name), quoteIdent(tname)),
+				Clause:        fmt.Sprintf("DROP INDEX %s", quoteIdent(name)),
+			}
+			if !ymlIndex.Exists() {
+				//添加外键时 MySQL 自动创建的同名索引
+				if tbl.Get("foreign_keys." + name).Exists() {
+					continue
 				}
-			} else {
-				dropIndexesSql = fmt.Sprintf("%sDROP INDEX %s ON %s;\n",
-					dropIndexesSql,
-					key.String(),
-					tname,
-				)
-				// if value.Get("columns")
-				// keepThisKey = false
+				dropIndex.Reason = "配置中已不存在/"
+				dropIndex.Destructive = true
+				drops = append(drops, dropIndex)
+				continue
 			}
-			return true
-		})
+			spec := ymlIndexSpec(section.Kind, name, ymlIndex)
+			if section.Name == "fulltext_indexes" {
+				spec.Parser = ymlFulltextParser(ymlIndex)
+			}
+			if sameIndex(spec, sqlSpec, sqlTbl.Engine) {
+				continue
+			}
+			//只有可见性变化时直接修改，不需要重建索引
+			visible := sqlSpec
+			visible.Invisible = spec.Invisible
+			if sameIndex(spec, visible, sqlTbl.Engine) {
+				tp.add(PlanOperation{
+					Type:          OP_ALTER_INDEX,
+					Table:         tname,
+					Name:          name,
+					OldDefinition: indexVisibility(sqlSpec.Invisible),
+					NewDefinition: indexVisibility(spec.Invisible),
+					Reason:        "索引可见性/",
+					Sql:           fmt.Sprintf("ALTER TABLE %s ALTER INDEX %s %s", quoteIdent(tname), quoteIdent(name), indexVisibility(spec.Invisible)),
+					Clause:        fmt.Sprintf("ALTER INDEX %s %s", quoteIdent(name), indexVisibility(spec.Invisible)),
+				})
+				continue
+			}
+
+			newDefinition := spec.Definition()
+			dropIndex.NewDefinition = newDefinition
+			dropIndex.Reason = "索引字段/"
+			tp.add(dropIndex)
+			tp.add(PlanOperation{
+				Type:          OP_ADD_INDEX,
+				Name:          name,
+				OldDefinition: oldDefinition,
+				NewDefinition: newDefinition,
+				Reason:        "索引字段/",
+				Sql:           spec.CreateSql(tname),
+				Clause:        fmt.Sprintf("ADD %s", newDefinition),
+			})
+		}
 	}
 
 	// 主键搜索，如果yml配置了primary_keys，则进行维护否则跳过
 	if tbl.Get("primary_indexes").Exists() {
 		primary_indexes := tbl.Get("primary_indexes.columns")
-		sql_primary_indexes := gjson.ParseBytes(sqlIndexesJ).Get("primary_indexes.columns")
-		if primary_indexes.String() != sql_primary_indexes.String() {
-
-			//仅删除
-			__f_drop := func() {
-				dropIndexesSql = fmt.Sprintf("%sALTER TABLE %s DROP PRIMARY KEY;\n",
-					dropIndexesSql,
-					tname,
-				)
+		if strings.Join(gjsonStrings(primary_indexes), ",") != strings.Join(sqlPrimary, ",") {
+			op := PlanOperation{
+				Type:   OP_CHANGE_PRIMARY_KEY,
+				Table:  tname,
+				Name:   "PRIMARY",
+				Reason: "主键/",
 			}
-
-			//仅添加
-			__f_add := func() {
-				sqlcol := ""
-				for _, v := range primary_indexes.Array() {
-					sqlcol = fmt.Sprintf("%s%s,", sqlcol, v)
-				}
-				sqlcol = sqlcol[:len(sqlcol)-1]
-
-				sql = fmt.Sprintf("%sALTER TABLE %s ADD PRIMARY KEY (%s);\n",
-					sql,
-					tname,
-					sqlcol,
-				)
+			if len(sqlPrimary) > 0 {
+				op.OldDefinition = fmt.Sprintf("PRIMARY KEY(%s)", quoteIdents(sqlPrimary))
 			}
-
-			// 删除后添加
-			__f_update := func() {
-				sql = fmt.Sprintf("%sALTER TABLE %s DROP PRIMARY KEY;\n",
-					sql,
-					tname,
-				)
-				__f_add()
+			if len(gjsonStrings(primary_indexes)) > 0 {
+				op.NewDefinition = fmt.Sprintf("PRIMARY KEY(%s)", quoteIdents(gjsonStrings(primary_indexes)))
 			}
 
-			// 如果其中一个是空的
-			if primary_indexes.String() == "" || sql_primary_indexes.String() == "" {
-				// 如果yml配置了空，sql非空，则删除,否则添加
-				if primary_indexes.String() == "" && sql_primary_indexes.String() != "" {
-					__f_drop()
-				} else {
-					__f_add()
-				}
-			} else {
-				__f_update()
+			switch {
+			// 如果yml配置了空，sql非空，则删除
+			case op.NewDefinition == "" && op.OldDefinition != "":
+				op.Clause = "DROP PRIMARY KEY"
+				op.Sql = fmt.Sprintf("ALTER TABLE %s %s", quoteIdent(tname), op.Clause)
+				drops = append(drops, op)
+			// 仅添加
+			case op.OldDefinition == "":
+				op.Clause = fmt.Sprintf("ADD %s", op.NewDefinition)
+				op.Sql = fmt.Sprintf("ALTER TABLE %s %s", quoteIdent(tname), op.Clause)
+				tp.add(op)
+			// 删除后添加
+			default:
+				op.Clause = fmt.Sprintf("DROP PRIMARY KEY, ADD %s", op.NewDefinition)
+				op.Sql = fmt.Sprintf("ALTER TABLE %s %s", quoteIdent(tname), op.Clause)
+				tp.add(op)
 			}
 		}
 	}
 
-	if gjson.Get(string(sqlIndexesJ), "fulltext_indexes").Exists() {
-		gjson.Get(string(sqlIndexesJ), "fulltext_indexes").ForEach(func(key, value gjson.Result) bool {
-			if tbl.Get("fulltext_indexes." + key.String()).Exists() {
-				// fmt.Println(tbl.Get("fulltext_indexes." + key.String() + ".columns").String())
-				// fmt.Println(tbl.Get("fulltext_indexes." + key.String() + ".with_parser").String())
-				if strings.Compare(value.Get("columns").String(), tbl.Get("fulltext_indexes."+key.String()+".columns").String()) == 0 {
-					// keepThisKey = true
-					// fmt.Println(">>>>>>保留==")
-					// fmt.Println("unqkey= ", key.String())
-					// fmt.Println("yml= ", tbl.Get("fulltext_indexes."+key.String()).String())
-					// fmt.Println("sql= ", value.String())
-					// fmt.Println("<<<<<===")
-				} else {
-					sql = fmt.Sprintf("%sDROP INDEX %s ON %s;\n",
-						sql,
-						key.String(),
-						tname,
-					)
-					sqlcol := ""
-					for _, v := range tbl.Get("fulltext_indexes." + key.String() + ".columns").Array() {
-						sqlcol = fmt.Sprintf("%s%s,", sqlcol, v)
-					}
-					sqlcol = sqlcol[:len(sqlcol)-1]
-					with_parser := tbl.Get("fulltext_indexes." + key.String() + ".with_parser").String()
-					if with_parser == "" {
-						with_parser = "ngram"
-					}
-					sql = fmt.Sprintf("%sCREATE FULLTEXT INDEX %s ON %s(%s) WITH PARSER %s;\n",
-						sql,
-						key.String(),
-						tname,
-						sqlcol,
-						with_parser,
-					)
-					// keepThisKey = false
-					// needReaddKey[key.String()] = true
-				}
-			} else {
-				dropIndexesSql = fmt.Sprintf("%sDROP INDEX %s ON %s;\n",
-					dropIndexesSql,
-					key.String(),
-					tname,
-				)
-				// if value.Get("columns")
-				// keepThisKey = false
+	//计算新增索引
+	for _, section := range ymlIndexSections {
+		tbl.Get(section.Name).ForEach(func(key, value gjson.Result) bool {
+			if _, ok := sqlSpecs[section.Name][key.String()]; ok {
+				return true
 			}
+			spec := ymlIndexSpec(section.Kind, key.String(), value)
+			if section.Name == "fulltext_indexes" {
+				spec.Parser = ymlFulltextParser(value)
+			}
+			newDefinition := spec.Definition()
+			tp.add(PlanOperation{
+				Type:          OP_ADD_INDEX,
+				Name:          key.String(),
+				NewDefinition: newDefinition,
+				Sql:           spec.CreateSql(tname),
+				Clause:        fmt.Sprintf("ADD %s", newDefinition),
+			})
 			return true
 		})
 	}
-	if gjson.Get(string(sqlIndexesJ), "indexes").Exists() {
-		gjson.Get(string(sqlIndexesJ), "indexes").ForEach(func(key, value gjson.Result) bool {
-			if tbl.Get("indexes." + key.String()).Exists() {
-				if strings.Compare(value.String(), tbl.Get("indexes."+key.String()).String()) == 0 {
 
-					// fmt.Println(">>>>>>保留==")
-					// fmt.Println("unqkey= ", key.String())
-					// fmt.Println("yml= ", tbl.Get("indexes."+key.String()).String())
-					// fmt.Println("sql= ", value.String())
-					// fmt.Println("<<<<<===")
-				} else {
-					sql = fmt.Sprintf("%sDROP INDEX %s ON %s;\n",
-						sql,
-						key.String(),
-						tname,
-					)
-					sqlcol := ""
-					for _, v := range tbl.Get("indexes." + key.String() + ".columns").Array() {
-						sqlcol = fmt.Sprintf("%s%s,", sqlcol, v)
-					}
-					sqlcol = sqlcol[:len(sqlcol)-1]
-					sql = fmt.Sprintf("%sCREATE INDEX %s ON %s(%s);\n",
-						sql,
-						key.String(),
-						tname,
-						sqlcol,
-					)
-
-					// fmt.Println(">>>>>>修改==")
-					// fmt.Println("unqkey= ", key.String())
-					// fmt.Println("yml= ", tbl.Get("indexes."+key.String()).String())
-					// fmt.Println("sql= ", value.String())
-					// fmt.Println("<<<<<===")
-				}
-			} else {
-				dropIndexesSql = fmt.Sprintf("%sDROP INDEX %s ON %s;\n",
-					dropIndexesSql,
-					key.String(),
-					tname,
-				)
-				// fmt.Println(">>>>>>丢弃==")
-				// fmt.Println("unqkey= ", key.String())
-				// fmt.Println("yml= ", tbl.Get("indexes."+key.String()).String())
-				// fmt.Println("sql= ", value.String())
-				// fmt.Println("<<<<<===")
-			}
-			return true
-		})
+	for _, op := range fkAdds {
+		tp.add(op)
+	}
+	for _, op := range checkAdds {
+		tp.add(op)
 	}
-	//计算新增索引
-	tbl.Get("unique_indexes").ForEach(func(key, value gjson.Result) bool {
-		if !gjson.Get(string(sqlIndexesJ), "unique_indexes."+key.String()).Exists() {
-			sqlcol := ""
-			for _, v := range value.Get("columns").Array() {
-				sqlcol = fmt.Sprintf("%s%s,", sqlcol, v)
-			}
-			sqlcol = sqlcol[:len(sqlcol)-1]
-			sql = fmt.Sprintf("%sCREATE UNIQUE INDEX %s ON %s(%s);\n",
-				sql,
-				key.String(),
-				tname,
-				sqlcol,
-			)
-		}
 
-		return true
-	})
-	tbl.Get("fulltext_indexes").ForEach(func(key, value gjson.Result) bool {
-		if !gjson.Get(string(sqlIndexesJ), "fulltext_indexes."+key.String()).Exists() {
-			sqlcol := ""
-			for _, v := range value.Get("columns").Array() {
-				sqlcol = fmt.Sprintf("%s%s,", sqlcol, v)
-			}
-			sqlcol = sqlcol[:len(sqlcol)-1]
+	//分区
+	partitionOps, partitionDrops, err := ts.getPartitionOps(tbl, sqlTbl)
+	if err != nil {
+		ts.addQueryError(file, tname, "读取分区失败", err)
+		return TablePlan{Table: tname, File: file}
+	}
+	for _, op := range partitionOps {
+		tp.add(op)
+	}
+	drops = append(drops, partitionDrops...)
 
-			with_parser := tbl.Get("fulltext_indexes." + key.String() + ".with_parser").String()
-			if with_parser == "" {
-				with_parser = "ngram"
-			}
+	ts.addDrops(&tp, tbl, drops)
 
-			sql = fmt.Sprintf("%sCREATE FULLTEXT INDEX %s ON %s(%s) WITH PARSER %s;\n",
-				sql,
-				key.String(),
-				tname,
-				sqlcol,
-				with_parser,
-			)
-		}
-		return true
-	})
-	tbl.Get("indexes").ForEach(func(key, value gjson.Result) bool {
+	return tp
+}
 
-		if !gjson.Get(string(sqlIndexesJ), "indexes."+key.String()).Exists() {
-			sqlcol := ""
-			for _, v := range value.Get("columns").Array() {
-				sqlcol = fmt.Sprintf("%s%s,", sqlcol, v)
+// addDrops 破坏性的删除只有在yml中显式声明(dropped_fields/dropped_indexes/dropped_partitions)或允许破坏性变更时才执行
+func (ts *YamlToSqlHandler) addDrops(tp *TablePlan, tbl gjson.Result, drops []PlanOperation) {
+	droppedFields := gjsonStringSet(tbl.Get("dropped_fields"))
+	droppedIndexes := gjsonStringSet(tbl.Get("dropped_indexes"))
+	droppedPartitions := gjsonStringSet(tbl.Get("dropped_partitions"))
+	for _, op := range drops {
+		if op.Destructive && !ts.allowDestructive {
+			if (op.Type == OP_DROP_COLUMN && !droppedFields[op.Name]) ||
+				(op.Type == OP_DROP_INDEX && !droppedIndexes[op.Name]) ||
+				(op.Type == OP_DROP_PARTITION && !droppedPartitions[op.Name]) {
+				tp.Skipped = append(tp.Skipped, op)
+				continue
 			}
-			sqlcol = sqlcol[:len(sqlcol)-1]
-			sql = fmt.Sprintf("%sCREATE INDEX %s ON %s(%s);\n",
-				sql,
-				key.String(),
-				tname,
-				sqlcol,
-			)
 		}
-		return true
-	})
-	sql = fmt.Sprintf("%s%s%s", sql, dropIndexesSql, dropColumnsSql)
-
-	return sql
+		tp.add(op)
+	}
 }
 
-// 校验yml的合法行
+// 校验yml的合法行，仅error级别的诊断会作为错误返回
 func (ts *YamlToSqlHandler) verifyYmlFile() *YamlToSqlHandler {
-	for k, table := range ts.tables {
+	if ts.hasError() {
+		return ts
+	}
+	tables := map[string]gjson.Result{}
+	for _, table := range ts.tables {
 		tbJson := gjson.Get(table, "Table")
-		// fieldsMap := map[string]string{}
-		// if !tbJson.Get("id").Exists() {
-		// 	fmt.Printf("\x1b[%dm 配置文件不正确:'%s' \x1b[0m\n", 31, ts.yamlFileFullPaths[k])
-		// 	fmt.Printf("\x1b[%dm 缺少主键id \x1b[0m\n", 31)
-		// 	panic("配置文件不正确")
-		// }
-		// if !tbJson.Get("id.id").Exists() {
-		// 	fmt.Printf("\x1b[%dm 配置文件不正确:'%s' \x1b[0m\n", 31, ts.yamlFileFullPaths[k])
-		// 	fmt.Printf("\x1b[%dm 缺少主键id \x1b[0m\n", 31)
-		// 	panic("配置文件不正确")
-		// }
-		tbJson.Get("indexes").ForEach(func(key, value gjson.Result) bool {
-			if !value.Get("columns").IsArray() {
-				fmt.Printf("\x1b[%dm 配置文件不正确:'%s' \x1b[0m\n", 31, ts.yamlFileFullPaths[k])
-				fmt.Printf("\x1b[%dm indexes:'%s' is not array\x1b[0m\n", 31, key.String())
-				panic("配置文件不正确")
-			}
-			for _, v := range value.Get("columns").Array() {
-				if !tbJson.Get("fields."+v.String()).Exists() && !tbJson.Get("id."+v.String()).Exists() {
-					fmt.Printf("\x1b[%dm 配置文件不正确:'%s' \x1b[0m\n", 31, ts.yamlFileFullPaths[k])
-					fmt.Printf("\x1b[%dm indexes columns:'%s' is not find\x1b[0m\n", 31, v.String())
-					panic("配置文件不正确")
-				}
-			}
-			return true
-		})
-		tbJson.Get("unique_indexes").ForEach(func(key, value gjson.Result) bool {
-			if !value.Get("columns").IsArray() {
-				fmt.Printf("\x1b[%dm 配置文件不正确:'%s' \x1b[0m\n", 31, ts.yamlFileFullPaths[k])
-				fmt.Printf("\x1b[%dm indexes:'%s' is not array\x1b[0m\n", 31, key.String())
-				panic("配置文件不正确")
-			}
-			for _, v := range value.Get("columns").Array() {
-				if !tbJson.Get("fields."+v.String()).Exists() && !tbJson.Get("id."+v.String()).Exists() {
-					fmt.Printf("\x1b[%dm 配置文件不正确:'%s' \x1b[0m\n", 31, ts.yamlFileFullPaths[k])
-					fmt.Printf("\x1b[%dm indexes columns:'%s' is not find\x1b[0m\n", 31, v.String())
-					panic("配置文件不正确")
-				}
-			}
-			return true
-		})
-		tbJson.Get("fulltext_indexes").ForEach(func(key, value gjson.Result) bool {
-			if !value.Get("columns").IsArray() {
-				fmt.Printf("\x1b[%dm 配置文件不正确:'%s' \x1b[0m\n", 31, ts.yamlFileFullPaths[k])
-				fmt.Printf("\x1b[%dm indexes:'%s' is not array\x1b[0m\n", 31, key.String())
-				panic("配置文件不正确")
-			}
-			for _, v := range value.Get("columns").Array() {
-				if !tbJson.Get("fields."+v.String()).Exists() && !tbJson.Get("id."+v.String()).Exists() {
-					fmt.Printf("\x1b[%dm 配置文件不正确:'%s' \x1b[0m\n", 31, ts.yamlFileFullPaths[k])
-					fmt.Printf("\x1b[%dm indexes columns:'%s' is not find\x1b[0m\n", 31, v.String())
-					panic("配置文件不正确")
-				}
+		tables[tbJson.Get("table").String()] = tbJson
+	}
+	for k, table := range ts.tables {
+		file := ts.tableFiles[k]
+		report := func(d Diagnostic, path string) {
+			if d.Severity != SEVERITY_ERROR {
+				return
 			}
-			return true
-		})
+			ts.addError(&SchemaError{Code: d.Code, File: file, Table: d.Table, Field: d.Field, Index: d.Index, Message: d.Message})
+		}
+		checkYmlTable(gjson.Get(table, "Table"), report)
+		checkYmlForeignKeyReferences(gjson.Get(table, "Table"), tables, report)
 	}
 
 	return ts
 }
 
-// ExecuteSchemaSafeCheck 执行根据配置文件同步表结构(安全操作，允许使用者进一步确认)
+// ExecuteSchemaSafeCheck 执行根据配置文件同步表结构(安全操作，允许使用者进一步确认)，出错时panic
 func (ts *YamlToSqlHandler) ExecuteSchemaSafeCheck() *YamlToSqlHandler {
-	ts.connectSql()
-	ts.getyamlFileFullPaths().
+	ts.ExecuteSchemaSafeCheckE()
+	ts.mustNoError()
+	return ts
+}
+
+// ExecuteSchemaSafeCheckE 同 ExecuteSchemaSafeCheck，出错时返回 SchemaErrors 而不是panic
+func (ts *YamlToSqlHandler) ExecuteSchemaSafeCheckE() error {
+	ts.reset().connectSql().
+		getyamlFileFullPaths().
 		getYamlDatas().verifyYmlFile().doSchema().doSqlSafe()
+	return ts.err()
+}
 
+// ExecuteSchema 执行根据配置文件同步表结构，出错时panic
+func (ts *YamlToSqlHandler) ExecuteSchema() *YamlToSqlHandler {
+	ts.ExecuteSchemaE()
+	ts.mustNoError()
 	return ts
 }
 
-// ExecuteSchema 执行根据配置文件同步表结构
-func (ts *YamlToSqlHandler) ExecuteSchema() *YamlToSqlHandler {
-	ts.connectSql()
-	ts.getyamlFileFullPaths().
+// ExecuteSchemaE 同 ExecuteSchema，出错时返回 SchemaErrors 而不是panic
+func (ts *YamlToSqlHandler) ExecuteSchemaE() error {
+	ts.reset().connectSql().
+		getyamlFileFullPaths().
 		getYamlDatas().verifyYmlFile().doSchema().doSql()
-
-	return ts
+	return ts.err()
 }
 
-// LoadSchema 加载编译后的表结构配置信息
+// LoadSchema 加载编译后的表结构配置信息，出错时panic
 func (ts *YamlToSqlHandler) LoadSchema() *YamlToSqlHandler {
-	ts.connectSql()
-	ts.loadFromBuildSchema().verifyYmlFile().doSchema()
+	ts.LoadSchemaE()
+	ts.mustNoError()
 	return ts
 }
 
+// LoadSchemaE 同 LoadSchema，出错时返回 SchemaErrors 而不是panic
+func (ts *YamlToSqlHandler) LoadSchemaE() error {
+	ts.reset().connectSql().
+		loadFromBuildSchema().verifyYmlFile().doSchema()
+	return ts.err()
+}
+
 func (ts *YamlToSqlHandler) trimSql() *YamlToSqlHandler {
 
 	var newsql []string
@@ -1464,12 +1307,19 @@ func (ts *YamlToSqlHandler) GetSql() []string {
 	return ts.sql
 }
 
-// DoSql 执行sql
+// DoSql 执行sql，出错时panic
 func (ts *YamlToSqlHandler) DoSql() *YamlToSqlHandler {
-	ts.doSqlSafe()
+	ts.DoSqlE()
+	ts.mustNoError()
 	return ts
 }
 
+// DoSqlE 同 DoSql，出错时返回 SchemaErrors 而不是panic
+func (ts *YamlToSqlHandler) DoSqlE() error {
+	ts.doSqlSafe()
+	return ts.err()
+}
+
 // 获取数据类型yml对应sql的映射
 func getTypeYml2SqlMapping(t string) string {
 	t = strings.ToLower(t)
@@ -0,0 +1,263 @@
+package dataschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlNodeToJSON 按yaml文档中的顺序将节点转换为json，map 解码会丢失字段顺序
+func yamlNodeToJSON(node *yaml.Node) ([]byte, error) {
+	//空文件与解码到 map 时一致，返回空对象
+	if node.Kind == 0 {
+		return []byte("{}"), nil
+	}
+	var b bytes.Buffer
+	if err := writeYamlNodeJSON(&b, node); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeYamlNodeJSON(b *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) < 1 {
+			b.WriteString("{}")
+			return nil
+		}
+		return writeYamlNodeJSON(b, node.Content[0])
+	case yaml.AliasNode:
+		return writeYamlNodeJSON(b, node.Alias)
+	case yaml.MappingNode:
+		keys, values := yamlMappingEntries(node)
+		b.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			kb, _ := json.Marshal(key)
+			b.Write(kb)
+			b.WriteByte(':')
+			if err := writeYamlNodeJSON(b, values[i]); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	case yaml.SequenceNode:
+		b.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := writeYamlNodeJSON(b, item); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	default:
+		var v interface{}
+		if err := node.Decode(&v); err != nil {
+			return err
+		}
+		vb, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.Write(vb)
+	}
+	return nil
+}
+
+// yamlMappingEntries 按顺序返回 mapping 的键值，展开 << 合并的内容，显式声明的键优先
+func yamlMappingEntries(node *yaml.Node) ([]string, []*yaml.Node) {
+	var keys []string
+	var values []*yaml.Node
+	explicit := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Tag != "!!merge" {
+			explicit[node.Content[i].Value] = true
+		}
+	}
+	seen := map[string]bool{}
+	add := func(key string, value *yaml.Node) {
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Tag != "!!merge" {
+			add(key.Value, value)
+			continue
+		}
+		merged := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			merged = value.Content
+		}
+		for _, m := range merged {
+			for m.Kind == yaml.AliasNode {
+				m = m.Alias
+			}
+			if m.Kind != yaml.MappingNode {
+				continue
+			}
+			mkeys, mvalues := yamlMappingEntries(m)
+			for j, mkey := range mkeys {
+				if !explicit[mkey] {
+					add(mkey, mvalues[j])
+				}
+			}
+		}
+	}
+	return keys, values
+}
+
+// SetFixColumnOrder 设置是否按yml中的顺序调整已有字段的顺序(根据 ORDINAL_POSITION 检测)，默认不调整，
+// 新增字段总是按yml中的顺序通过 FIRST/AFTER 添加
+func (ts *YamlToSqlHandler) SetFixColumnOrder(fix bool) *YamlToSqlHandler {
+	ts.fixColumnOrder = fix
+	return ts
+}
+
+// ymlMergedFields 主键字段在前、普通字段在后，按yml中的顺序合并为一个json对象
+func ymlMergedFields(tbl gjson.Result) string {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for _, section := range []string{"id", "fields"} {
+		tbl.Get(section).ForEach(func(key, value gjson.Result) bool {
+			if b.Len() > 1 {
+				b.WriteByte(',')
+			}
+			b.WriteString(key.Raw)
+			b.WriteByte(':')
+			b.WriteString(value.Raw)
+			return true
+		})
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// ymlColumnNames yml中按顺序排列的字段名
+func ymlColumnNames(tbl gjson.Result) []string {
+	var names []string
+	tbl.Get("fields").ForEach(func(key, value gjson.Result) bool {
+		names = append(names, key.String())
+		return true
+	})
+	return names
+}
+
+// columnPosition 字段在 names 中的位置子句，第一个为 FIRST，其他为 AFTER 前一个字段
+func columnPosition(names []string, name string) string {
+	for i, n := range names {
+		if n != name {
+			continue
+		}
+		if i == 0 {
+			return "FIRST"
+		}
+		return fmt.Sprintf("AFTER %s", quoteIdent(names[i-1]))
+	}
+	return ""
+}
+
+// columnMove 调整字段顺序时需要移动的字段
+type columnMove struct {
+	Name     string
+	Position string // FIRST 或 AFTER xxx
+}
+
+// columnMoves 计算将 current 调整为 expected 顺序需要移动的字段，两者应包含相同的字段
+func columnMoves(expected, current []string) []columnMove {
+	var moves []columnMove
+	current = append([]string{}, current...)
+	for i, name := range expected {
+		if i < len(current) && current[i] == name {
+			continue
+		}
+		for j := range current {
+			if current[j] == name {
+				current = append(current[:j], current[j+1:]...)
+				break
+			}
+		}
+		if i > len(current) {
+			i = len(current)
+		}
+		current = append(current[:i], append([]string{name}, current[i:]...)...)
+		moves = append(moves, columnMove{Name: name, Position: columnPosition(expected, name)})
+	}
+	return moves
+}
+
+// addColumnMoves 按yml中的顺序调整数据库中已有字段的顺序，已有修改操作的字段直接追加位置
+func (ts *YamlToSqlHandler) addColumnMoves(tp *TablePlan, tbl gjson.Result, sqlColumns []information_schema.SqlTableColumns,
+	renames map[string]string, columnNames []string) {
+	sqlColumnsMap := map[string]information_schema.SqlTableColumns{}
+	oldPositions := map[string]string{}
+	var current []string
+	for i, sc := range sqlColumns {
+		name := sc.ColumnName
+		if newName, ok := renames[name]; ok {
+			name = newName
+		}
+		sqlColumnsMap[name] = sc
+		if i == 0 {
+			oldPositions[name] = "FIRST"
+		} else {
+			oldPositions[name] = fmt.Sprintf("AFTER %s", quoteIdent(sqlColumns[i-1].ColumnName))
+		}
+		if tbl.Get("fields." + name).Exists() {
+			current = append(current, name)
+		}
+	}
+	var expected []string
+	for _, name := range columnNames {
+		if _, ok := sqlColumnsMap[name]; ok {
+			expected = append(expected, name)
+		}
+	}
+
+	for _, move := range columnMoves(expected, current) {
+		var changed bool
+		for i := range tp.Operations {
+			op := &tp.Operations[i]
+			//重新添加的生成列已经带有位置
+			if op.Name == move.Name && op.Type == OP_ADD_COLUMN {
+				changed = true
+				break
+			}
+			if op.Name != move.Name || (op.Type != OP_MODIFY_COLUMN && op.Type != OP_RENAME_COLUMN) {
+				continue
+			}
+			op.OldDefinition = fmt.Sprintf("%s %s", op.OldDefinition, oldPositions[move.Name])
+			op.NewDefinition = fmt.Sprintf("%s %s", op.NewDefinition, move.Position)
+			op.Reason += "顺序/"
+			op.Sql = fmt.Sprintf("%s %s", op.Sql, move.Position)
+			op.Clause = fmt.Sprintf("%s %s", op.Clause, move.Position)
+			changed = true
+		}
+		if changed {
+			continue
+		}
+		definition := ymlColumnDefinition(move.Name, tbl.Get("fields."+move.Name))
+		tp.add(PlanOperation{
+			Type:          OP_MODIFY_COLUMN,
+			Name:          move.Name,
+			OldDefinition: fmt.Sprintf("%s %s", sqlColumnDefinition(sqlColumnsMap[move.Name]), oldPositions[move.Name]),
+			NewDefinition: fmt.Sprintf("%s %s", definition, move.Position),
+			Reason:        "顺序/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", quoteIdent(tp.Table), definition, move.Position),
+			Clause:        fmt.Sprintf("MODIFY COLUMN %s %s", definition, move.Position),
+		})
+	}
+}
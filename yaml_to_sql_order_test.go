@@ -0,0 +1,94 @@
+package dataschema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYamlNodeToJSONKeepsOrder(t *testing.T) {
+	var doc yaml.Node
+	err := yaml.Unmarshal([]byte(`Table:
+  table: user
+  base: &base
+    type: varchar
+    comment: 基础
+  fields:
+    zname:
+      <<: *base
+      comment: 名称
+    age:
+      type: int
+      nullable: true
+    created_at:
+      type: datetime
+      default: null
+  tags: [a, 1, true]
+`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jb, err := yamlNodeToJSON(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"Table":{"table":"user","base":{"type":"varchar","comment":"基础"},` +
+		`"fields":{"zname":{"type":"varchar","comment":"名称"},"age":{"type":"int","nullable":true},` +
+		`"created_at":{"type":"datetime","default":null}},"tags":["a",1,true]}}`
+	if string(jb) != want {
+		t.Errorf("Unexpected json:\n%s\nwant:\n%s", jb, want)
+	}
+
+	var empty yaml.Node
+	yaml.Unmarshal([]byte(""), &empty)
+	if jb, _ := yamlNodeToJSON(&empty); string(jb) != "{}" {
+		t.Errorf("Expected empty document to be {}, got %s", jb)
+	}
+}
+
+func TestYmlMergedFields(t *testing.T) {
+	tbl := gjson.Parse(`{"id": {"uid": {"type": "int"}}, "fields": {"name": {"type": "varchar"}, "age": {"type": "int"}}}`)
+	fields := gjson.Parse(ymlMergedFields(tbl))
+	var names []string
+	fields.ForEach(func(key, value gjson.Result) bool {
+		names = append(names, key.String())
+		return true
+	})
+	if !reflect.DeepEqual(names, []string{"uid", "name", "age"}) {
+		t.Errorf("Expected id fields first in yml order, got %v", names)
+	}
+}
+
+func TestColumnPosition(t *testing.T) {
+	names := []string{"id", "name", "age"}
+	if got := columnPosition(names, "id"); got != "FIRST" {
+		t.Errorf("Expected FIRST, got %q", got)
+	}
+	if got := columnPosition(names, "age"); got != "AFTER `name`" {
+		t.Errorf("Expected AFTER `name`, got %q", got)
+	}
+}
+
+func TestColumnMoves(t *testing.T) {
+	cases := []struct {
+		expected []string
+		current  []string
+		want     []columnMove
+	}{
+		{[]string{"id", "name", "age"}, []string{"id", "name", "age"}, nil},
+		{[]string{"id", "name", "age"}, []string{"id", "age", "name"},
+			[]columnMove{{Name: "name", Position: "AFTER `id`"}}},
+		{[]string{"id", "name", "age"}, []string{"name", "age", "id"},
+			[]columnMove{{Name: "id", Position: "FIRST"}}},
+		{[]string{"a", "b", "c", "d"}, []string{"d", "c", "b", "a"},
+			[]columnMove{{Name: "a", Position: "FIRST"}, {Name: "b", Position: "AFTER `a`"}, {Name: "c", Position: "AFTER `b`"}}},
+	}
+	for _, c := range cases {
+		if got := columnMoves(c.expected, c.current); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("columnMoves(%v, %v) = %v, want %v", c.expected, c.current, got, c.want)
+		}
+	}
+}
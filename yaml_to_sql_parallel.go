@@ -0,0 +1,147 @@
+package dataschema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 进度所处的阶段
+const (
+	PROGRESS_DIFF    = "diff"    // 对比表结构
+	PROGRESS_EXECUTE = "execute" // 执行迁移
+)
+
+// TableProgress 单张表对比或执行完成时的进度
+type TableProgress struct {
+	Stage  string       // PROGRESS_*
+	Table  string       // 表名
+	Done   int          // 已完成的表数量
+	Total  int          // 表总数
+	Errors SchemaErrors // 该表的错误，成功时为空
+}
+
+// SetConcurrency 设置对比表结构和执行迁移的并发数，默认1即按顺序执行，
+// 大于1时各表并发对比，执行时互不依赖的表(如分表)并发执行，单表的语句仍按顺序执行，某张表失败不影响其他表
+func (ts *YamlToSqlHandler) SetConcurrency(concurrency int) *YamlToSqlHandler {
+	ts.concurrency = concurrency
+	return ts
+}
+
+// SetProgress 设置进度回调，每张表对比或执行完成后调用，并发时可能在多个goroutine中调用；
+// 未设置时并发数大于1才会打印进度
+func (ts *YamlToSqlHandler) SetProgress(progress func(p TableProgress)) *YamlToSqlHandler {
+	ts.progress = progress
+	return ts
+}
+
+// runPool 以最多 concurrency 个goroutine执行 job(0..n-1)，concurrency<=1 时按顺序执行
+func runPool(concurrency int, n int, job func(i int)) {
+	if concurrency <= 1 {
+		for i := 0; i < n; i++ {
+			job(i)
+		}
+		return
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				job(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// progressCounter 统计已完成的表数量并回调进度
+type progressCounter struct {
+	mu    sync.Mutex
+	stage string
+	done  int
+	total int
+}
+
+func newProgressCounter(stage string, total int) *progressCounter {
+	return &progressCounter{stage: stage, total: total}
+}
+
+// finishTable 一张表完成后回调进度
+func (ts *YamlToSqlHandler) finishTable(pc *progressCounter, table string) {
+	pc.mu.Lock()
+	pc.done++
+	p := TableProgress{Stage: pc.stage, Table: table, Done: pc.done, Total: pc.total, Errors: ts.tableErrors(table)}
+	pc.mu.Unlock()
+
+	if ts.progress != nil {
+		ts.progress(p)
+		return
+	}
+	if ts.concurrency <= 1 {
+		return
+	}
+	stage := "对比表结构"
+	if p.Stage == PROGRESS_EXECUTE {
+		stage = "执行迁移"
+	}
+	if len(p.Errors) > 0 {
+		fmt.Printf("\x1b[%dm%s %d/%d: %s 失败: %s \x1b[0m\n", 31, stage, p.Done, p.Total, p.Table, p.Errors.Error())
+		return
+	}
+	fmt.Printf("\x1b[%dm%s %d/%d: %s \x1b[0m\n", 36, stage, p.Done, p.Total, p.Table)
+}
+
+// 匹配外键引用的表
+var referencesRegexp = regexp.MustCompile("(?i)REFERENCES\\s+`?(\\w+)`?")
+
+// stepGroups 将未完成的步骤按表分组，并发数<=1时全部步骤为一组按顺序执行；
+// 含外键或被外键引用的表之间存在先后依赖，放在第一组按顺序执行，返回的 serial 表示第一组是否必须先于其他组执行
+func stepGroups(steps []MigrationStep, concurrency int) (groups [][]int, serial bool) {
+	if concurrency <= 1 {
+		var group []int
+		for i := range steps {
+			group = append(group, i)
+		}
+		return [][]int{group}, true
+	}
+
+	dependent := map[string]bool{}
+	for _, step := range steps {
+		if strings.Contains(strings.ToUpper(step.Sql), "FOREIGN KEY") {
+			dependent[step.Table] = true
+			for _, m := range referencesRegexp.FindAllStringSubmatch(step.Sql, -1) {
+				dependent[m[1]] = true
+			}
+		}
+	}
+
+	var serialGroup []int
+	tableGroups := map[string]int{}
+	groups = append(groups, nil)
+	for i, step := range steps {
+		if dependent[step.Table] {
+			serialGroup = append(serialGroup, i)
+			continue
+		}
+		k, ok := tableGroups[step.Table]
+		if !ok {
+			k = len(groups)
+			tableGroups[step.Table] = k
+			groups = append(groups, nil)
+		}
+		groups[k] = append(groups[k], i)
+	}
+	if len(serialGroup) < 1 {
+		return groups[1:], false
+	}
+	groups[0] = serialGroup
+	return groups, true
+}
@@ -0,0 +1,81 @@
+package dataschema
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunPool(t *testing.T) {
+	// 顺序执行
+	var order []int
+	runPool(1, 5, func(i int) { order = append(order, i) })
+	if !reflect.DeepEqual(order, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("Expected sequential order, got %v", order)
+	}
+
+	// 并发数不超过限制，且每个任务只执行一次
+	var running, maxRunning int32
+	var mu sync.Mutex
+	seen := map[int]int{}
+	runPool(3, 20, func(i int) {
+		n := atomic.AddInt32(&running, 1)
+		mu.Lock()
+		if n > maxRunning {
+			maxRunning = n
+		}
+		seen[i]++
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	})
+	if maxRunning > 3 || len(seen) != 20 {
+		t.Errorf("Expected 20 jobs with 3 workers, got %d jobs with %d workers", len(seen), maxRunning)
+	}
+}
+
+func TestStepGroups(t *testing.T) {
+	steps := []MigrationStep{
+		{Table: "company", Sql: "CREATE TABLE company (id int)"},
+		{Table: "user_00", Sql: "ALTER TABLE user_00 ADD age int"},
+		{Table: "employee", Sql: "ALTER TABLE employee ADD CONSTRAINT fk_company FOREIGN KEY (company_id) REFERENCES company (id)"},
+		{Table: "user_01", Sql: "ALTER TABLE user_01 ADD age int"},
+		{Table: "user_00", Sql: "CREATE INDEX idx_age ON user_00 (age)"},
+	}
+
+	groups, serial := stepGroups(steps, 1)
+	if !serial || !reflect.DeepEqual(groups, [][]int{{0, 1, 2, 3, 4}}) {
+		t.Errorf("Expected a single sequential group, got %v", groups)
+	}
+
+	// 外键相关的表先按顺序执行，其余按表分组
+	groups, serial = stepGroups(steps, 4)
+	if !serial || !reflect.DeepEqual(groups, [][]int{{0, 2}, {1, 4}, {3}}) {
+		t.Errorf("Unexpected groups: %v", groups)
+	}
+
+	groups, serial = stepGroups(steps[3:], 4)
+	if serial || !reflect.DeepEqual(groups, [][]int{{0}, {1}}) {
+		t.Errorf("Unexpected groups without foreign keys: %v", groups)
+	}
+}
+
+func TestTableProgress(t *testing.T) {
+	var progress []TableProgress
+	ts := NewYamlToSqlHandler().SetConcurrency(2).SetProgress(func(p TableProgress) {
+		progress = append(progress, p)
+	})
+	ts.addError(&SchemaError{Code: ERR_CODE_EXECUTE_SQL, Table: "user_01", Message: "failed"})
+	pc := newProgressCounter(PROGRESS_EXECUTE, 2)
+	ts.finishTable(pc, "user_00")
+	ts.finishTable(pc, "user_01")
+	if len(progress) != 2 || progress[0].Done != 1 || len(progress[0].Errors) != 0 ||
+		progress[1].Done != 2 || progress[1].Total != 2 || len(progress[1].Errors) != 1 {
+		t.Errorf("Unexpected progress: %+v", progress)
+	}
+	if errs := ts.ErrorsByTable(); len(errs["user_01"]) != 1 || len(errs["user_00"]) != 0 {
+		t.Errorf("Unexpected errors by table: %v", errs)
+	}
+}
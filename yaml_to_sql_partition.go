@@ -0,0 +1,282 @@
+package dataschema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+// partitionMethods 支持的分区方式
+var partitionMethods = map[string]bool{
+	"RANGE": true, "RANGE COLUMNS": true, "LIST": true, "LIST COLUMNS": true,
+	"HASH": true, "LINEAR HASH": true, "KEY": true, "LINEAR KEY": true,
+}
+
+// partitionItem range/list 分区中的一个分区
+type partitionItem struct {
+	Name   string
+	Values string // range 为 LESS THAN 的值，list 为 IN 的值
+}
+
+func normalizePartitionMethod(method string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(method), " "))
+}
+
+// isCountPartition hash/key 分区只需要分区数量
+func isCountPartition(method string) bool {
+	return strings.HasSuffix(method, "HASH") || strings.HasSuffix(method, "KEY")
+}
+
+// partitionItemDefinition 单个分区的定义，如 PARTITION p202401 VALUES LESS THAN (739282)
+func partitionItemDefinition(method string, item partitionItem) string {
+	if strings.HasPrefix(method, "LIST") {
+		return fmt.Sprintf("PARTITION %s VALUES IN (%s)", quoteIdent(item.Name), item.Values)
+	}
+	if strings.ToUpper(strings.TrimSpace(item.Values)) == "MAXVALUE" {
+		return fmt.Sprintf("PARTITION %s VALUES LESS THAN MAXVALUE", quoteIdent(item.Name))
+	}
+	return fmt.Sprintf("PARTITION %s VALUES LESS THAN (%s)", quoteIdent(item.Name), item.Values)
+}
+
+func partitionItemDefinitions(method string, items []partitionItem) string {
+	var defs []string
+	for _, item := range items {
+		defs = append(defs, partitionItemDefinition(method, item))
+	}
+	return strings.Join(defs, ",\n\t")
+}
+
+// partitionByDefinition 分区定义，建表时追加在表选项之后，修改时用于 ALTER TABLE t PARTITION BY ...
+func partitionByDefinition(method, expression string, count int, items []partitionItem) string {
+	def := fmt.Sprintf("PARTITION BY %s (%s)", method, expression)
+	if isCountPartition(method) {
+		return fmt.Sprintf("%s PARTITIONS %d", def, count)
+	}
+	return fmt.Sprintf("%s (\n\t%s\n)", def, partitionItemDefinitions(method, items))
+}
+
+// ymlPartitionExpression 分区表达式，columns 优先于 expression
+func ymlPartitionExpression(partitions gjson.Result) string {
+	if columns := partitions.Get("columns"); columns.IsArray() {
+		return quoteIdents(gjsonStrings(columns))
+	}
+	return partitions.Get("expression").String()
+}
+
+func ymlPartitionItems(partitions gjson.Result) []partitionItem {
+	var items []partitionItem
+	for _, item := range partitions.Get("items").Array() {
+		values := item.Get("values")
+		value := values.String()
+		if values.IsArray() {
+			value = strings.Join(gjsonStrings(values), ",")
+		}
+		items = append(items, partitionItem{Name: item.Get("name").String(), Values: value})
+	}
+	return items
+}
+
+// ymlPartitionBy yml中 options.partitions 对应的分区定义
+func ymlPartitionBy(partitions gjson.Result) string {
+	return partitionByDefinition(normalizePartitionMethod(partitions.Get("type").String()), ymlPartitionExpression(partitions),
+		int(partitions.Get("count").Int()), ymlPartitionItems(partitions))
+}
+
+// sqlPartitionBy 数据库中现有的分区定义，没有分区时为空
+func sqlPartitionBy(sqlPartitions []information_schema.SqlPartition) string {
+	if len(sqlPartitions) < 1 {
+		return ""
+	}
+	method := normalizePartitionMethod(sqlPartitions[0].PartitionMethod)
+	var items []partitionItem
+	for _, sp := range sqlPartitions {
+		items = append(items, partitionItem{Name: sp.PartitionName, Values: sp.PartitionDescription})
+	}
+	return partitionByDefinition(method, sqlPartitions[0].PartitionExpression, len(sqlPartitions), items)
+}
+
+// checkYmlPartitions 检查 options.partitions 配置
+func checkYmlPartitions(tbJson gjson.Result, report func(d Diagnostic, path string)) {
+	partitions := tbJson.Get("options.partitions")
+	if !partitions.Exists() {
+		return
+	}
+	tname := tbJson.Get("table").String()
+	path := "Table.options.partitions"
+	partitionError := func(message, subPath string) {
+		report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_PARTITION, Table: tname, Message: message}, path+subPath)
+	}
+	method := normalizePartitionMethod(partitions.Get("type").String())
+	if !partitionMethods[method] {
+		partitionError(fmt.Sprintf("不支持的分区方式:'%s'", partitions.Get("type").String()), ".type")
+		return
+	}
+	if ymlPartitionExpression(partitions) == "" && !strings.HasSuffix(method, "KEY") {
+		partitionError("缺少分区表达式 expression/columns", "")
+	}
+	if isCountPartition(method) {
+		if partitions.Get("count").Int() < 1 {
+			partitionError("hash/key 分区需要配置 count", "")
+		}
+		return
+	}
+	items := partitions.Get("items")
+	if !items.IsArray() || len(items.Array()) < 1 {
+		partitionError("range/list 分区需要配置 items", "")
+		return
+	}
+	names := map[string]bool{}
+	for i, item := range items.Array() {
+		name := item.Get("name").String()
+		if name == "" || item.Get("values").String() == "" {
+			partitionError("分区缺少 name 或 values", fmt.Sprintf(".items.%d", i))
+			continue
+		}
+		if names[name] {
+			partitionError(fmt.Sprintf("重复的分区名:'%s'", name), fmt.Sprintf(".items.%d", i))
+		}
+		names[name] = true
+	}
+}
+
+// getPartitionOps 对比 INFORMATION_SCHEMA.PARTITIONS，yml中配置了 options.partitions 时才维护。
+// 分区方式或表达式变化时重新分区；range/list 按分区名对比，新增的分区在末尾时 ADD PARTITION，
+// 在已有分区之前时拆分(REORGANIZE)其后的已有分区，分区值的变化不会检测；hash/key 按数量 ADD/COALESCE
+func (ts *YamlToSqlHandler) getPartitionOps(tbl gjson.Result, sqlTbl information_schema.SqlTable) (ops, drops []PlanOperation, err error) {
+	partitions := tbl.Get("options.partitions")
+	if !partitions.Exists() {
+		return
+	}
+	tname := tbl.Get("table").String()
+
+	var sqlPartitions []information_schema.SqlPartition
+	err = ts.db.Table("INFORMATION_SCHEMA.PARTITIONS").
+		Where("TABLE_SCHEMA=?", ts.schema).
+		Where("TABLE_NAME=?", sqlTbl.TableName).
+		Where("PARTITION_NAME IS NOT NULL").
+		Order("PARTITION_ORDINAL_POSITION").
+		Find(&sqlPartitions).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	ops, drops = partitionOps(tname, partitions, sqlPartitions)
+	return ops, drops, nil
+}
+
+// partitionOps 计算分区变更，不依赖数据库连接
+func partitionOps(tname string, partitions gjson.Result, sqlPartitions []information_schema.SqlPartition) (ops, drops []PlanOperation) {
+	method := normalizePartitionMethod(partitions.Get("type").String())
+	newBy := ymlPartitionBy(partitions)
+	if len(sqlPartitions) < 1 ||
+		normalizePartitionMethod(sqlPartitions[0].PartitionMethod) != method ||
+		normalizeSqlExpression(sqlPartitions[0].PartitionExpression) != normalizeSqlExpression(ymlPartitionExpression(partitions)) {
+		op := PlanOperation{
+			Type:          OP_PARTITION_BY,
+			Table:         tname,
+			Name:          method,
+			OldDefinition: sqlPartitionBy(sqlPartitions),
+			NewDefinition: newBy,
+			Reason:        "分区方式/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s %s", quoteIdent(tname), newBy),
+		}
+		if len(sqlPartitions) < 1 {
+			op.Reason = "分区/"
+		}
+		ops = append(ops, op)
+		return
+	}
+
+	if isCountPartition(method) {
+		count, current := int(partitions.Get("count").Int()), len(sqlPartitions)
+		if count > current {
+			ops = append(ops, PlanOperation{
+				Type:          OP_ADD_PARTITION,
+				Table:         tname,
+				Name:          "PARTITIONS",
+				NewDefinition: fmt.Sprintf("PARTITIONS %d", count-current),
+				Reason:        "分区数量/",
+				Sql:           fmt.Sprintf("ALTER TABLE %s ADD PARTITION PARTITIONS %d", quoteIdent(tname), count-current),
+			})
+		} else if count < current {
+			ops = append(ops, PlanOperation{
+				Type:          OP_COALESCE_PARTITION,
+				Table:         tname,
+				Name:          "PARTITIONS",
+				OldDefinition: fmt.Sprintf("PARTITIONS %d", current-count),
+				Reason:        "分区数量/",
+				Sql:           fmt.Sprintf("ALTER TABLE %s COALESCE PARTITION %d", quoteIdent(tname), current-count),
+			})
+		}
+		return
+	}
+
+	existing := map[string]partitionItem{}
+	for _, sp := range sqlPartitions {
+		existing[sp.PartitionName] = partitionItem{Name: sp.PartitionName, Values: sp.PartitionDescription}
+	}
+	items := ymlPartitionItems(partitions)
+	configured := map[string]bool{}
+	for _, item := range items {
+		configured[item.Name] = true
+	}
+	for _, sp := range sqlPartitions {
+		if configured[sp.PartitionName] {
+			continue
+		}
+		drops = append(drops, PlanOperation{
+			Type:          OP_DROP_PARTITION,
+			Table:         tname,
+			Name:          sp.PartitionName,
+			OldDefinition: partitionItemDefinition(method, existing[sp.PartitionName]),
+			Reason:        "配置中已不存在/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s", quoteIdent(tname), quoteIdent(sp.PartitionName)),
+			Destructive:   true,
+		})
+	}
+
+	var pending []partitionItem
+	for _, item := range items {
+		old, ok := existing[item.Name]
+		if !ok {
+			pending = append(pending, item)
+			continue
+		}
+		// range 分区只能在末尾新增，中间的新分区通过拆分其后的已有分区得到
+		if len(pending) > 0 && strings.HasPrefix(method, "RANGE") {
+			var names []string
+			for _, p := range pending {
+				names = append(names, p.Name)
+			}
+			names = append(names, item.Name)
+			newDefinition := partitionItemDefinitions(method, append(pending, item))
+			ops = append(ops, PlanOperation{
+				Type:          OP_REORGANIZE_PARTITION,
+				Table:         tname,
+				Name:          strings.Join(names, ","),
+				OldDefinition: partitionItemDefinition(method, old),
+				NewDefinition: newDefinition,
+				Reason:        "拆分分区/",
+				Sql:           fmt.Sprintf("ALTER TABLE %s REORGANIZE PARTITION %s INTO (\n\t%s\n)", quoteIdent(tname), quoteIdent(item.Name), newDefinition),
+			})
+			pending = nil
+		}
+	}
+	if len(pending) > 0 {
+		var names []string
+		for _, p := range pending {
+			names = append(names, p.Name)
+		}
+		newDefinition := partitionItemDefinitions(method, pending)
+		ops = append(ops, PlanOperation{
+			Type:          OP_ADD_PARTITION,
+			Table:         tname,
+			Name:          strings.Join(names, ","),
+			NewDefinition: newDefinition,
+			Reason:        "新增分区/",
+			Sql:           fmt.Sprintf("ALTER TABLE %s ADD PARTITION (\n\t%s\n)", quoteIdent(tname), newDefinition),
+		})
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package dataschema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/k-kkong/dataschema/information_schema"
+	"github.com/tidwall/gjson"
+)
+
+var testRangePartitions = gjson.Parse(`{
+	"type": "range",
+	"expression": "to_days(created_at)",
+	"items": [
+		{"name": "p202312", "values": "to_days('2024-01-01')"},
+		{"name": "p202401", "values": "to_days('2024-02-01')"},
+		{"name": "p202402", "values": "to_days('2024-03-01')"},
+		{"name": "pmax", "values": "maxvalue"}
+	]
+}`)
+
+func TestYmlPartitionBy(t *testing.T) {
+	want := "PARTITION BY RANGE (to_days(created_at)) (\n" +
+		"\tPARTITION `p202312` VALUES LESS THAN (to_days('2024-01-01')),\n" +
+		"\tPARTITION `p202401` VALUES LESS THAN (to_days('2024-02-01')),\n" +
+		"\tPARTITION `p202402` VALUES LESS THAN (to_days('2024-03-01')),\n" +
+		"\tPARTITION `pmax` VALUES LESS THAN MAXVALUE\n)"
+	if got := ymlPartitionBy(testRangePartitions); got != want {
+		t.Errorf("Unexpected partition definition:\n%s", got)
+	}
+
+	hash := gjson.Parse(`{"type": "linear  hash", "expression": "user_id", "count": 8}`)
+	if got := ymlPartitionBy(hash); got != "PARTITION BY LINEAR HASH (user_id) PARTITIONS 8" {
+		t.Errorf("Unexpected hash partition definition: %s", got)
+	}
+
+	list := gjson.Parse(`{"type": "list columns", "columns": ["region"], "items": [{"name": "p_east", "values": ["'sh'", "'hz'"]}]}`)
+	if got := ymlPartitionBy(list); !strings.Contains(got, "PARTITION `p_east` VALUES IN ('sh','hz')") {
+		t.Errorf("Unexpected list partition definition: %s", got)
+	}
+}
+
+func TestPartitionOpsRange(t *testing.T) {
+	sqlPartitions := []information_schema.SqlPartition{
+		{PartitionName: "p202311", PartitionMethod: "RANGE", PartitionExpression: "to_days(`created_at`)", PartitionDescription: "739191"},
+		{PartitionName: "p202312", PartitionMethod: "RANGE", PartitionExpression: "to_days(`created_at`)", PartitionDescription: "739251"},
+		{PartitionName: "pmax", PartitionMethod: "RANGE", PartitionExpression: "to_days(`created_at`)", PartitionDescription: "MAXVALUE"},
+	}
+	ops, drops := partitionOps("log", testRangePartitions, sqlPartitions)
+	if len(drops) != 1 || drops[0].Type != OP_DROP_PARTITION || drops[0].Name != "p202311" || !drops[0].Destructive {
+		t.Errorf("Expected p202311 to be a destructive drop, got %+v", drops)
+	}
+	if len(ops) != 1 || ops[0].Type != OP_REORGANIZE_PARTITION || ops[0].Name != "p202401,p202402,pmax" {
+		t.Fatalf("Expected pmax to be reorganized, got %+v", ops)
+	}
+	if !strings.HasPrefix(ops[0].Sql, "ALTER TABLE `log` REORGANIZE PARTITION `pmax` INTO (\n\tPARTITION `p202401`") {
+		t.Errorf("Unexpected reorganize sql: %s", ops[0].Sql)
+	}
+	if got := ops[0].RollbackSql(); got != "ALTER TABLE `log` REORGANIZE PARTITION `p202401`,`p202402`,`pmax` INTO (\n\tPARTITION `pmax` VALUES LESS THAN MAXVALUE\n)" {
+		t.Errorf("Unexpected rollback: %s", got)
+	}
+
+	// 表达式变化时重新分区
+	sqlPartitions[0].PartitionExpression = "`id`"
+	ops, _ = partitionOps("log", testRangePartitions, sqlPartitions[:1])
+	if len(ops) != 1 || ops[0].Type != OP_PARTITION_BY || !strings.HasPrefix(ops[0].Sql, "ALTER TABLE `log` PARTITION BY RANGE") {
+		t.Errorf("Expected repartition, got %+v", ops)
+	}
+
+	// 未分区的表
+	ops, _ = partitionOps("log", testRangePartitions, nil)
+	if len(ops) != 1 || ops[0].RollbackSql() != "ALTER TABLE `log` REMOVE PARTITIONING" {
+		t.Errorf("Expected partitioning with REMOVE PARTITIONING rollback, got %+v", ops)
+	}
+}
+
+func TestPartitionOpsHash(t *testing.T) {
+	var sqlPartitions []information_schema.SqlPartition
+	for _, name := range []string{"p0", "p1", "p2", "p3"} {
+		sqlPartitions = append(sqlPartitions, information_schema.SqlPartition{PartitionName: name, PartitionMethod: "HASH", PartitionExpression: "`user_id`"})
+	}
+	ops, _ := partitionOps("user", gjson.Parse(`{"type": "hash", "expression": "user_id", "count": 6}`), sqlPartitions)
+	if len(ops) != 1 || ops[0].Sql != "ALTER TABLE `user` ADD PARTITION PARTITIONS 2" || ops[0].RollbackSql() != "ALTER TABLE `user` COALESCE PARTITION 2" {
+		t.Errorf("Unexpected add partition ops: %+v", ops)
+	}
+	ops, _ = partitionOps("user", gjson.Parse(`{"type": "hash", "expression": "user_id", "count": 3}`), sqlPartitions)
+	if len(ops) != 1 || ops[0].Sql != "ALTER TABLE `user` COALESCE PARTITION 1" || ops[0].RollbackSql() != "ALTER TABLE `user` ADD PARTITION PARTITIONS 1" {
+		t.Errorf("Unexpected coalesce partition ops: %+v", ops)
+	}
+}
+
+func TestValidatePartitions(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.Log.yml", `Table:
+  table: log
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+    partitions:
+      type: range
+      expression: to_days(created_at)
+      items:
+        - name: p1
+          values: to_days('2024-01-01')
+        - name: p1
+          values: maxvalue
+  dropped_partitions:
+    - p1
+  fields:
+    created_at:
+      type: datetime
+`)
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	if len(ds) != 2 || ds[0].Code != ERR_CODE_INVALID_PARTITION || ds[0].Line != 12 || ds[1].Code != ERR_CODE_INVALID_TABLE {
+		t.Errorf("Expected duplicate partition and dropped_partitions conflict, got %v", ds)
+	}
+}
@@ -0,0 +1,294 @@
+package dataschema
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// 迁移操作类型
+const (
+	OP_CREATE_TABLE       = "CreateTable"
+	OP_RENAME_TABLE       = "RenameTable"
+	OP_ALTER_TABLE        = "AlterTable" // 表级属性，如表注释
+	OP_ADD_COLUMN         = "AddColumn"
+	OP_MODIFY_COLUMN      = "ModifyColumn"
+	OP_RENAME_COLUMN      = "RenameColumn"
+	OP_DROP_COLUMN        = "DropColumn"
+	OP_ADD_INDEX          = "AddIndex"
+	OP_DROP_INDEX         = "DropIndex"
+	OP_ALTER_INDEX        = "AlterIndex" // 修改索引可见性
+	OP_CHANGE_PRIMARY_KEY = "ChangePrimaryKey"
+	OP_ADD_FOREIGN_KEY    = "AddForeignKey"
+	OP_DROP_FOREIGN_KEY   = "DropForeignKey"
+	OP_ADD_CHECK          = "AddCheck"
+	OP_DROP_CHECK         = "DropCheck"
+	// 分区操作不能与其他变更合并，总是单独执行
+	OP_PARTITION_BY         = "PartitionBy"
+	OP_ADD_PARTITION        = "AddPartition"
+	OP_DROP_PARTITION       = "DropPartition"
+	OP_COALESCE_PARTITION   = "CoalescePartition"
+	OP_REORGANIZE_PARTITION = "ReorganizePartition"
+)
+
+// PlanOperation 一条结构变更操作
+type PlanOperation struct {
+	Type          string // 操作类型 OP_*
+	Table         string // 表名
+	Name          string // 字段名/索引名
+	OldDefinition string // 变更前的定义，新增时为空
+	NewDefinition string // 变更后的定义，删除时为空
+	Reason        string // 变更原因
+	Sql           string // 对应的sql语句，不含结尾分号
+	Clause        string // ALTER TABLE 子句，用于合并为一条语句，为空时只能单独执行
+	Destructive   bool   // 是否为会丢失数据的破坏性操作
+}
+
+// TablePlan 单张表的迁移计划
+type TablePlan struct {
+	Table      string          // 表名
+	File       string          // 配置文件路径
+	Operations []PlanOperation // 按执行顺序排列的操作
+	Skipped    []PlanOperation // 未声明而被跳过的破坏性操作
+	Algorithm  string          // 在线DDL的 ALGORITHM
+	Lock       string          // 在线DDL的 LOCK
+	Split      bool            // 是否每个变更单独一条语句，默认合并为一条 ALTER TABLE
+}
+
+// PlanStatement 一条待执行的sql及其包含的操作
+type PlanStatement struct {
+	Sql        string          // 不含结尾分号
+	Operations []PlanOperation // 合并到该语句中的操作
+}
+
+// MigrationPlan 迁移计划
+type MigrationPlan struct {
+	Tables          []TablePlan
+	UncoveredShards []ShardingReport // 数据库中存在但未被分表模板覆盖的分表，不会做任何变更
+}
+
+func (tp *TablePlan) add(op PlanOperation) {
+	if op.Table == "" {
+		op.Table = tp.Table
+	}
+	tp.Operations = append(tp.Operations, op)
+}
+
+// IsEmpty 该表是否没有变更
+func (tp TablePlan) IsEmpty() bool {
+	return len(tp.Operations) < 1
+}
+
+// onlineOptions ALTER TABLE 结尾的在线DDL选项，如 ALGORITHM=INPLACE, LOCK=NONE
+func (tp TablePlan) onlineOptions() string {
+	var options []string
+	if tp.Algorithm != "" {
+		options = append(options, "ALGORITHM="+strings.ToUpper(tp.Algorithm))
+	}
+	if tp.Lock != "" {
+		options = append(options, "LOCK="+strings.ToUpper(tp.Lock))
+	}
+	return strings.Join(options, ", ")
+}
+
+// PlanStatements 按顺序返回该表需要执行的语句，默认所有可合并的操作会合并为一条 ALTER TABLE，
+// 放在第一个可合并操作的位置，避免大表每条语句都重建一次
+func (tp TablePlan) PlanStatements() []PlanStatement {
+	online := tp.onlineOptions()
+	var stmts []PlanStatement
+	merged := -1
+	for _, op := range tp.Operations {
+		if op.Clause == "" {
+			stmts = append(stmts, PlanStatement{Sql: op.Sql, Operations: []PlanOperation{op}})
+			continue
+		}
+		if tp.Split {
+			sql := op.Sql
+			if online != "" {
+				sql = fmt.Sprintf("ALTER TABLE %s %s, %s", quoteIdent(tp.Table), op.Clause, online)
+			}
+			stmts = append(stmts, PlanStatement{Sql: sql, Operations: []PlanOperation{op}})
+			continue
+		}
+		if merged < 0 {
+			merged = len(stmts)
+			stmts = append(stmts, PlanStatement{})
+		}
+		stmts[merged].Operations = append(stmts[merged].Operations, op)
+	}
+	// 只有一个变更且没有在线DDL选项时保持原有的语句
+	if merged >= 0 && len(stmts[merged].Operations) == 1 && online == "" {
+		stmts[merged].Sql = stmts[merged].Operations[0].Sql
+	} else if merged >= 0 {
+		var clauses []string
+		for _, op := range stmts[merged].Operations {
+			clauses = append(clauses, op.Clause)
+		}
+		if online != "" {
+			clauses = append(clauses, online)
+		}
+		stmts[merged].Sql = fmt.Sprintf("ALTER TABLE %s\n\t%s", quoteIdent(tp.Table), strings.Join(clauses, ",\n\t"))
+	}
+	return stmts
+}
+
+// Statements 按顺序返回该表需要执行的sql
+func (tp TablePlan) Statements() []string {
+	var stmts []string
+	for _, stmt := range tp.PlanStatements() {
+		stmts = append(stmts, stmt.Sql)
+	}
+	return stmts
+}
+
+// Sql 该表需要执行的sql，多条语句以分号换行分隔
+func (tp TablePlan) Sql() string {
+	if tp.IsEmpty() {
+		return ""
+	}
+	sql := "\n"
+	for _, stmt := range tp.Statements() {
+		sql = fmt.Sprintf("%s%s;\n", sql, stmt)
+	}
+	return sql
+}
+
+// IsEmpty 是否没有任何变更
+func (p *MigrationPlan) IsEmpty() bool {
+	for _, tp := range p.Tables {
+		if !tp.IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// Operations 全部操作
+func (p *MigrationPlan) Operations() []PlanOperation {
+	var ops []PlanOperation
+	for _, tp := range p.Tables {
+		ops = append(ops, tp.Operations...)
+	}
+	return ops
+}
+
+// String 输出带注释的sql，便于人工审核
+func (p *MigrationPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- dataschema migration plan\n")
+	fmt.Fprintf(&b, "-- generated at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	if p.IsEmpty() {
+		fmt.Fprintf(&b, "-- no changes\n")
+	}
+	for _, tp := range p.Tables {
+		if tp.IsEmpty() {
+			continue
+		}
+		fmt.Fprintf(&b, "\n-- ==================== table: %s ====================\n", tp.Table)
+		if tp.File != "" {
+			fmt.Fprintf(&b, "-- file: %s\n", tp.File)
+		}
+		for _, stmt := range tp.PlanStatements() {
+			fmt.Fprintf(&b, "\n")
+			for _, op := range stmt.Operations {
+				fmt.Fprintf(&b, "-- [%s] %s", op.Type, op.Name)
+				if op.Reason != "" {
+					fmt.Fprintf(&b, " (%s)", op.Reason)
+				}
+				fmt.Fprintf(&b, "\n")
+				if op.Type != OP_CREATE_TABLE {
+					if op.OldDefinition != "" {
+						fmt.Fprintf(&b, "--   old: %s\n", op.OldDefinition)
+					}
+					if op.NewDefinition != "" {
+						fmt.Fprintf(&b, "--   new: %s\n", op.NewDefinition)
+					}
+				}
+			}
+			fmt.Fprintf(&b, "%s;\n", stmt.Sql)
+		}
+	}
+	for _, tp := range p.Tables {
+		if len(tp.Skipped) < 1 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n-- ==================== skipped destructive: %s ====================\n", tp.Table)
+		fmt.Fprintf(&b, "-- 在yml中声明 dropped_fields/dropped_indexes/dropped_partitions 或 SetAllowDestructive(true) 后才会执行\n")
+		for _, op := range tp.Skipped {
+			fmt.Fprintf(&b, "-- [%s] %s\n", op.Type, op.Name)
+			fmt.Fprintf(&b, "-- %s;\n", op.Sql)
+		}
+	}
+	for _, sr := range p.UncoveredShards {
+		fmt.Fprintf(&b, "\n-- ==================== uncovered shards: %s ====================\n", sr.Table)
+		fmt.Fprintf(&b, "-- 数据库中存在但未被分表模板 %s 覆盖的分表，请确认是否需要扩大 from/to\n", sr.Pattern)
+		fmt.Fprintf(&b, "-- %s\n", strings.Join(sr.Uncovered, ","))
+	}
+	return b.String()
+}
+
+// Plan 只计算迁移计划，不执行
+func (ts *YamlToSqlHandler) Plan() (*MigrationPlan, error) {
+	ts.reset().connectSql().
+		getyamlFileFullPaths().
+		getYamlDatas().verifyYmlFile().doSchema()
+	return ts.plan, ts.err()
+}
+
+// GetPlan 获取最近一次计算的迁移计划
+func (ts *YamlToSqlHandler) GetPlan() *MigrationPlan {
+	return ts.plan
+}
+
+// WritePlan 将迁移计划写入sql文件供审核，同时写入对应的回滚文件(如 plan.sql => plan.down.sql)，
+// 尚未计算计划时会先调用 Plan
+func (ts *YamlToSqlHandler) WritePlan(dest string) error {
+	if ts.plan == nil {
+		if _, err := ts.Plan(); err != nil {
+			return err
+		}
+	}
+
+	if err := writePlanFile(dest, ts.plan.String()); err != nil {
+		return err
+	}
+	return writePlanFile(rollbackPath(dest), ts.plan.RollbackString())
+}
+
+// SetSplitStatements 设置是否每个变更单独一条语句，默认false，同一张表的变更合并为一条 ALTER TABLE
+func (ts *YamlToSqlHandler) SetSplitStatements(split bool) *YamlToSqlHandler {
+	ts.splitStatements = split
+	return ts
+}
+
+// SetOnlineDDL 设置全局的在线DDL选项，algorithm: instant|inplace|copy，lock: none|shared，
+// 为空表示不指定，yml中的 options.algorithm/options.lock 优先
+func (ts *YamlToSqlHandler) SetOnlineDDL(algorithm, lock string) *YamlToSqlHandler {
+	ts.algorithm = algorithm
+	ts.lock = lock
+	return ts
+}
+
+// tableAlgorithm 表使用的 ALGORITHM
+func (ts *YamlToSqlHandler) tableAlgorithm(tbl gjson.Result) string {
+	if algorithm := tbl.Get("options.algorithm").String(); algorithm != "" {
+		return algorithm
+	}
+	return ts.algorithm
+}
+
+// tableLock 表使用的 LOCK
+func (ts *YamlToSqlHandler) tableLock(tbl gjson.Result) string {
+	if lock := tbl.Get("options.lock").String(); lock != "" {
+		return lock
+	}
+	return ts.lock
+}
+
+// SetAllowDestructive 设置是否允许删除yml中未声明的字段和索引，默认不允许
+func (ts *YamlToSqlHandler) SetAllowDestructive(allow bool) *YamlToSqlHandler {
+	ts.allowDestructive = allow
+	return ts
+}
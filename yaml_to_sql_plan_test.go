@@ -0,0 +1,164 @@
+package dataschema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestGetCreateTablePlan(t *testing.T) {
+	tbl := gjson.Parse(`{
+		"table": "user",
+		"options": {"charset": "utf8mb4", "collate": "utf8mb4_general_ci", "comment": "用户"},
+		"id": {"id": {"type": "integer unsigned", "generator": "AUTO_INCREMENT"}},
+		"fields": {
+			"id": {"type": "integer unsigned", "generator": "AUTO_INCREMENT"},
+			"name": {"type": "varchar", "comment": "名称"},
+			"intro": {"type": "text", "nullable": true}
+		},
+		"indexes": {"idx_name": {"columns": ["name"]}}
+	}`)
+
+	ts := NewYamlToSqlHandler()
+	tp := ts.getCreateTablePlan(tbl, "Entity.User.yml")
+	if ts.hasError() {
+		t.Fatalf("Unexpected errors: %v", ts.Errors())
+	}
+	if len(tp.Operations) != 1 || tp.Operations[0].Type != OP_CREATE_TABLE {
+		t.Fatalf("Expected one CreateTable operation, got %+v", tp.Operations)
+	}
+
+	sql := tp.Operations[0].Sql
+	for _, want := range []string{
+		"CREATE TABLE `user`(",
+		"`id` int(10) unsigned NOT NULL AUTO_INCREMENT COMMENT ''",
+		"`name` varchar(255) NOT NULL COMMENT '名称'",
+		"`intro` text COMMENT ''",
+		"INDEX `idx_name` (`name`)",
+		"PRIMARY KEY(`id`)",
+		"COMMENT = '用户'",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Expected create sql to contain %q, got:\n%s", want, sql)
+		}
+	}
+}
+
+func TestGetCreateTablePlanMissingCharset(t *testing.T) {
+	ts := NewYamlToSqlHandler()
+	tp := ts.getCreateTablePlan(gjson.Parse(`{"table": "user", "fields": {}}`), "Entity.User.yml")
+	if !tp.IsEmpty() {
+		t.Errorf("Expected empty plan, got %+v", tp.Operations)
+	}
+	if len(ts.Errors()) != 1 || ts.Errors()[0].Code != ERR_CODE_INVALID_OPTION {
+		t.Errorf("Expected %s error, got %v", ERR_CODE_INVALID_OPTION, ts.Errors())
+	}
+}
+
+func TestMigrationPlanString(t *testing.T) {
+	plan := &MigrationPlan{}
+	tp := TablePlan{Table: "user", File: "Entity.User.yml"}
+	tp.add(PlanOperation{
+		Type:          OP_MODIFY_COLUMN,
+		Name:          "name",
+		OldDefinition: "name varchar(100) NOT NULL COMMENT ''",
+		NewDefinition: "name varchar(255) NOT NULL COMMENT ''",
+		Reason:        "类型/",
+		Sql:           "ALTER TABLE user MODIFY COLUMN name varchar(255) NOT NULL COMMENT ''",
+	})
+	plan.Tables = append(plan.Tables, tp, TablePlan{Table: "empty"})
+
+	out := plan.String()
+	for _, want := range []string{
+		"-- ==================== table: user ====================",
+		"-- [ModifyColumn] name (类型/)",
+		"--   old: name varchar(100) NOT NULL COMMENT ''",
+		"ALTER TABLE user MODIFY COLUMN name varchar(255) NOT NULL COMMENT '';",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected plan to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "table: empty") {
+		t.Errorf("Expected empty table to be skipped, got:\n%s", out)
+	}
+	if tp.Sql() != "\nALTER TABLE user MODIFY COLUMN name varchar(255) NOT NULL COMMENT '';\n" {
+		t.Errorf("Unexpected table sql: %q", tp.Sql())
+	}
+}
+
+func TestAddDropsSkipsUndeclaredDestructive(t *testing.T) {
+	drops := []PlanOperation{
+		{Type: OP_DROP_COLUMN, Name: "old_name", Sql: "ALTER TABLE user DROP old_name", Destructive: true},
+		{Type: OP_DROP_COLUMN, Name: "legacy", Sql: "ALTER TABLE user DROP legacy", Destructive: true},
+		{Type: OP_DROP_INDEX, Name: "idx_legacy", Sql: "DROP INDEX idx_legacy ON user", Destructive: true},
+		{Type: OP_CHANGE_PRIMARY_KEY, Name: "PRIMARY", Sql: "ALTER TABLE user DROP PRIMARY KEY"},
+	}
+	tbl := gjson.Parse(`{"table": "user", "dropped_fields": ["legacy"]}`)
+
+	tp := TablePlan{Table: "user"}
+	NewYamlToSqlHandler().addDrops(&tp, tbl, drops)
+	if len(tp.Operations) != 2 || tp.Operations[0].Name != "legacy" || tp.Operations[1].Name != "PRIMARY" {
+		t.Errorf("Expected legacy and PRIMARY to be kept, got %+v", tp.Operations)
+	}
+	if len(tp.Skipped) != 2 {
+		t.Errorf("Expected 2 skipped operations, got %+v", tp.Skipped)
+	}
+
+	tp = TablePlan{Table: "user"}
+	NewYamlToSqlHandler().SetAllowDestructive(true).addDrops(&tp, tbl, drops)
+	if len(tp.Operations) != 4 || len(tp.Skipped) != 0 {
+		t.Errorf("Expected all drops with allow destructive, got %+v / %+v", tp.Operations, tp.Skipped)
+	}
+}
+
+func TestTablePlanOnlineStatements(t *testing.T) {
+	tp := TablePlan{Table: "user", Algorithm: "inplace", Lock: "none"}
+	tp.add(PlanOperation{Type: OP_RENAME_TABLE, Name: "user", Sql: "RENAME TABLE member TO user"})
+	tp.add(PlanOperation{Type: OP_MODIFY_COLUMN, Name: "name",
+		Sql: "ALTER TABLE user MODIFY COLUMN name varchar(255) NOT NULL COMMENT ''", Clause: "MODIFY COLUMN name varchar(255) NOT NULL COMMENT ''"})
+	tp.add(PlanOperation{Type: OP_DROP_INDEX, Name: "idx_name",
+		Sql: "DROP INDEX idx_name ON user", Clause: "DROP INDEX idx_name"})
+	tp.add(PlanOperation{Type: OP_ADD_INDEX, Name: "idx_name",
+		Sql: "CREATE INDEX idx_name ON user (name,age)", Clause: "ADD INDEX idx_name (name,age)"})
+
+	want := []string{
+		"RENAME TABLE member TO user",
+		"ALTER TABLE `user`\n\tMODIFY COLUMN name varchar(255) NOT NULL COMMENT '',\n\tDROP INDEX idx_name,\n\tADD INDEX idx_name (name,age),\n\tALGORITHM=INPLACE, LOCK=NONE",
+	}
+	if got := tp.Statements(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected statements:\n%s", strings.Join(got, "\n"))
+	}
+
+	run := newMigrationRun(&MigrationPlan{Tables: []TablePlan{tp}}, "")
+	if len(run.Steps) != 2 || run.Steps[1].Type != OP_ALTER_TABLE || run.Steps[1].Name != "name,idx_name,idx_name" {
+		t.Errorf("Expected merged statement to be one step, got %+v", run.Steps)
+	}
+
+	tp.Split = true
+	if got := tp.Statements(); len(got) != 4 || got[3] != "ALTER TABLE `user` ADD INDEX idx_name (name,age), ALGORITHM=INPLACE, LOCK=NONE" {
+		t.Errorf("Expected one statement per operation with online options, got %v", got)
+	}
+}
+
+func TestTablePlanCombinedStatements(t *testing.T) {
+	tp := TablePlan{Table: "user"}
+	tp.add(PlanOperation{Type: OP_ADD_COLUMN, Name: "age",
+		Sql: "ALTER TABLE user ADD COLUMN age int(11) NOT NULL COMMENT ''", Clause: "ADD COLUMN age int(11) NOT NULL COMMENT ''"})
+	if got := tp.Statements(); len(got) != 1 || got[0] != "ALTER TABLE user ADD COLUMN age int(11) NOT NULL COMMENT ''" {
+		t.Errorf("Expected a single change to keep its statement, got %v", got)
+	}
+
+	tp.add(PlanOperation{Type: OP_ADD_INDEX, Name: "idx_age",
+		Sql: "CREATE INDEX idx_age ON user (age)", Clause: "ADD INDEX idx_age (age)"})
+	want := "ALTER TABLE `user`\n\tADD COLUMN age int(11) NOT NULL COMMENT '',\n\tADD INDEX idx_age (age)"
+	if got := tp.Statements(); len(got) != 1 || got[0] != want {
+		t.Errorf("Expected changes combined into one ALTER TABLE, got %q", got)
+	}
+
+	tp.Split = true
+	if got := tp.Statements(); len(got) != 2 || got[1] != "CREATE INDEX idx_age ON user (age)" {
+		t.Errorf("Expected one statement per change when split, got %v", got)
+	}
+}
@@ -0,0 +1,167 @@
+package dataschema
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// RollbackSql 撤销该操作的sql，无法撤销时返回空字符串
+func (op PlanOperation) RollbackSql() string {
+	switch op.Type {
+	case OP_CREATE_TABLE:
+		return fmt.Sprintf("DROP TABLE %s", quoteIdent(op.Table))
+	case OP_RENAME_TABLE:
+		return fmt.Sprintf("RENAME TABLE %s TO %s", quoteIdent(op.NewDefinition), quoteIdent(op.OldDefinition))
+	case OP_ALTER_TABLE:
+		return fmt.Sprintf("ALTER TABLE %s %s", quoteIdent(op.Table), op.OldDefinition)
+	case OP_ADD_COLUMN:
+		return fmt.Sprintf("ALTER TABLE %s DROP %s", quoteIdent(op.Table), quoteIdent(op.Name))
+	case OP_MODIFY_COLUMN:
+		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", quoteIdent(op.Table), op.OldDefinition)
+	case OP_RENAME_COLUMN:
+		return fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s", quoteIdent(op.Table), quoteIdent(op.Name), op.OldDefinition)
+	case OP_DROP_COLUMN:
+		return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", quoteIdent(op.Table), op.OldDefinition)
+	case OP_ADD_INDEX:
+		return fmt.Sprintf("DROP INDEX %s ON %s", quoteIdent(op.Name), quoteIdent(op.Table))
+	case OP_DROP_INDEX:
+		return fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdent(op.Table), op.OldDefinition)
+	case OP_ALTER_INDEX:
+		return fmt.Sprintf("ALTER TABLE %s ALTER INDEX %s %s", quoteIdent(op.Table), quoteIdent(op.Name), op.OldDefinition)
+	case OP_ADD_FOREIGN_KEY:
+		return fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s", quoteIdent(op.Table), quoteIdent(op.Name))
+	case OP_DROP_FOREIGN_KEY:
+		return fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdent(op.Table), op.OldDefinition)
+	case OP_ADD_CHECK:
+		return fmt.Sprintf("ALTER TABLE %s DROP CHECK %s", quoteIdent(op.Table), quoteIdent(op.Name))
+	case OP_DROP_CHECK:
+		return fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdent(op.Table), op.OldDefinition)
+	case OP_PARTITION_BY:
+		if op.OldDefinition == "" {
+			return fmt.Sprintf("ALTER TABLE %s REMOVE PARTITIONING", quoteIdent(op.Table))
+		}
+		return fmt.Sprintf("ALTER TABLE %s %s", quoteIdent(op.Table), op.OldDefinition)
+	case OP_ADD_PARTITION:
+		if count := strings.TrimPrefix(op.NewDefinition, "PARTITIONS "); count != op.NewDefinition {
+			return fmt.Sprintf("ALTER TABLE %s COALESCE PARTITION %s", quoteIdent(op.Table), count)
+		}
+		return fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s", quoteIdent(op.Table), quoteIdents(strings.Split(op.Name, ",")))
+	case OP_COALESCE_PARTITION:
+		return fmt.Sprintf("ALTER TABLE %s ADD PARTITION %s", quoteIdent(op.Table), op.OldDefinition)
+	case OP_REORGANIZE_PARTITION:
+		return fmt.Sprintf("ALTER TABLE %s REORGANIZE PARTITION %s INTO (\n\t%s\n)", quoteIdent(op.Table), quoteIdents(strings.Split(op.Name, ",")), op.OldDefinition)
+	case OP_CHANGE_PRIMARY_KEY:
+		switch {
+		case op.OldDefinition == "":
+			return fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY", quoteIdent(op.Table))
+		case op.NewDefinition == "":
+			return fmt.Sprintf("ALTER TABLE %s ADD %s", quoteIdent(op.Table), op.OldDefinition)
+		default:
+			return fmt.Sprintf("ALTER TABLE %s DROP PRIMARY KEY, ADD %s", quoteIdent(op.Table), op.OldDefinition)
+		}
+	}
+	return ""
+}
+
+// rollbackWarning 撤销后仍无法恢复的内容
+func (op PlanOperation) rollbackWarning() string {
+	if op.Type == OP_DROP_COLUMN {
+		return "字段结构可以恢复，但删除的数据无法恢复"
+	}
+	if op.Type == OP_PARTITION_BY {
+		return "会按原分区方式重建整张表"
+	}
+	if op.Type == OP_CREATE_TABLE {
+		return "会删除新建的表及其中的数据"
+	}
+	return ""
+}
+
+// RollbackStatements 按撤销顺序(与执行顺序相反)返回回滚sql
+func (p *MigrationPlan) RollbackStatements() []string {
+	var stmts []string
+	for i := len(p.Tables) - 1; i >= 0; i-- {
+		ops := p.Tables[i].Operations
+		for j := len(ops) - 1; j >= 0; j-- {
+			if sql := ops[j].RollbackSql(); sql != "" {
+				stmts = append(stmts, sql)
+			}
+		}
+	}
+	return stmts
+}
+
+// RollbackString 输出带注释的回滚sql
+func (p *MigrationPlan) RollbackString() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- dataschema rollback plan\n")
+	fmt.Fprintf(&b, "-- generated at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	if p.IsEmpty() {
+		fmt.Fprintf(&b, "-- no changes\n")
+		return b.String()
+	}
+	for i := len(p.Tables) - 1; i >= 0; i-- {
+		tp := p.Tables[i]
+		if tp.IsEmpty() {
+			continue
+		}
+		fmt.Fprintf(&b, "\n-- ==================== table: %s ====================\n", tp.Table)
+		for j := len(tp.Operations) - 1; j >= 0; j-- {
+			op := tp.Operations[j]
+			sql := op.RollbackSql()
+			if sql == "" {
+				fmt.Fprintf(&b, "\n-- [%s] %s 无法自动回滚\n", op.Type, op.Name)
+				continue
+			}
+			fmt.Fprintf(&b, "\n-- undo [%s] %s\n", op.Type, op.Name)
+			if warning := op.rollbackWarning(); warning != "" {
+				fmt.Fprintf(&b, "-- 注意: %s\n", warning)
+			}
+			fmt.Fprintf(&b, "%s;\n", sql)
+		}
+	}
+	return b.String()
+}
+
+// GetRollbackSql 获取最近一次计算的迁移计划对应的回滚sql
+func (ts *YamlToSqlHandler) GetRollbackSql() []string {
+	if ts.plan == nil {
+		return nil
+	}
+	return ts.plan.RollbackStatements()
+}
+
+// WriteRollback 将回滚sql写入文件，尚未计算计划时会先调用 Plan
+func (ts *YamlToSqlHandler) WriteRollback(dest string) error {
+	if ts.plan == nil {
+		if _, err := ts.Plan(); err != nil {
+			return err
+		}
+	}
+	return writePlanFile(dest, ts.plan.RollbackString())
+}
+
+// rollbackPath 迁移计划文件对应的回滚文件，如 plan.sql => plan.down.sql
+func rollbackPath(dest string) string {
+	ext := path.Ext(dest)
+	return fmt.Sprintf("%s.down%s", strings.TrimSuffix(dest, ext), ext)
+}
+
+func writePlanFile(dest string, content string) error {
+	os.MkdirAll(path.Dir(dest), os.ModePerm)
+	file, err := os.Create(dest)
+	if err != nil {
+		return &SchemaError{Code: ERR_CODE_WRITE_PLAN, File: dest, Message: "迁移计划写入失败", Err: err}
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if _, err = fmt.Fprint(writer, content); err != nil {
+		return &SchemaError{Code: ERR_CODE_WRITE_PLAN, File: dest, Message: "迁移计划写入失败", Err: err}
+	}
+	return writer.Flush()
+}
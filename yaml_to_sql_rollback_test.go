@@ -0,0 +1,72 @@
+package dataschema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/k-kkong/dataschema/information_schema"
+)
+
+func TestMigrationPlanRollbackStatements(t *testing.T) {
+	tp := TablePlan{Table: "user"}
+	tp.add(PlanOperation{Type: OP_RENAME_TABLE, Name: "user", OldDefinition: "member", NewDefinition: "user"})
+	tp.add(PlanOperation{Type: OP_ADD_COLUMN, Name: "age", NewDefinition: "age int(11) NOT NULL COMMENT ''"})
+	tp.add(PlanOperation{Type: OP_MODIFY_COLUMN, Name: "name",
+		OldDefinition: "name varchar(50) NOT NULL COMMENT ''", NewDefinition: "name varchar(255) NOT NULL COMMENT ''"})
+	tp.add(PlanOperation{Type: OP_RENAME_COLUMN, Name: "nick",
+		OldDefinition: "nickname varchar(50) NOT NULL COMMENT ''", NewDefinition: "nick varchar(50) NOT NULL COMMENT ''"})
+	tp.add(PlanOperation{Type: OP_DROP_COLUMN, Name: "old", OldDefinition: "old int(11) NOT NULL COMMENT ''", Destructive: true})
+	tp.add(PlanOperation{Type: OP_DROP_INDEX, Name: "idx_name", OldDefinition: "INDEX idx_name (name)"})
+	tp.add(PlanOperation{Type: OP_ADD_INDEX, Name: "idx_name", OldDefinition: "INDEX idx_name (name)", NewDefinition: "INDEX idx_name (name,age)"})
+	tp.add(PlanOperation{Type: OP_CHANGE_PRIMARY_KEY, Name: "PRIMARY", OldDefinition: "PRIMARY KEY(id)", NewDefinition: "PRIMARY KEY(id,age)"})
+	plan := &MigrationPlan{Tables: []TablePlan{{Table: "log", Operations: []PlanOperation{{Type: OP_CREATE_TABLE, Table: "log"}}}, tp}}
+
+	want := []string{
+		"ALTER TABLE `user` DROP PRIMARY KEY, ADD PRIMARY KEY(id)",
+		"DROP INDEX `idx_name` ON `user`",
+		"ALTER TABLE `user` ADD INDEX idx_name (name)",
+		"ALTER TABLE `user` ADD COLUMN old int(11) NOT NULL COMMENT ''",
+		"ALTER TABLE `user` CHANGE COLUMN `nick` nickname varchar(50) NOT NULL COMMENT ''",
+		"ALTER TABLE `user` MODIFY COLUMN name varchar(50) NOT NULL COMMENT ''",
+		"ALTER TABLE `user` DROP `age`",
+		"RENAME TABLE `user` TO `member`",
+		"DROP TABLE `log`",
+	}
+	got := plan.RollbackStatements()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Unexpected rollback statements:\n%s", strings.Join(got, "\n"))
+	}
+
+	s := plan.RollbackString()
+	if !strings.Contains(s, "-- undo [DropColumn] old\n-- 注意:") {
+		t.Errorf("Expected data loss warning for dropped column, got:\n%s", s)
+	}
+}
+
+func TestRollbackPath(t *testing.T) {
+	cases := map[string]string{
+		"plan/20240101.sql": "plan/20240101.down.sql",
+		"plan/up":           "plan/up.down",
+	}
+	for dest, want := range cases {
+		if got := rollbackPath(dest); got != want {
+			t.Errorf("rollbackPath(%q) = %q, want %q", dest, got, want)
+		}
+	}
+}
+
+func TestRollbackTimestampDefault(t *testing.T) {
+	// MySQL 5.7 的 EXTRA 中没有 DEFAULT_GENERATED
+	value := "CURRENT_TIMESTAMP"
+	sc := information_schema.SqlTableColumns{ColumnName: "updated_at", ColumnType: "timestamp", DataType: "timestamp", IsNullable: "NO",
+		ColumnDefault: &value, Extra: "on update CURRENT_TIMESTAMP"}
+	tp := TablePlan{Table: "user"}
+	tp.add(PlanOperation{Type: OP_MODIFY_COLUMN, Name: "updated_at", OldDefinition: sqlColumnDefinition(sc),
+		NewDefinition: "`updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT ''"})
+	plan := &MigrationPlan{Tables: []TablePlan{tp}}
+
+	want := "ALTER TABLE `user` MODIFY COLUMN `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP on update CURRENT_TIMESTAMP COMMENT ''"
+	if got := plan.RollbackStatements(); len(got) != 1 || got[0] != want {
+		t.Errorf("Unexpected rollback:\n%v\nwant:\n%s", got, want)
+	}
+}
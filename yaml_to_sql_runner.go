@@ -0,0 +1,319 @@
+package dataschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DEFAULT_STATE_FILE 建议的迁移执行状态文件，需通过 SetStateFile 开启
+const DEFAULT_STATE_FILE = "./dataschema.state"
+
+// 迁移步骤状态
+const (
+	STEP_PENDING = "pending"
+	STEP_DONE    = "done"
+	STEP_FAILED  = "failed"
+)
+
+// MigrationStep 迁移中的一条sql及其执行状态
+type MigrationStep struct {
+	Index      int        `json:"index"`       // 执行顺序，从0开始
+	Table      string     `json:"table"`       // 表名
+	File       string     `json:"file"`        // 配置文件路径
+	Type       string     `json:"type"`        // 操作类型 OP_*
+	Name       string     `json:"name"`        // 字段名/索引名
+	Sql        string     `json:"sql"`         // 执行的sql，不含结尾分号
+	Status     string     `json:"status"`      // STEP_*
+	Error      string     `json:"error"`       // 失败原因
+	ExecutedAt *time.Time `json:"executed_at"` // 最近一次执行时间
+}
+
+// MigrationRun 一次迁移的执行记录，MySQL 的 DDL 会隐式提交无法整体回滚，
+// 因此逐条记录执行状态，失败后可从失败的步骤继续执行
+type MigrationRun struct {
+	Checksum  string          `json:"checksum"`   // 对应的yml结构校验和
+	Rollback  []string        `json:"rollback"`   // 对应的回滚sql
+	StartedAt time.Time       `json:"started_at"` // 首次执行时间
+	Steps     []MigrationStep `json:"steps"`
+}
+
+// newMigrationRun 将迁移计划展开为按顺序执行的步骤
+func newMigrationRun(plan *MigrationPlan, checksum string) *MigrationRun {
+	run := &MigrationRun{Checksum: checksum, Rollback: plan.RollbackStatements(), StartedAt: time.Now()}
+	for _, tp := range plan.Tables {
+		for _, stmt := range tp.PlanStatements() {
+			var names []string
+			for _, op := range stmt.Operations {
+				names = append(names, op.Name)
+			}
+			step := MigrationStep{
+				Index:  len(run.Steps),
+				Table:  tp.Table,
+				File:   tp.File,
+				Type:   stmt.Operations[0].Type,
+				Name:   strings.Join(names, ","),
+				Sql:    stmt.Sql,
+				Status: STEP_PENDING,
+			}
+			if len(stmt.Operations) > 1 {
+				step.Type = OP_ALTER_TABLE
+			}
+			run.Steps = append(run.Steps, step)
+		}
+	}
+	return run
+}
+
+// Finished 是否所有步骤都已执行成功
+func (r *MigrationRun) Finished() bool {
+	for _, step := range r.Steps {
+		if step.Status != STEP_DONE {
+			return false
+		}
+	}
+	return true
+}
+
+// FailedStep 执行失败的步骤，没有时返回nil
+func (r *MigrationRun) FailedStep() *MigrationStep {
+	for i := range r.Steps {
+		if r.Steps[i].Status == STEP_FAILED {
+			return &r.Steps[i]
+		}
+	}
+	return nil
+}
+
+// MixedTables 部分步骤已执行、部分未执行，处于中间状态的表
+func (r *MigrationRun) MixedTables() []string {
+	var tables []string
+	done := map[string]bool{}
+	pending := map[string]bool{}
+	mixed := map[string]bool{}
+	for _, step := range r.Steps {
+		if step.Status == STEP_DONE {
+			done[step.Table] = true
+		} else {
+			pending[step.Table] = true
+		}
+		if done[step.Table] && pending[step.Table] && !mixed[step.Table] {
+			mixed[step.Table] = true
+			tables = append(tables, step.Table)
+		}
+	}
+	return tables
+}
+
+// SetStateFile 设置迁移执行状态文件，默认为空，只在内存中记录，无法跨进程继续执行
+func (ts *YamlToSqlHandler) SetStateFile(stateFile string) *YamlToSqlHandler {
+	ts.stateFile = stateFile
+	return ts
+}
+
+// GetRun 获取最近一次迁移的执行记录
+func (ts *YamlToSqlHandler) GetRun() *MigrationRun {
+	return ts.run
+}
+
+// Resume 从上次失败的步骤继续执行迁移，出错时panic
+func (ts *YamlToSqlHandler) Resume() *YamlToSqlHandler {
+	ts.ResumeE()
+	ts.mustNoError()
+	return ts
+}
+
+// ResumeE 同 Resume，出错时返回 SchemaErrors 而不是panic
+func (ts *YamlToSqlHandler) ResumeE() error {
+	ts.resetErrors()
+	ts.connectSql()
+	if ts.hasError() {
+		return ts.err()
+	}
+	run, err := ts.readRun()
+	if err != nil {
+		ts.addError(err)
+		return ts.err()
+	}
+	if run == nil {
+		run = ts.run
+	}
+	if run == nil || run.Finished() {
+		fmt.Printf("\x1b[%dm没有需要继续执行的迁移 \x1b[0m\n", 36)
+		return nil
+	}
+	ts.run = run
+	ts.runSteps()
+	return ts.err()
+}
+
+// execPlan 将迁移计划展开为步骤并依次执行
+func (ts *YamlToSqlHandler) execPlan() {
+	unfinished, err := ts.readRun()
+	if err != nil {
+		ts.addError(err)
+		return
+	}
+	if unfinished != nil && !unfinished.Finished() {
+		ts.addError(&SchemaError{Code: ERR_CODE_MIGRATION_STATE, File: ts.stateFile,
+			Message: "存在未完成的迁移，请先调用 Resume 继续执行或确认后删除状态文件"})
+		return
+	}
+
+	ts.run = newMigrationRun(ts.plan, ts.schemaChecksum())
+	ts.runSteps()
+}
+
+// runSteps 从第一个未成功的步骤开始逐条执行，每条执行后保存状态；
+// 并发数大于1时按表分组并发执行，某张表失败时该表后续步骤不再执行，其他表继续
+func (ts *YamlToSqlHandler) runSteps() {
+	var statements []string
+	var execErr error
+	var mu sync.Mutex
+	startedAt := time.Now()
+
+	//执行一组步骤，遇到失败时停止该组，返回是否全部成功
+	runGroup := func(group []int) bool {
+		for _, i := range group {
+			step := &ts.run.Steps[i]
+			if step.Status == STEP_DONE {
+				continue
+			}
+			fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
+			fmt.Printf("\x1b[%dm正在执行第 %d/%d 步 sql:\n%s; \x1b[0m\n", 34, step.Index+1, len(ts.run.Steps), step.Sql)
+			fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
+			now := time.Now()
+			err := ts.db.Exec(step.Sql).Error
+
+			mu.Lock()
+			step.ExecutedAt = &now
+			if err != nil {
+				step.Status = STEP_FAILED
+				step.Error = err.Error()
+				if execErr == nil {
+					execErr = err
+				}
+				ts.addStepError(step, err)
+				mu.Unlock()
+				return false
+			}
+			step.Status = STEP_DONE
+			step.Error = ""
+			statements = append(statements, step.Sql)
+			werr := ts.writeRun()
+			mu.Unlock()
+			if werr != nil {
+				ts.addError(werr)
+				return false
+			}
+		}
+		return true
+	}
+
+	groups, serial := stepGroups(ts.run.Steps, ts.concurrency)
+	ok := true
+	if serial {
+		ok = runGroup(groups[0])
+		groups = groups[1:]
+	}
+	if ok {
+		pc := newProgressCounter(PROGRESS_EXECUTE, len(groups))
+		runPool(ts.concurrency, len(groups), func(k int) {
+			runGroup(groups[k])
+			ts.finishTable(pc, ts.run.Steps[groups[k][0]].Table)
+		})
+	}
+
+	ts.saveHistory(startedAt, statements, execErr)
+
+	if execErr != nil {
+		if err := ts.writeRun(); err != nil {
+			ts.addError(err)
+		}
+		return
+	}
+	if ts.hasError() {
+		return
+	}
+	if err := ts.removeRun(); err != nil {
+		ts.addError(err)
+	}
+
+	fmt.Printf("\x1b[%dm>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>： \x1b[0m\n", 34)
+	fmt.Printf("\x1b[%dmSQL更新完毕： \x1b[0m\n", 36)
+	fmt.Printf("\x1b[%dm<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<<： \x1b[0m\n", 34)
+}
+
+// addStepError 记录执行失败的步骤，已执行的DDL不会回滚，需要明确告知处于中间状态的表
+func (ts *YamlToSqlHandler) addStepError(step *MigrationStep, err error) {
+	done := 0
+	for _, s := range ts.run.Steps {
+		if s.Status == STEP_DONE {
+			done++
+		}
+	}
+	message := fmt.Sprintf("执行第 %d 步sql:\n%s;\n时出现错误，已执行的 %d 步不会回滚，修复后可调用 Resume 从该步骤继续执行",
+		step.Index+1, step.Sql, done)
+	if mixed := ts.run.MixedTables(); len(mixed) > 0 {
+		message = fmt.Sprintf("%s，处于部分迁移状态的表: %s", message, strings.Join(mixed, ","))
+	}
+	ts.addError(&SchemaError{
+		Code:    ERR_CODE_EXECUTE_SQL,
+		File:    step.File,
+		Table:   step.Table,
+		Field:   step.Name,
+		Message: message,
+		Err:     err,
+	})
+}
+
+// readRun 读取状态文件中的执行记录，文件不存在时返回nil
+func (ts *YamlToSqlHandler) readRun() (*MigrationRun, *SchemaError) {
+	if ts.stateFile == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(ts.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &SchemaError{Code: ERR_CODE_MIGRATION_STATE, File: ts.stateFile, Message: "读取迁移状态失败", Err: err}
+	}
+	run := &MigrationRun{}
+	if err := json.Unmarshal(content, run); err != nil {
+		return nil, &SchemaError{Code: ERR_CODE_MIGRATION_STATE, File: ts.stateFile, Message: "迁移状态文件格式不正确", Err: err}
+	}
+	return run, nil
+}
+
+// writeRun 保存执行记录到状态文件
+func (ts *YamlToSqlHandler) writeRun() *SchemaError {
+	if ts.stateFile == "" {
+		return nil
+	}
+	content, err := json.MarshalIndent(ts.run, "", "  ")
+	if err == nil {
+		os.MkdirAll(path.Dir(ts.stateFile), os.ModePerm)
+		err = os.WriteFile(ts.stateFile, content, 0644)
+	}
+	if err != nil {
+		return &SchemaError{Code: ERR_CODE_MIGRATION_STATE, File: ts.stateFile, Message: "保存迁移状态失败", Err: err}
+	}
+	return nil
+}
+
+// removeRun 迁移全部完成后删除状态文件
+func (ts *YamlToSqlHandler) removeRun() *SchemaError {
+	if ts.stateFile == "" {
+		return nil
+	}
+	if err := os.Remove(ts.stateFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return &SchemaError{Code: ERR_CODE_MIGRATION_STATE, File: ts.stateFile, Message: "删除迁移状态失败", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package dataschema
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testMigrationPlan() *MigrationPlan {
+	return &MigrationPlan{Tables: []TablePlan{
+		{Table: "user", File: "Entity.User.yml", Operations: []PlanOperation{
+			{Type: OP_ADD_COLUMN, Table: "user", Name: "age", Sql: "ALTER TABLE user ADD age int(11) NOT NULL COMMENT ''"},
+			{Type: OP_ADD_INDEX, Table: "user", Name: "idx_age", Sql: "CREATE INDEX idx_age ON user (age)"},
+		}},
+		{Table: "order", File: "Entity.Order.yml", Operations: []PlanOperation{
+			{Type: OP_ADD_COLUMN, Table: "order", Name: "amount", Sql: "ALTER TABLE order ADD amount int(11) NOT NULL COMMENT ''"},
+		}},
+	}}
+}
+
+func TestMigrationRunSteps(t *testing.T) {
+	run := newMigrationRun(testMigrationPlan(), "sum")
+	if len(run.Steps) != 3 || run.Steps[2].Index != 2 || run.Steps[2].File != "Entity.Order.yml" {
+		t.Fatalf("Unexpected steps: %+v", run.Steps)
+	}
+	if run.Finished() || run.FailedStep() != nil || len(run.MixedTables()) != 0 {
+		t.Fatalf("Expected a fresh pending run, got %+v", run)
+	}
+
+	run.Steps[0].Status = STEP_DONE
+	run.Steps[1].Status = STEP_FAILED
+	if failed := run.FailedStep(); failed == nil || failed.Name != "idx_age" {
+		t.Errorf("Expected idx_age to be the failed step, got %+v", failed)
+	}
+	if mixed := run.MixedTables(); !reflect.DeepEqual(mixed, []string{"user"}) {
+		t.Errorf("Expected user to be in a mixed state, got %v", mixed)
+	}
+
+	for i := range run.Steps {
+		run.Steps[i].Status = STEP_DONE
+	}
+	if !run.Finished() || len(run.MixedTables()) != 0 {
+		t.Errorf("Expected finished run without mixed tables, got %+v", run)
+	}
+}
+
+func TestMigrationRunStateFile(t *testing.T) {
+	ts := NewYamlToSqlHandler().SetStateFile(filepath.Join(t.TempDir(), "dataschema.state"))
+	if run, err := ts.readRun(); run != nil || err != nil {
+		t.Fatalf("Expected no run before the state file exists, got %+v %v", run, err)
+	}
+
+	ts.run = newMigrationRun(testMigrationPlan(), "sum")
+	ts.run.Steps[0].Status = STEP_DONE
+	ts.run.Steps[1].Status = STEP_FAILED
+	ts.run.Steps[1].Error = "Duplicate key name 'idx_age'"
+	if err := ts.writeRun(); err != nil {
+		t.Fatalf("writeRun failed: %v", err)
+	}
+	run, err := ts.readRun()
+	if err != nil || run == nil {
+		t.Fatalf("readRun failed: %v", err)
+	}
+	if run.Checksum != "sum" || run.FailedStep().Error != "Duplicate key name 'idx_age'" {
+		t.Errorf("Unexpected run read back: %+v", run)
+	}
+
+	// 存在未完成的迁移时拒绝开始新的迁移
+	ts.plan = testMigrationPlan()
+	ts.execPlan()
+	if errs := ts.Errors(); len(errs) != 1 || errs[0].Code != ERR_CODE_MIGRATION_STATE {
+		t.Errorf("Expected %s error, got %v", ERR_CODE_MIGRATION_STATE, errs)
+	}
+
+	if err := ts.removeRun(); err != nil {
+		t.Fatalf("removeRun failed: %v", err)
+	}
+	if run, _ := ts.readRun(); run != nil {
+		t.Errorf("Expected state file to be removed")
+	}
+}
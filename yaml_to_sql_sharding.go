@@ -0,0 +1,329 @@
+package dataschema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// 按模板展开的分表数量上限，避免配置错误时生成过多的表
+const MAX_SHARDING_TABLES = 10000
+
+// 日期分表的步进单位
+const (
+	SHARDING_UNIT_YEAR  = "year"
+	SHARDING_UNIT_MONTH = "month"
+	SHARDING_UNIT_DAY   = "day"
+)
+
+// ShardingReport 数据库中存在但未被分表模板覆盖的分表
+type ShardingReport struct {
+	Table     string   // yml中的表名
+	File      string   // 配置文件路径
+	Pattern   string   // 分表模板
+	Uncovered []string // 未覆盖的分表
+}
+
+// shardingExpansion 按模板展开的分表
+type shardingExpansion struct {
+	Table    string
+	File     string
+	Pattern  string
+	Expanded []string // 展开后的分表名
+}
+
+// shardingToken 分表模板中的一段，Verb 为空时是普通文本
+type shardingToken struct {
+	Text  string
+	Verb  byte // d|Y|m
+	Width int  // 整数补零宽度，如 %03d 为3
+}
+
+// shardingPattern 解析后的分表模板
+// 整数模板: user_%03d，日期模板: log_%Y%m，日期模板中的 %d 表示日
+type shardingPattern struct {
+	Pattern string
+	Tokens  []shardingToken
+	Date    bool
+	Unit    string // 日期模板的步进单位
+}
+
+// parseShardingPattern 解析分表模板
+func parseShardingPattern(pattern string) (*shardingPattern, error) {
+	sp := &shardingPattern{Pattern: pattern}
+	var text strings.Builder
+	verbs := 0
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' {
+			text.WriteByte(pattern[i])
+			continue
+		}
+		j := i + 1
+		for j < len(pattern) && pattern[j] >= '0' && pattern[j] <= '9' {
+			j++
+		}
+		if j >= len(pattern) {
+			return nil, fmt.Errorf("分表模板:'%s' 以不完整的 %% 结尾", pattern)
+		}
+		width, _ := strconv.Atoi(pattern[i+1 : j])
+		verb := pattern[j]
+		switch verb {
+		case '%':
+			text.WriteByte('%')
+		case 'd', 'Y', 'm':
+			if text.Len() > 0 {
+				sp.Tokens = append(sp.Tokens, shardingToken{Text: text.String()})
+				text.Reset()
+			}
+			sp.Tokens = append(sp.Tokens, shardingToken{Verb: verb, Width: width})
+			verbs++
+			if verb != 'd' {
+				sp.Date = true
+			}
+		default:
+			return nil, fmt.Errorf("分表模板:'%s' 不支持 %%%c，只支持 %%d/%%Y/%%m", pattern, verb)
+		}
+		i = j
+	}
+	if text.Len() > 0 {
+		sp.Tokens = append(sp.Tokens, shardingToken{Text: text.String()})
+	}
+	if verbs < 1 {
+		return nil, fmt.Errorf("分表模板:'%s' 缺少 %%d/%%Y/%%m", pattern)
+	}
+
+	if !sp.Date {
+		if verbs > 1 {
+			return nil, fmt.Errorf("分表模板:'%s' 只能包含一个 %%d", pattern)
+		}
+		return sp, nil
+	}
+	sp.Unit = SHARDING_UNIT_YEAR
+	for _, tk := range sp.Tokens {
+		if tk.Verb != 0 && tk.Width > 0 {
+			return nil, fmt.Errorf("分表模板:'%s' 日期格式不支持指定宽度", pattern)
+		}
+		if tk.Verb == 'm' && sp.Unit == SHARDING_UNIT_YEAR {
+			sp.Unit = SHARDING_UNIT_MONTH
+		}
+		if tk.Verb == 'd' {
+			sp.Unit = SHARDING_UNIT_DAY
+		}
+	}
+	return sp, nil
+}
+
+// format 按整数或日期生成分表名
+func (sp *shardingPattern) format(n int64, t time.Time) string {
+	var b strings.Builder
+	for _, tk := range sp.Tokens {
+		switch {
+		case tk.Verb == 0:
+			b.WriteString(tk.Text)
+		case !sp.Date:
+			fmt.Fprintf(&b, "%0*d", tk.Width, n)
+		case tk.Verb == 'Y':
+			b.WriteString(t.Format("2006"))
+		case tk.Verb == 'm':
+			b.WriteString(t.Format("01"))
+		case tk.Verb == 'd':
+			b.WriteString(t.Format("02"))
+		}
+	}
+	return b.String()
+}
+
+// regexp 匹配该模板形式的表名，用于找出数据库中未覆盖的分表
+func (sp *shardingPattern) regexp() *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, tk := range sp.Tokens {
+		switch {
+		case tk.Verb == 0:
+			b.WriteString(regexp.QuoteMeta(tk.Text))
+		case !sp.Date && tk.Width > 0:
+			fmt.Fprintf(&b, `\d{%d,}`, tk.Width)
+		case !sp.Date:
+			b.WriteString(`\d+`)
+		case tk.Verb == 'Y':
+			b.WriteString(`\d{4}`)
+		default:
+			b.WriteString(`\d{2}`)
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// parseShardingDate 按步进单位解析日期，如 month 接受 2024-01 或 202401
+func parseShardingDate(unit string, value string) (time.Time, error) {
+	layouts := map[string][]string{
+		SHARDING_UNIT_YEAR:  {"2006"},
+		SHARDING_UNIT_MONTH: {"2006-01", "200601"},
+		SHARDING_UNIT_DAY:   {"2006-01-02", "20060102"},
+	}
+	for _, layout := range layouts[unit] {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("日期:'%s' 格式不正确，应为 %s", value, strings.Join(layouts[unit], " 或 "))
+}
+
+// shardingNames 按 sharding 配置展开分表名
+//
+//	sharding:
+//	  pattern: user_%03d
+//	  from: 0
+//	  to: 255
+//	  step: 1
+func shardingNames(sharding gjson.Result) ([]string, error) {
+	sp, err := parseShardingPattern(sharding.Get("pattern").String())
+	if err != nil {
+		return nil, err
+	}
+	from, to := sharding.Get("from"), sharding.Get("to")
+	if !from.Exists() || !to.Exists() {
+		return nil, fmt.Errorf("分表模板:'%s' 缺少 from/to", sp.Pattern)
+	}
+	step := int(sharding.Get("step").Int())
+	if !sharding.Get("step").Exists() {
+		step = 1
+	}
+	if step < 1 {
+		return nil, fmt.Errorf("分表模板:'%s' 的 step 必须大于0", sp.Pattern)
+	}
+
+	var names []string
+	if !sp.Date {
+		if from.Type != gjson.Number || to.Type != gjson.Number {
+			return nil, fmt.Errorf("分表模板:'%s' 的 from/to 必须为整数", sp.Pattern)
+		}
+		if from.Int() > to.Int() {
+			return nil, fmt.Errorf("分表模板:'%s' 的 from 大于 to", sp.Pattern)
+		}
+		if (to.Int()-from.Int())/int64(step)+1 > MAX_SHARDING_TABLES {
+			return nil, fmt.Errorf("分表模板:'%s' 展开后超过 %d 张表", sp.Pattern, MAX_SHARDING_TABLES)
+		}
+		for n := from.Int(); n <= to.Int(); n += int64(step) {
+			names = append(names, sp.format(n, time.Time{}))
+		}
+		return names, nil
+	}
+
+	start, err := parseShardingDate(sp.Unit, from.String())
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseShardingDate(sp.Unit, to.String())
+	if err != nil {
+		return nil, err
+	}
+	if start.After(end) {
+		return nil, fmt.Errorf("分表模板:'%s' 的 from 晚于 to", sp.Pattern)
+	}
+	for t := start; !t.After(end); {
+		if len(names) >= MAX_SHARDING_TABLES {
+			return nil, fmt.Errorf("分表模板:'%s' 展开后超过 %d 张表", sp.Pattern, MAX_SHARDING_TABLES)
+		}
+		names = append(names, sp.format(0, t))
+		switch sp.Unit {
+		case SHARDING_UNIT_YEAR:
+			t = t.AddDate(step, 0, 0)
+		case SHARDING_UNIT_MONTH:
+			t = t.AddDate(0, step, 0)
+		default:
+			t = t.AddDate(0, 0, step)
+		}
+	}
+	return names, nil
+}
+
+// isShardedTable 是否配置了分表
+func isShardedTable(tbJson gjson.Result) bool {
+	return tbJson.Get("sharding_tables").String() != "" || tbJson.Get("sharding").Exists()
+}
+
+// ymlShardingTables 表的全部分表名，sharding 优先于 sharding_tables，未分表时返回空
+func ymlShardingTables(tbJson gjson.Result) ([]string, error) {
+	if sharding := tbJson.Get("sharding"); sharding.Exists() {
+		return shardingNames(sharding)
+	}
+	var names []string
+	for _, name := range strings.Split(tbJson.Get("sharding_tables").String(), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// uncoveredShards 数据库中符合模板形式但不在展开结果中的分表
+func uncoveredShards(pattern string, expected []string, dbTables []string) ([]string, error) {
+	sp, err := parseShardingPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	re := sp.regexp()
+	covered := map[string]bool{}
+	for _, name := range expected {
+		covered[name] = true
+	}
+	var uncovered []string
+	for _, name := range dbTables {
+		if re.MatchString(name) && !covered[name] {
+			uncovered = append(uncovered, name)
+		}
+	}
+	sort.Strings(uncovered)
+	return uncovered, nil
+}
+
+// checkYmlSharding 检查分表配置
+func checkYmlSharding(tbJson gjson.Result, report func(d Diagnostic, path string)) {
+	sharding := tbJson.Get("sharding")
+	if !sharding.Exists() {
+		return
+	}
+	tname := tbJson.Get("table").String()
+	if tbJson.Get("sharding_tables").String() != "" {
+		report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_SHARDING, Table: tname,
+			Message: "sharding 和 sharding_tables 不能同时配置"}, "Table.sharding")
+		return
+	}
+	if !sharding.IsObject() {
+		report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_SHARDING, Table: tname,
+			Message: "sharding 需要配置 pattern/from/to"}, "Table.sharding")
+		return
+	}
+	if _, err := shardingNames(sharding); err != nil {
+		report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_SHARDING, Table: tname,
+			Message: err.Error()}, "Table.sharding")
+	}
+}
+
+// getShardingReports 找出数据库中存在但未被分表模板覆盖的分表
+func (ts *YamlToSqlHandler) getShardingReports() []ShardingReport {
+	if len(ts.shardings) < 1 {
+		return nil
+	}
+	var dbTables []string
+	ts.db.Table("INFORMATION_SCHEMA.TABLES").
+		Where("TABLE_SCHEMA=?", ts.schema).
+		Pluck("TABLE_NAME", &dbTables)
+
+	var reports []ShardingReport
+	for _, se := range ts.shardings {
+		uncovered, err := uncoveredShards(se.Pattern, se.Expanded, dbTables)
+		if err != nil || len(uncovered) < 1 {
+			continue
+		}
+		reports = append(reports, ShardingReport{Table: se.Table, File: se.File, Pattern: se.Pattern, Uncovered: uncovered})
+	}
+	return reports
+}
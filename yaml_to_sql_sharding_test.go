@@ -0,0 +1,105 @@
+package dataschema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestShardingNames(t *testing.T) {
+	names, err := shardingNames(gjson.Parse(`{"pattern": "user_%03d", "from": 0, "to": 255}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 256 || names[0] != "user_000" || names[255] != "user_255" {
+		t.Errorf("Unexpected integer shards: %d %v", len(names), names[:3])
+	}
+
+	names, err = shardingNames(gjson.Parse(`{"pattern": "log_%Y%m", "from": "2023-11", "to": "2024-02"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"log_202311", "log_202312", "log_202401", "log_202402"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Unexpected monthly shards: %v", names)
+	}
+
+	names, _ = shardingNames(gjson.Parse(`{"pattern": "event_%Y%m%d", "from": "20240227", "to": "20240302", "step": 2}`))
+	if want := []string{"event_20240227", "event_20240229", "event_20240302"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Unexpected daily shards: %v", names)
+	}
+
+	for _, sharding := range []string{
+		`{"pattern": "user", "from": 0, "to": 1}`,
+		`{"pattern": "user_%s", "from": 0, "to": 1}`,
+		`{"pattern": "user_%d_%d", "from": 0, "to": 1}`,
+		`{"pattern": "user_%d", "from": 5, "to": 1}`,
+		`{"pattern": "user_%d", "from": 0}`,
+		`{"pattern": "user_%d", "from": 0, "to": 100000}`,
+		`{"pattern": "log_%Y%m", "from": "2024", "to": "2025"}`,
+	} {
+		if _, err := shardingNames(gjson.Parse(sharding)); err == nil {
+			t.Errorf("Expected error for %s", sharding)
+		}
+	}
+}
+
+func TestUncoveredShards(t *testing.T) {
+	expected := []string{"user_000", "user_001"}
+	dbTables := []string{"user", "user_000", "user_001", "user_002", "user_1000", "user_ab", "user_01", "order_002"}
+	uncovered, err := uncoveredShards("user_%03d", expected, dbTables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"user_002", "user_1000"}; !reflect.DeepEqual(uncovered, want) {
+		t.Errorf("Unexpected uncovered shards: %v", uncovered)
+	}
+
+	uncovered, _ = uncoveredShards("log_%Y%m", []string{"log_202401"}, []string{"log_202312", "log_202401", "log_2024"})
+	if want := []string{"log_202312"}; !reflect.DeepEqual(uncovered, want) {
+		t.Errorf("Unexpected uncovered date shards: %v", uncovered)
+	}
+}
+
+func TestShardingAppendTable(t *testing.T) {
+	ts := NewYamlToSqlHandler()
+	ts.appendTable(`{"Table": {"table": "user", "sharding": {"pattern": "user_%02d", "from": 1, "to": 3}}}`, "Entity.User.yml")
+	ts.appendTable(`{"Table": {"table": "company", "sharding_tables": "company_01, company_02,"}}`, "Entity.Company.yml")
+	var names []string
+	for _, table := range ts.tables {
+		names = append(names, gjson.Get(table, "Table.table").String())
+	}
+	if want := []string{"user_01", "user_02", "user_03", "company_01", "company_02"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Unexpected expanded tables: %v", names)
+	}
+	if len(ts.shardings) != 1 || ts.shardings[0].Table != "user" || len(ts.shardings[0].Expanded) != 3 {
+		t.Errorf("Expected the pattern to be recorded, got %+v", ts.shardings)
+	}
+
+	ts.appendTable(`{"Table": {"table": "log", "sharding": {"pattern": "log_%Y%m", "from": "2024-13", "to": "2025-01"}}}`, "Entity.Log.yml")
+	if len(ts.Errors()) != 1 || ts.Errors()[0].Code != ERR_CODE_INVALID_SHARDING {
+		t.Errorf("Expected invalid sharding error, got %v", ts.Errors())
+	}
+}
+
+func TestValidateSharding(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.User.yml", `Table:
+  table: user
+  sharding_tables: user_01
+  sharding:
+    pattern: user_%02d
+    from: 0
+    to: 3
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+  fields:
+    name:
+      type: varchar
+`)
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	if len(ds) != 1 || ds[0].Code != ERR_CODE_INVALID_SHARDING || ds[0].Line != 4 {
+		t.Errorf("Expected sharding conflict, got %v", ds)
+	}
+}
@@ -0,0 +1,207 @@
+package dataschema
+
+import (
+	"fmt"
+	"strings"
+
+	drivermysql "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Target 应用yml的目标库
+type Target struct {
+	Name   string   // 目标名称，用于输出和状态文件，为空时使用 Schema
+	Dsn    string   // 数据库连接dsn，为空时使用 SetDsn 设置的连接
+	DB     *gorm.DB // 数据库连接，优先于 Dsn，当前库与 Schema 不一致时报错
+	Schema string   // 目标库名，为空时使用连接的当前库
+}
+
+// TargetResult 单个目标库的执行结果
+type TargetResult struct {
+	Target   string         // 目标名称
+	Schema   string         // 目标库名
+	Plan     *MigrationPlan // 迁移计划
+	Run      *MigrationRun  // 执行记录，未执行时为nil
+	Approved bool           // 是否通过审批
+	Errors   SchemaErrors   // 该目标的错误
+}
+
+// TargetResults 全部目标库的执行结果
+type TargetResults []TargetResult
+
+// Summary 每个目标库一行的结果汇总
+func (rs TargetResults) Summary() string {
+	var b strings.Builder
+	for _, r := range rs {
+		tables, statements := 0, 0
+		if r.Plan != nil {
+			for _, tp := range r.Plan.Tables {
+				if !tp.IsEmpty() {
+					tables++
+					statements += len(tp.Statements())
+				}
+			}
+		}
+		status := "无变更"
+		switch {
+		case len(r.Errors) > 0:
+			status = fmt.Sprintf("失败(%d个错误)", len(r.Errors))
+		case r.Run != nil:
+			status = "成功"
+		case r.Plan != nil && !r.Plan.IsEmpty():
+			status = "未执行"
+		}
+		fmt.Fprintf(&b, "%s(%s): %d 张表变更, %d 条语句, %s\n", r.Target, r.Schema, tables, statements, status)
+	}
+	return b.String()
+}
+
+// Failed 执行失败的目标
+func (rs TargetResults) Failed() []string {
+	var names []string
+	for _, r := range rs {
+		if len(r.Errors) > 0 {
+			names = append(names, r.Target)
+		}
+	}
+	return names
+}
+
+// SetSchema 设置目标库名，所有 INFORMATION_SCHEMA 查询都使用该库名，默认为连接的当前库；
+// 使用 SetDsn 时会连接到该库，使用 SetDB 时连接的当前库必须为该库，否则返回 ERR_CODE_DB_CONNECT
+func (ts *YamlToSqlHandler) SetSchema(schema string) *YamlToSqlHandler {
+	ts.schema = schema
+	return ts
+}
+
+// SetTargets 设置多个目标库，*Targets 系列方法会对每个目标库依次应用同一套yml，
+// 设置了状态文件时，每个目标库的执行状态文件为 <stateFile>.<目标名称>
+func (ts *YamlToSqlHandler) SetTargets(targets ...Target) *YamlToSqlHandler {
+	ts.targets = targets
+	return ts
+}
+
+// SetTargetSchemas 使用 SetDsn 设置的连接，按库名设置多个目标库，如每个租户一个库
+func (ts *YamlToSqlHandler) SetTargetSchemas(schemas ...string) *YamlToSqlHandler {
+	ts.targets = nil
+	for _, schema := range schemas {
+		ts.targets = append(ts.targets, Target{Schema: schema})
+	}
+	return ts
+}
+
+// PlanTargets 计算每个目标库的迁移计划，不执行
+func (ts *YamlToSqlHandler) PlanTargets() (TargetResults, error) {
+	return ts.eachTarget(func(th *YamlToSqlHandler) {
+		th.Plan()
+	})
+}
+
+// ExecuteTargetsSafeCheckE 对每个目标库执行 ExecuteSchemaSafeCheckE，每个目标库单独审批，
+// 某个目标库失败时继续处理其他目标库
+func (ts *YamlToSqlHandler) ExecuteTargetsSafeCheckE() (TargetResults, error) {
+	return ts.eachTarget(func(th *YamlToSqlHandler) {
+		th.ExecuteSchemaSafeCheckE()
+	})
+}
+
+// ExecuteTargetsE 对每个目标库执行 ExecuteSchemaE，某个目标库失败时继续处理其他目标库
+func (ts *YamlToSqlHandler) ExecuteTargetsE() (TargetResults, error) {
+	return ts.eachTarget(func(th *YamlToSqlHandler) {
+		th.ExecuteSchemaE()
+	})
+}
+
+// eachTarget 对每个目标库创建独立的处理器并执行 fn，汇总结果和错误
+func (ts *YamlToSqlHandler) eachTarget(fn func(th *YamlToSqlHandler)) (TargetResults, error) {
+	ts.resetErrors()
+	if len(ts.targets) < 1 {
+		ts.addError(&SchemaError{Code: ERR_CODE_DB_CONNECT, Message: "没有设置目标库，请调用 SetTargets 或 SetTargetSchemas"})
+		return nil, ts.err()
+	}
+
+	var results TargetResults
+	for _, target := range ts.targets {
+		th := ts.targetHandler(target)
+		name := targetName(target)
+		fmt.Printf("\x1b[%dm==================== 目标: %s ==================== \x1b[0m\n", 34, name)
+		fn(th)
+		th.closeDB()
+
+		for _, e := range th.Errors() {
+			e.Target = name
+			ts.addError(e)
+		}
+		results = append(results, TargetResult{
+			Target:   name,
+			Schema:   th.schema,
+			Plan:     th.plan,
+			Run:      th.run,
+			Approved: th.approved,
+			Errors:   th.Errors(),
+		})
+	}
+	fmt.Printf("\x1b[%dm%s\x1b[0m", 36, results.Summary())
+	return results, ts.err()
+}
+
+// targetHandler 复制当前的配置，创建指向目标库的处理器
+func (ts *YamlToSqlHandler) targetHandler(target Target) *YamlToSqlHandler {
+	th := NewYamlToSqlHandler()
+	th.IsOutputBuildSchema = ts.IsOutputBuildSchema
+	th.IsEncryOutputBuildSchema = ts.IsEncryOutputBuildSchema
+	th.EncryKey = ts.EncryKey
+	th.BuildSchemaDest = ts.BuildSchemaDest
+	th.YamlPath = ts.YamlPath
+	th.approver = ts.approver
+	th.allowDestructive = ts.allowDestructive
+	th.algorithm = ts.algorithm
+	th.lock = ts.lock
+	th.splitStatements = ts.splitStatements
+	th.fixColumnOrder = ts.fixColumnOrder
+	th.historyTable = ts.historyTable
+	th.recordHistory = ts.recordHistory
+	th.executor = ts.executor
+	th.concurrency = ts.concurrency
+	th.progress = ts.progress
+
+	th.dsn = ts.dsn
+	if target.Dsn != "" {
+		th.dsn = target.Dsn
+	}
+	th.db = target.DB
+	th.schema = target.Schema
+	if ts.stateFile != "" {
+		th.stateFile = ts.stateFile + "." + targetName(target)
+	} else {
+		th.stateFile = ""
+	}
+	return th
+}
+
+// targetName 目标名称，未设置时依次使用库名、dsn中的库名
+func targetName(target Target) string {
+	if target.Name != "" {
+		return target.Name
+	}
+	if target.Schema != "" {
+		return target.Schema
+	}
+	if cfg, err := drivermysql.ParseDSN(target.Dsn); err == nil && cfg.DBName != "" {
+		return cfg.DBName
+	}
+	return "default"
+}
+
+// dsnWithSchema 将dsn中的库名替换为 schema，schema 为空时原样返回
+func dsnWithSchema(dsn string, schema string) (string, error) {
+	if schema == "" {
+		return dsn, nil
+	}
+	cfg, err := drivermysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.DBName = schema
+	return cfg.FormatDSN(), nil
+}
@@ -0,0 +1,94 @@
+package dataschema
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openLazyDB 不会立即连接数据库的连接池
+func openLazyDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "root@tcp(127.0.0.1:1)/pulingfu", SkipInitializeWithVersion: true}),
+		&gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDsnWithSchema(t *testing.T) {
+	dsn, err := dsnWithSchema("root:tiger@(127.0.0.1:3306)/pulingfu?charset=utf8mb4&parseTime=True&loc=Local", "tenant_01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dsn, "@tcp(127.0.0.1:3306)/tenant_01?") || !strings.Contains(dsn, "parseTime=true") {
+		t.Errorf("Unexpected dsn: %s", dsn)
+	}
+	if dsn, _ := dsnWithSchema("root@/pulingfu", ""); dsn != "root@/pulingfu" {
+		t.Errorf("Expected dsn unchanged without schema, got %s", dsn)
+	}
+}
+
+func TestTargetHandler(t *testing.T) {
+	ts := NewYamlToSqlHandler().SetYamlPath("./etc/").SetDsn("root:tiger@(127.0.0.1:3306)/pulingfu").
+		SetConcurrency(4).SetAllowDestructive(true).
+		SetTargetSchemas("tenant_01", "tenant_02")
+	th := ts.targetHandler(ts.targets[1])
+	if th.schema != "tenant_02" || th.dsn != ts.dsn || th.YamlPath != "./etc/" || th.concurrency != 4 || !th.allowDestructive {
+		t.Errorf("Unexpected target handler: %+v", th)
+	}
+	if th.stateFile != "" {
+		t.Errorf("Expected no state file by default, got %s", th.stateFile)
+	}
+	if th := ts.SetStateFile(DEFAULT_STATE_FILE).targetHandler(ts.targets[1]); th.stateFile != DEFAULT_STATE_FILE+".tenant_02" {
+		t.Errorf("Expected a state file per target, got %s", th.stateFile)
+	}
+
+	if name := targetName(Target{Dsn: "root@tcp(127.0.0.1:3306)/tenant_03"}); name != "tenant_03" {
+		t.Errorf("Expected the dsn database as target name, got %s", name)
+	}
+}
+
+func TestTargetResultsSummary(t *testing.T) {
+	results := TargetResults{
+		{Target: "t1", Schema: "tenant_01", Plan: testMigrationPlan(), Run: &MigrationRun{}},
+		{Target: "t2", Schema: "tenant_02", Plan: &MigrationPlan{}},
+		{Target: "t3", Schema: "tenant_03", Errors: SchemaErrors{{Code: ERR_CODE_DB_CONNECT}}},
+	}
+	want := "t1(tenant_01): 2 张表变更, 3 条语句, 成功\n" +
+		"t2(tenant_02): 0 张表变更, 0 条语句, 无变更\n" +
+		"t3(tenant_03): 0 张表变更, 0 条语句, 失败(1个错误)\n"
+	if got := results.Summary(); got != want {
+		t.Errorf("Unexpected summary:\n%s", got)
+	}
+	if failed := results.Failed(); len(failed) != 1 || failed[0] != "t3" {
+		t.Errorf("Unexpected failed targets: %v", failed)
+	}
+
+	// 没有设置目标库
+	if _, err := NewYamlToSqlHandler().PlanTargets(); err == nil {
+		t.Errorf("Expected error without targets")
+	}
+}
+
+func TestCloseDB(t *testing.T) {
+	db := openLazyDB(t)
+	th := NewYamlToSqlHandler().targetHandler(Target{DB: db, Schema: "tenant_01"})
+	th.closeDB()
+	sqlDB, _ := db.DB()
+	if err := sqlDB.Ping(); th.db != db || err != nil && strings.Contains(err.Error(), "closed") {
+		t.Errorf("Expected a caller supplied DB to stay open, got %v", err)
+	}
+
+	owned := openLazyDB(t)
+	th = NewYamlToSqlHandler()
+	th.db, th.ownDB = owned, true
+	th.closeDB()
+	sqlDB, _ = owned.DB()
+	if err := sqlDB.Ping(); th.db != nil || err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("Expected an owned DB to be closed, got %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+package dataschema
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+)
+
+// 诊断级别
+const (
+	SEVERITY_ERROR   = "error"
+	SEVERITY_WARNING = "warning"
+)
+
+// Diagnostic 配置文件校验得到的单条诊断信息
+type Diagnostic struct {
+	File     string // 配置文件路径
+	Line     int    // 行号，从1开始，0表示未知
+	Column   int    // 列号，从1开始，0表示未知
+	Severity string // SEVERITY_ERROR/SEVERITY_WARNING
+	Code     string // 错误码 ERR_CODE_*
+	Table    string // 表名
+	Field    string // 字段名
+	Index    string // 索引名
+	Message  string // 描述
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: [%s] %s", d.File, d.Line, d.Column, d.Severity, d.Code, d.Message)
+}
+
+// Diagnostics 全部诊断信息
+type Diagnostics []Diagnostic
+
+// HasError 是否存在error级别的诊断
+func (ds Diagnostics) HasError() bool {
+	for _, d := range ds {
+		if d.Severity == SEVERITY_ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCount error级别的诊断数量
+func (ds Diagnostics) errorCount() int {
+	var n int
+	for _, d := range ds {
+		if d.Severity == SEVERITY_ERROR {
+			n++
+		}
+	}
+	return n
+}
+
+// Validate 校验配置目录下的全部yml文件并返回所有诊断信息，不会连接数据库
+func (ts *YamlToSqlHandler) Validate() Diagnostics {
+	var ds Diagnostics
+
+	files, err := listYamlFiles(ts.YamlPath)
+	if err != nil {
+		ds = append(ds, Diagnostic{File: ts.YamlPath, Severity: SEVERITY_ERROR, Code: ERR_CODE_READ_DIR, Message: err.Error()})
+		return ds
+	}
+
+	tableFiles := map[string]string{}
+	tables := map[string]gjson.Result{}
+	var checks []func()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			ds = append(ds, Diagnostic{File: file, Severity: SEVERITY_ERROR, Code: ERR_CODE_READ_FILE, Message: err.Error()})
+			continue
+		}
+
+		var doc yaml.Node
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			ds = append(ds, Diagnostic{File: file, Line: yamlErrorLine(err), Severity: SEVERITY_ERROR, Code: ERR_CODE_PARSE_FILE, Message: err.Error()})
+			continue
+		}
+		jb, err := yamlNodeToJSON(&doc)
+		if err != nil {
+			ds = append(ds, Diagnostic{File: file, Severity: SEVERITY_ERROR, Code: ERR_CODE_SERIALIZE, Message: err.Error()})
+			continue
+		}
+
+		positions := map[string]*yaml.Node{}
+		if len(doc.Content) > 0 {
+			yamlNodePositions(doc.Content[0], "", positions)
+		}
+		report := func(d Diagnostic, path string) {
+			d.File = file
+			if node := lookupYamlNode(positions, path); node != nil {
+				d.Line = node.Line
+				d.Column = node.Column
+			}
+			ds = append(ds, d)
+		}
+
+		tbJson := gjson.GetBytes(jb, "Table")
+		tname := tbJson.Get("table").String()
+		if tname != "" {
+			if other, ok := tableFiles[tname]; ok {
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_DUPLICATE_TABLE, Table: tname,
+					Message: fmt.Sprintf("重复定义的表，已在 %s 中定义", other)}, "Table.table")
+			} else {
+				tableFiles[tname] = file
+				tables[tname] = tbJson
+			}
+		}
+		if err := checkSchemaVersion(int(gjson.GetBytes(jb, "version").Int())); err != nil {
+			report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_UNSUPPORTED_VERSION, Table: tname, Message: err.(*SchemaError).Message}, "version")
+		}
+		//与同步时一样严格解析，未定义的键会导致同步失败
+		var ue *UnknownFieldError
+		var se *SchemaError
+		_, parseErr := ParseSchema(content)
+		if errors.As(parseErr, &ue) {
+			report(Diagnostic{Line: ue.Line, Column: ue.Column, Severity: SEVERITY_ERROR, Code: ERR_CODE_UNKNOWN_FIELD, Table: tname,
+				Message: fmt.Sprintf("未知的配置项:'%s'", ue.Field)}, "")
+			parseErr = nil
+		}
+		reported := ds.errorCount()
+		checkYmlTable(tbJson, report)
+		//取值类型不正确等解析错误大多已有更具体的诊断，没有时才报告
+		if parseErr != nil && !errors.As(parseErr, &se) && ds.errorCount() == reported {
+			report(Diagnostic{Line: yamlErrorLine(parseErr), Severity: SEVERITY_ERROR, Code: ERR_CODE_PARSE_FILE, Table: tname,
+				Message: parseErr.Error()}, "")
+		}
+		checks = append(checks, func() { checkYmlForeignKeyReferences(tbJson, tables, report) })
+	}
+	//外键需要在所有表加载完后检查
+	for _, check := range checks {
+		check()
+	}
+
+	return ds
+}
+
+// checkYmlTable 检查单张表的配置，path 为诊断对应的 gjson 路径，用于定位行列
+func checkYmlTable(tbJson gjson.Result, report func(d Diagnostic, path string)) {
+	if !tbJson.Exists() || !tbJson.IsObject() {
+		report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_TABLE, Message: "缺少 Table 配置"}, "")
+		return
+	}
+	tname := tbJson.Get("table").String()
+	if tname == "" {
+		report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_MISSING_TABLE, Message: "缺少表名"}, "Table")
+	}
+	checkYmlSharding(tbJson, report)
+	if len(ymlPreviousNames(tbJson)) > 0 && isShardedTable(tbJson) {
+		report(Diagnostic{Severity: SEVERITY_WARNING, Code: ERR_CODE_INVALID_TABLE, Table: tname,
+			Message: "分表不支持表级 renamed_from，将被忽略"}, "Table.renamed_from")
+	}
+
+	for _, option := range []string{"charset", "collate"} {
+		if tbJson.Get("options."+option).String() == "" {
+			report(Diagnostic{Severity: SEVERITY_WARNING, Code: ERR_CODE_INVALID_OPTION, Table: tname,
+				Message: fmt.Sprintf("缺少 options.%s，建表时会失败", option)}, "Table.options")
+		}
+	}
+
+	for _, option := range []string{"algorithm", "lock"} {
+		value := tbJson.Get("options." + option).String()
+		if value != "" && !onlineDDLOptions[option][strings.ToLower(value)] {
+			report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_OPTION, Table: tname,
+				Message: fmt.Sprintf("不支持的 options.%s:'%s'", option, value)}, "Table.options."+option)
+		}
+	}
+
+	for _, section := range []string{"id", "fields"} {
+		tbJson.Get(section).ForEach(func(key, value gjson.Result) bool {
+			path := "Table." + section + "." + key.String()
+			if section == "id" && tbJson.Get("fields."+key.String()).Exists() {
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_DUPLICATE_FIELD, Table: tname, Field: key.String(),
+					Message: "主键字段和普通字段重名"}, path)
+			}
+			if !value.IsObject() {
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_FIELD, Table: tname, Field: key.String(),
+					Message: "字段配置不正确"}, path)
+				return true
+			}
+			for _, oldName := range ymlPreviousNames(value) {
+				if tbJson.Get("fields."+oldName).Exists() || tbJson.Get("id."+oldName).Exists() {
+					report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_FIELD, Table: tname, Field: key.String(),
+						Message: fmt.Sprintf("renamed_from:'%s' 仍在配置中", oldName)}, path+".renamed_from")
+				}
+			}
+			columnType := value.Get("type").String()
+			if columnType == "" {
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_FIELD, Table: tname, Field: key.String(),
+					Message: "缺少字段类型"}, path)
+			} else if verifyDataType(baseDataType(columnType)) == 0 {
+				report(Diagnostic{Severity: SEVERITY_WARNING, Code: ERR_CODE_INVALID_FIELD, Table: tname, Field: key.String(),
+					Message: fmt.Sprintf("未识别的字段类型:'%s'", columnType)}, path+".type")
+			}
+			return true
+		})
+	}
+
+	checkYmlForeignKeys(tbJson, report)
+	checkYmlChecks(tbJson, report)
+	checkYmlPartitions(tbJson, report)
+	checkYmlCharsets(tbJson, report)
+
+	for _, section := range []string{"dropped_fields", "dropped_indexes", "dropped_partitions"} {
+		dropped := tbJson.Get(section)
+		if !dropped.Exists() {
+			continue
+		}
+		if !dropped.IsArray() {
+			report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_TABLE, Table: tname,
+				Message: fmt.Sprintf("%s 必须是数组", section)}, "Table."+section)
+			continue
+		}
+		for i, v := range dropped.Array() {
+			var conflict bool
+			if section == "dropped_fields" {
+				conflict = tbJson.Get("fields."+v.String()).Exists() || tbJson.Get("id."+v.String()).Exists()
+			} else if section == "dropped_partitions" {
+				for _, item := range ymlPartitionItems(tbJson.Get("options.partitions")) {
+					conflict = conflict || item.Name == v.String()
+				}
+			} else {
+				for _, s := range ymlIndexSections {
+					conflict = conflict || tbJson.Get(s.Name+"."+v.String()).Exists()
+				}
+			}
+			if conflict {
+				report(Diagnostic{Severity: SEVERITY_ERROR, Code: ERR_CODE_INVALID_TABLE, Table: tname,
+					Message: fmt.Sprintf("%s:'%s' 仍在配置中，不能同时声明删除", section, v.String())}, fmt.Sprintf("Table.%s.%d", section, i))
+			}
+		}
+	}
+
+	checkYmlIndexes(tbJson, report)
+}
+
+// onlineDDLOptions 在线DDL选项允许的取值
+var onlineDDLOptions = map[string]map[string]bool{
+	"algorithm": {"default": true, "instant": true, "inplace": true, "copy": true},
+	"lock":      {"default": true, "none": true, "shared": true, "exclusive": true},
+}
+
+// baseDataType 去掉类型长度，如 varchar(11) => varchar
+func baseDataType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if b := strings.Index(t, "("); b >= 0 {
+		if e := strings.Index(t, ")"); e > b {
+			t = strings.TrimSpace(t[:b] + t[e+1:])
+		}
+	}
+	return t
+}
+
+// yamlNodePositions 记录每个 gjson 路径对应的yaml节点，mapping 记录 key 节点的位置
+func yamlNodePositions(node *yaml.Node, prefix string, positions map[string]*yaml.Node) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			path := join(node.Content[i].Value)
+			positions[path] = node.Content[i]
+			yamlNodePositions(node.Content[i+1], path, positions)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			path := join(strconv.Itoa(i))
+			positions[path] = item
+			yamlNodePositions(item, path, positions)
+		}
+	case yaml.AliasNode:
+		if node.Alias != nil {
+			yamlNodePositions(node.Alias, prefix, positions)
+		}
+	}
+}
+
+// lookupYamlNode 查找路径对应的节点，找不到时逐级向上查找
+func lookupYamlNode(positions map[string]*yaml.Node, path string) *yaml.Node {
+	for path != "" {
+		if node, ok := positions[path]; ok {
+			return node
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return nil
+}
+
+var yamlErrorLineRegexp = regexp.MustCompile(`line (\d+)`)
+
+// yamlErrorLine 从yaml解析错误中取出行号
+func yamlErrorLine(err error) int {
+	m := yamlErrorLineRegexp.FindStringSubmatch(err.Error())
+	if len(m) < 2 {
+		return 0
+	}
+	line, _ := strconv.Atoi(m[1])
+	return line
+}
+
+// listYamlFiles 列出目录下的yml文件
+func listYamlFiles(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, f := range files {
+		filename := string(f.Name())
+		if strings.Contains(filename, ".yml") {
+			paths = append(paths, fmt.Sprintf("%s%s", dir, filename))
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,144 @@
+package dataschema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestYaml(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateCollectsAllDiagnostics(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.A.yml", `Table:
+  table: a
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+  indexes:
+    idx_missing:
+      columns:
+        - nope
+  fields:
+    name:
+      type: varchar
+`)
+	writeTestYaml(t, dir, "Entity.B.yml", `Table:
+  table: a
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+  fields:
+    title:
+      nullable: true
+`)
+
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	if !ds.HasError() {
+		t.Fatal("Expected error diagnostics")
+	}
+
+	codes := map[string]Diagnostic{}
+	for _, d := range ds {
+		codes[d.Code] = d
+	}
+	d, ok := codes[ERR_CODE_INDEX_COLUMN_NOT_FOUND]
+	if !ok {
+		t.Fatalf("Expected %s diagnostic, got %v", ERR_CODE_INDEX_COLUMN_NOT_FOUND, ds)
+	}
+	if d.Line != 9 || d.Column != 11 {
+		t.Errorf("Expected position 9:11, got %d:%d", d.Line, d.Column)
+	}
+	if _, ok := codes[ERR_CODE_DUPLICATE_TABLE]; !ok {
+		t.Errorf("Expected %s diagnostic, got %v", ERR_CODE_DUPLICATE_TABLE, ds)
+	}
+	if _, ok := codes[ERR_CODE_INVALID_FIELD]; !ok {
+		t.Errorf("Expected %s diagnostic, got %v", ERR_CODE_INVALID_FIELD, ds)
+	}
+}
+
+func TestValidateExampleSchemas(t *testing.T) {
+	for _, dir := range []string{"./cmd/test_yaml_to_sql/etc/", "./cmd/test_yaml_to_sql/etc2/"} {
+		ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+		if ds.HasError() {
+			t.Errorf("Expected %s to be valid, got %v", dir, ds)
+		}
+	}
+}
+
+func TestValidateRenamedFromConflict(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.A.yml", `Table:
+  table: a
+  renamed_from: a_old
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+  fields:
+    nickname:
+      type: varchar
+      renamed_from: [nick, name]
+    name:
+      type: varchar
+`)
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	if len(ds) != 1 || ds[0].Field != "nickname" || ds[0].Line != 10 {
+		t.Errorf("Expected one renamed_from diagnostic on line 10, got %v", ds)
+	}
+}
+
+func TestValidateOnlineDDLOptions(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeTestYaml(t, dir, "Entity.A.yml", `Table:
+  table: a
+  options:
+    charset: utf8mb4
+    collate: utf8mb4_general_ci
+    algorithm: inplace
+    lock: nolock
+  fields:
+    name:
+      type: varchar
+`)
+	ds := NewYamlToSqlHandler().SetYamlPath(dir).Validate()
+	if len(ds) != 1 || ds[0].Code != ERR_CODE_INVALID_OPTION || ds[0].Line != 7 {
+		t.Errorf("Expected one invalid lock diagnostic on line 7, got %v", ds)
+	}
+}
+
+func TestVerifyDataTypeUnchanged(t *testing.T) {
+	// 改为 dataTypes 表之前的分类，保证类型校验的行为不变
+	legacy := map[int][]string{
+		1: {"int", "integer", "tinyint", "smallint", "mediumint", "bigint",
+			"int unsigned", "integer unsigned", "tinyint unsigned", "smallint unsigned",
+			"mediumint unsigned", "bigint unsigned", "bit"},
+		2: {"float", "double", "decimal"},
+		3: {"bool", "boolean"},
+		4: {"enum", "set", "varchar", "char", "tinytext", "mediumtext", "text", "longtext", "blob", "tinyblob",
+			"mediumblob", "longblob", "binary", "varbinary", "json"},
+		5: {"date", "datetime", "timestamp", "time", "year"},
+		6: {"geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon", "geometrycollection"},
+	}
+	var count int
+	for category, types := range legacy {
+		for _, dataType := range types {
+			count++
+			if got := verifyDataType(dataType); got != category {
+				t.Errorf("verifyDataType(%q) = %d, want %d", dataType, got, category)
+			}
+		}
+	}
+	if len(dataTypes) != count {
+		t.Errorf("Expected %d data types, got %d", count, len(dataTypes))
+	}
+	for _, dataType := range []string{"", "Int", "INT", "varchar(11)", "int  unsigned", "double unsigned", "string"} {
+		if got := verifyDataType(dataType); got != 0 {
+			t.Errorf("verifyDataType(%q) = %d, want 0", dataType, got)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package dataschema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// YamlToStructHandler 根据yml配置生成与 TblToStructHandler.GenerateTblStruct 相同的 go struct model，不需要连接数据库
+type YamlToStructHandler struct {
+	YamlPath string //yaml文件路径
+
+	ts *TblToStructHandler //生成配置及输出，与数据库生成共用
+}
+
+// NewYamlToStructHandler 新建yml结构生成器
+func NewYamlToStructHandler() *YamlToStructHandler {
+	return &YamlToStructHandler{
+		ts: NewTblToStructHandler(),
+	}
+}
+
+// SetYamlPath 设置yaml配置文件路径
+func (ys *YamlToStructHandler) SetYamlPath(yamlPath string) *YamlToStructHandler {
+	ys.YamlPath = yamlPath
+	return ys
+}
+
+// SetSavePath 设置生成model文件的保存路径
+func (ys *YamlToStructHandler) SetSavePath(savePath string) *YamlToStructHandler {
+	ys.ts.SetSavePath(savePath)
+	return ys
+}
+
+// SetTableName 设置要生成model的表，分表使用分表名，如 company_test_01
+func (ys *YamlToStructHandler) SetTableName(tableName string) *YamlToStructHandler {
+	ys.ts.SetTableName(tableName)
+	return ys
+}
+
+// SetIsNullableValuePoint 设置可空字段是否设置为指针
+func (ys *YamlToStructHandler) SetIsNullableValuePoint(nullableValuePoint bool) *YamlToStructHandler {
+	ys.ts.SetIsNullableValuePoint(nullableValuePoint)
+	return ys
+}
+
+// SetStructOrmTag 设置所生成对应的orm 标记类型  默认为 `gorm:"column:xxx"`
+func (ys *YamlToStructHandler) SetStructOrmTag(modelOrmTagType string) *YamlToStructHandler {
+	ys.ts.SetStructOrmTag(modelOrmTagType)
+	return ys
+}
+
+// SetOtherTags 添加其他的标签 如json ==> `json:"xxx"`
+func (ys *YamlToStructHandler) SetOtherTags(otherTag ...string) *YamlToStructHandler {
+	ys.ts.SetOtherTags(otherTag...)
+	return ys
+}
+
+// SeTblStructColumnNameInfo 设置行信息，排序方式 FIELD_ORDER_ORDINAL_POSITION 为yml中的顺序
+func (ys *YamlToStructHandler) SeTblStructColumnNameInfo(columnNameType, columnOrder, prefix, suffix string) *YamlToStructHandler {
+	ys.ts.SeTblStructColumnNameInfo(columnNameType, columnOrder, prefix, suffix)
+	return ys
+}
+
+// SetTblStructNameInfo 默认生成的结构体名类型为CamelCase写法，无前后后缀
+func (ys *YamlToStructHandler) SetTblStructNameInfo(structNameType, prifix, suffix string) *YamlToStructHandler {
+	ys.ts.SetTblStructNameInfo(structNameType, prifix, suffix)
+	return ys
+}
+
+// SetPackageInfo 设置生成的包名
+func (ys *YamlToStructHandler) SetPackageInfo(packageName, prifix, suffix string) *YamlToStructHandler {
+	ys.ts.SetPackageInfo(packageName, prifix, suffix)
+	return ys
+}
+
+// SetTimeType 设置数据库中的时间类型对应 modelstruct中的什么 time.Time/string
+func (ys *YamlToStructHandler) SetTimeType(timeType string) *YamlToStructHandler {
+	ys.ts.SetTimeType(timeType)
+	return ys
+}
+
+// GetAllTableNames 获取yml中定义的所有表，分表按分表名展开
+func (ys *YamlToStructHandler) GetAllTableNames() ([]string, error) {
+	names, _, err := ys.loadTables()
+	return names, err
+}
+
+// GenerateTblStruct 根据yml中的表生成对应结构体，出错时panic
+func (ys *YamlToStructHandler) GenerateTblStruct() *YamlToStructHandler {
+	if err := ys.GenerateTblStructE(); err != nil {
+		panic(err)
+	}
+	return ys
+}
+
+// GenerateTblStructE 同 GenerateTblStruct，出错时返回错误而不是panic
+func (ys *YamlToStructHandler) GenerateTblStructE() error {
+	_, tables, err := ys.loadTables()
+	if err != nil {
+		return err
+	}
+	return ys.generate(tables)
+}
+
+// GenerateAllTblStruct 一键生成yml中所有表对应的结构体，出错时panic
+func (ys *YamlToStructHandler) GenerateAllTblStruct() {
+	if err := ys.GenerateAllTblStructE(); err != nil {
+		panic(err)
+	}
+}
+
+// GenerateAllTblStructE 同 GenerateAllTblStruct，出错时返回错误而不是panic
+func (ys *YamlToStructHandler) GenerateAllTblStructE() error {
+	names, tables, err := ys.loadTables()
+	if err != nil {
+		return err
+	}
+	for _, tname := range names {
+		ys.SetPackageInfo(tname, "tbl_", "").
+			SetSavePath(fmt.Sprintf("./tbl_%s/schema_model.go", tname)).
+			SetTableName(tname)
+		if err := ys.generate(tables); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generate 生成 SetTableName 指定的表
+func (ys *YamlToStructHandler) generate(tables map[string]gjson.Result) error {
+	if ys.ts.tableName == "" {
+		return &SchemaError{Code: ERR_CODE_MISSING_TABLE, File: ys.YamlPath, Message: "请先调用SetTableName设置要生成结构的表"}
+	}
+	tbl, ok := tables[ys.ts.tableName]
+	if !ok {
+		return &SchemaError{Code: ERR_CODE_MISSING_TABLE, File: ys.YamlPath, Table: ys.ts.tableName, Message: "yml中没有定义此表"}
+	}
+	ys.ts.setColumns(ymlStructColumns(tbl, ys.ts.tblStructColumnInfo.ColumnOrder))
+	ys.ts.saveTblStruct(ys.ts.tblStructContent(tbl.Get("options.comment").String()))
+	return nil
+}
+
+// loadTables 读取并校验yml，返回按文件顺序排列的表名及表名对应的配置
+func (ys *YamlToStructHandler) loadTables() ([]string, map[string]gjson.Result, error) {
+	yts := NewYamlToSqlHandler().SetYamlPath(ys.YamlPath)
+	yts.reset().getyamlFileFullPaths().getYamlDatas().verifyYmlFile()
+	if err := yts.err(); err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	tables := map[string]gjson.Result{}
+	for _, table := range yts.tables {
+		tbl := gjson.Get(table, "Table")
+		tname := tbl.Get("table").String()
+		names = append(names, tname)
+		tables[tname] = tbl
+	}
+	return names, tables, nil
+}
+
+// ymlStructColumns yml字段对应的数据库字段信息，主键字段在前，
+// order 为 FIELD_ORDER_FIELD_NAME 时按字段名排序，否则按yml中的顺序
+func ymlStructColumns(tbl gjson.Result, order string) []column {
+	var cols []column
+	gjson.Parse(ymlMergedFields(tbl)).ForEach(func(key, value gjson.Result) bool {
+		nullable := "NO"
+		if value.Get("nullable").Bool() {
+			nullable = "YES"
+		}
+		cols = append(cols, column{
+			ColumnName:    key.String(),
+			Type:          ymlDataType(value.Get("type").String()),
+			Nullable:      nullable,
+			TableName:     tbl.Get("table").String(),
+			ColumnComment: value.Get("comment").String(),
+		})
+		return true
+	})
+	if order == FIELD_ORDER_FIELD_NAME || order == "" {
+		sort.SliceStable(cols, func(i, j int) bool { return cols[i].ColumnName < cols[j].ColumnName })
+	}
+	return cols
+}
+
+// ymlDataType yml类型对应的 INFORMATION_SCHEMA.COLUMNS.DATA_TYPE，如 integer unsigned => int，varchar(11) => varchar
+func ymlDataType(t string) string {
+	fields := strings.Fields(baseDataType(getTypeYml2SqlMapping(t)))
+	if len(fields) < 1 {
+		return ""
+	}
+	return fields[0]
+}
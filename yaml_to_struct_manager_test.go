@@ -0,0 +1,94 @@
+package dataschema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestYamlToStructHandler(t *testing.T) {
+	dir := t.TempDir()
+	yml := `Table:
+  type: entity
+  table: user_log
+  sharding:
+    pattern: user_log_%02d
+    from: 0
+    to: 1
+  options:
+    comment: 用户日志
+  id:
+    id:
+      type: bigint unsigned
+      generator: AUTO_INCREMENT
+  fields:
+    name:
+      type: varchar(32)
+      comment: 名称
+    deleted_at:
+      type: datetime
+      nullable: true
+`
+	if err := os.WriteFile(filepath.Join(dir, "Entity.UserLog.yml"), []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ys := NewYamlToStructHandler().SetYamlPath(dir + "/")
+	names, err := ys.GetAllTableNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"user_log_00", "user_log_01"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Unexpected table names: %v", names)
+	}
+
+	savePath := filepath.Join(dir, "model", "user_log.go")
+	err = ys.SetTableName("user_log_01").
+		SetSavePath(savePath).
+		SetPackageInfo("model", "", "").
+		SetIsNullableValuePoint(true).
+		SeTblStructColumnNameInfo(CAMEL_CASE, FIELD_ORDER_ORDINAL_POSITION, "", "").
+		GenerateTblStructE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(savePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(content)
+	for _, want := range []string{
+		"package model\n",
+		"import \"time\"\n",
+		"//用户日志\n",
+		"type UserLog01 struct{\n",
+		"\tId        int        `gorm:\"column:id\" `         //是否可空:NO \n",
+		"\tName      string     `gorm:\"column:name\" `       //是否可空:NO 名称\n",
+		"\tDeletedAt *time.Time `gorm:\"column:deleted_at\" ` //是否可空:YES \n",
+		"\t return \"user_log_01\"\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in:\n%s", want, got)
+		}
+	}
+
+	if err := ys.SetTableName("user_log").GenerateTblStructE(); err == nil {
+		t.Error("Expected error for undefined table")
+	}
+}
+
+func TestYmlStructColumnsOrder(t *testing.T) {
+	cols := ymlStructColumns(gjson.Parse(`{"table": "t", "id": {"id": {"type": "integer unsigned"}},
+		"fields": {"b": {"type": "decimal(10,2)", "nullable": true}, "a": {"type": "tinyint(1)"}}}`), FIELD_ORDER_FIELD_NAME)
+	var got []string
+	for _, col := range cols {
+		got = append(got, col.ColumnName+":"+col.Type+":"+col.Nullable)
+	}
+	if want := []string{"a:tinyint:NO", "b:decimal:YES", "id:int:NO"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected columns: %v", got)
+	}
+}